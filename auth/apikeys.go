@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// APIKeyPrefix starts every API key secret, telling them apart from static
+// tokens without a lookup.
+const APIKeyPrefix = "vkr_"
+
+const (
+	apiKeyStorePrefix = "valkey-rest:apikeys:"
+	apiKeyCacheTTL    = 30 * time.Second
+	apiKeyCacheSize   = 1024
+)
+
+// CreateAPIKeyRequest is the body of POST /admin/apikeys.
+type CreateAPIKeyRequest struct {
+	Name        string   `json:"name"`
+	Role        string   `json:"role"`
+	KeyPatterns []string `json:"key_patterns,omitempty"`
+	Namespaces  []string `json:"namespaces,omitempty"`
+	ExpiresIn   int64    `json:"expires_in,omitempty"` // Lifetime in seconds, 0 for no expiry
+	RateLimit   int64    `json:"rate_limit,omitempty"` // Requests per minute, 0 for unlimited
+}
+
+// APIKey is the stored record of an API key. Only the SHA-256 hash of the
+// secret is kept.
+type APIKey struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Role        string     `json:"role"`
+	KeyPatterns []string   `json:"key_patterns,omitempty"`
+	Namespaces  []string   `json:"namespaces,omitempty"`
+	RateLimit   int64      `json:"rate_limit,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Hash        string     `json:"-"`
+}
+
+// apiKeyRecord is the JSON stored in Valkey, which unlike the API
+// representation includes the hash.
+type apiKeyRecord struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+type apiKeyCacheEntry struct {
+	principal *Principal // nil caches a miss
+	expires   time.Time
+}
+
+// APIKeyStore keeps API keys in Valkey with a small in-memory cache in front
+// so authenticating a request doesn't cost a round trip every time.
+type APIKeyStore struct {
+	client valkey.Client
+
+	mu    sync.Mutex
+	cache map[string]apiKeyCacheEntry
+}
+
+func NewAPIKeyStore(client valkey.Client) *APIKeyStore {
+	return &APIKeyStore{
+		client: client,
+		cache:  make(map[string]apiKeyCacheEntry),
+	}
+}
+
+// APIKeyIDKey is the Valkey key holding the record of an API key.
+func APIKeyIDKey(id string) string { return apiKeyStorePrefix + "id:" + id }
+
+func apiKeyHashKey(hash string) string { return apiKeyStorePrefix + "hash:" + hash }
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Create stores a new API key and returns its record along with the secret,
+// which cannot be recovered afterwards.
+func (ks *APIKeyStore) Create(ctx context.Context, req CreateAPIKeyRequest, role Role) (*APIKey, string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+	secret = APIKeyPrefix + secret
+
+	key := &APIKey{
+		ID:          id,
+		Name:        req.Name,
+		Role:        role.String(),
+		KeyPatterns: req.KeyPatterns,
+		Namespaces:  req.Namespaces,
+		RateLimit:   req.RateLimit,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		Hash:        hashToken(secret),
+	}
+	if req.ExpiresIn > 0 {
+		expires := key.CreatedAt.Add(time.Duration(req.ExpiresIn) * time.Second)
+		key.ExpiresAt = &expires
+	}
+
+	data, err := json.Marshal(apiKeyRecord{APIKey: *key, Hash: key.Hash})
+	if err != nil {
+		return nil, "", err
+	}
+
+	idCmd := ks.client.B().Set().Key(APIKeyIDKey(id)).Value(string(data))
+	hashCmd := ks.client.B().Set().Key(apiKeyHashKey(key.Hash)).Value(id)
+	var cmds valkey.Commands
+	if req.ExpiresIn > 0 {
+		ttl := time.Duration(req.ExpiresIn) * time.Second
+		cmds = valkey.Commands{idCmd.Ex(ttl).Build(), hashCmd.Ex(ttl).Build()}
+	} else {
+		cmds = valkey.Commands{idCmd.Build(), hashCmd.Build()}
+	}
+
+	for _, resp := range ks.client.DoMulti(ctx, cmds...) {
+		if err := resp.Error(); err != nil {
+			return nil, "", err
+		}
+	}
+	return key, secret, nil
+}
+
+// Get returns the record of an API key by ID, or valkey.Nil if it doesn't exist.
+func (ks *APIKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	data, err := ks.client.Do(ctx, ks.client.B().Get().Key(APIKeyIDKey(id)).Build()).ToString()
+	if err != nil {
+		return nil, err
+	}
+
+	var record apiKeyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("decode API key %s: %w", id, err)
+	}
+	record.APIKey.Hash = record.Hash
+	return &record.APIKey, nil
+}
+
+// Delete revokes an API key. Other instances keep honouring their cached copy
+// for up to apiKeyCacheTTL.
+func (ks *APIKeyStore) Delete(ctx context.Context, id string) error {
+	key, err := ks.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Separate commands, since the two keys may live in different cluster slots
+	for _, resp := range ks.client.DoMulti(ctx,
+		ks.client.B().Del().Key(apiKeyHashKey(key.Hash)).Build(),
+		ks.client.B().Del().Key(APIKeyIDKey(id)).Build(),
+	) {
+		if err := resp.Error(); err != nil {
+			return err
+		}
+	}
+
+	ks.mu.Lock()
+	delete(ks.cache, key.Hash)
+	ks.mu.Unlock()
+	return nil
+}
+
+// Lookup resolves an API key secret to a principal, or nil if it is unknown
+// or expired.
+func (ks *APIKeyStore) Lookup(ctx context.Context, secret string) (*Principal, error) {
+	hash := hashToken(secret)
+	now := time.Now()
+
+	ks.mu.Lock()
+	entry, ok := ks.cache[hash]
+	ks.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.principal, nil
+	}
+
+	principal, err := ks.load(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.mu.Lock()
+	if len(ks.cache) >= apiKeyCacheSize {
+		ks.evictLocked(now)
+	}
+	ks.cache[hash] = apiKeyCacheEntry{principal: principal, expires: now.Add(apiKeyCacheTTL)}
+	ks.mu.Unlock()
+	return principal, nil
+}
+
+func (ks *APIKeyStore) load(ctx context.Context, hash string) (*Principal, error) {
+	id, err := ks.client.Do(ctx, ks.client.B().Get().Key(apiKeyHashKey(hash)).Build()).ToString()
+	if valkey.IsValkeyNil(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	key, err := ks.Get(ctx, id)
+	if valkey.IsValkeyNil(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, nil
+	}
+
+	role, err := ParseRole(key.Role)
+	if err != nil {
+		return nil, nil
+	}
+
+	return &Principal{
+		ID:          "apikey:" + key.ID,
+		Name:        key.Name,
+		Role:        role,
+		KeyPatterns: key.KeyPatterns,
+		Namespaces:  key.Namespaces,
+		RateLimit:   key.RateLimit,
+	}, nil
+}
+
+// evictLocked drops expired entries, or everything if none have expired yet.
+func (ks *APIKeyStore) evictLocked(now time.Time) {
+	for hash, entry := range ks.cache {
+		if now.After(entry.expires) {
+			delete(ks.cache, hash)
+		}
+	}
+	if len(ks.cache) >= apiKeyCacheSize {
+		ks.cache = make(map[string]apiKeyCacheEntry)
+	}
+}
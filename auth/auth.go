@@ -0,0 +1,224 @@
+// Package auth resolves bearer tokens, API keys and JWTs to principals with
+// a role and optional key restrictions.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Role is the level of access granted to a token. Each role includes the
+// permissions of the roles below it.
+type Role int
+
+const (
+	RoleRead Role = iota + 1
+	RoleWrite
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleRead:
+		return "read"
+	case RoleWrite:
+		return "write"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "unknown"
+}
+
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(s) {
+	case "read", "read-only", "readonly":
+		return RoleRead, nil
+	case "write", "read-write", "readwrite":
+		return RoleWrite, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return 0, fmt.Errorf("unknown role %q", s)
+}
+
+// Principal is the identity a request was authenticated as.
+type Principal struct {
+	ID          string // Unique across credential types, e.g. "token:dashboard"
+	Name        string
+	Role        Role
+	KeyPatterns []string // Glob patterns the principal may access; empty means all keys
+	Namespaces  []string // Namespaces the principal must use one of; empty means any, or none
+	RateLimit   int64    // Requests per minute; 0 means unlimited
+}
+
+// CanUseNamespace reports whether the principal may make a request in
+// namespace ns, which is "" for a request outside any namespace. A
+// principal bound to namespaces can't leave them.
+func (p *Principal) CanUseNamespace(ns string) bool {
+	return len(p.Namespaces) == 0 || slices.Contains(p.Namespaces, ns)
+}
+
+// CanAccessKey reports whether the principal's key patterns allow key. The
+// key is the one stored in Valkey, so a request's namespace is part of it:
+// "orders:*" allows orders outside any namespace, and "acme:orders:*" those
+// in namespace acme.
+func (p *Principal) CanAccessKey(key string) bool {
+	if len(p.KeyPatterns) == 0 {
+		return true
+	}
+	for _, pattern := range p.KeyPatterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanPattern narrows a SCAN pattern to the principal's key pattern, so a
+// listing of "*" by a token limited to "orders:*" only walks orders and
+// doesn't return mostly empty pages. Narrowing is only possible with a single
+// key pattern; callers must still filter the results with CanAccessKey.
+func (p *Principal) ScanPattern(pattern string) string {
+	if len(p.KeyPatterns) != 1 {
+		return pattern
+	}
+	allowed := p.KeyPatterns[0]
+	if pattern == "*" {
+		return allowed
+	}
+	// Of two prefix patterns where one extends the other, the longer one
+	// matches exactly the keys both do
+	prefix, ok := globPrefix(pattern)
+	allowedPrefix, allowedOK := globPrefix(allowed)
+	if ok && allowedOK && strings.HasPrefix(allowedPrefix, prefix) {
+		return allowed
+	}
+	return pattern
+}
+
+// globPrefix returns the literal prefix of a pattern of the form "prefix*".
+func globPrefix(pattern string) (string, bool) {
+	prefix, ok := strings.CutSuffix(pattern, "*")
+	return prefix, ok && !strings.ContainsAny(prefix, `*?[\`)
+}
+
+// TokenConfig is one entry of the AUTH_TOKENS_FILE JSON array, or of the
+// auth.tokens list in the config file.
+type TokenConfig struct {
+	Name        string   `json:"name" yaml:"name" toml:"name"`
+	Token       string   `json:"token" yaml:"token" toml:"token"`
+	Role        string   `json:"role" yaml:"role" toml:"role"`
+	KeyPatterns []string `json:"key_patterns,omitempty" yaml:"key_patterns" toml:"key_patterns"`
+	Namespaces  []string `json:"namespaces,omitempty" yaml:"namespaces" toml:"namespaces"`
+}
+
+// TokenStore maps tokens to principals. Tokens are indexed by their SHA-256
+// digest so raw secrets aren't kept in memory longer than needed.
+type TokenStore struct {
+	tokens map[string]*Principal
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenStore builds the token set from the legacy single AUTH_TOKEN, which
+// is granted the admin role, the inline tokens of the config file and the
+// entries of an optional tokens file.
+func NewTokenStore(authToken string, tokens []TokenConfig, tokensFile string) (*TokenStore, error) {
+	store := &TokenStore{tokens: make(map[string]*Principal)}
+
+	if authToken != "" {
+		store.tokens[hashToken(authToken)] = &Principal{ID: "token:default", Name: "default", Role: RoleAdmin}
+	}
+
+	if err := store.add(tokens, "auth.tokens"); err != nil {
+		return nil, err
+	}
+
+	if tokensFile == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(tokensFile)
+	if err != nil {
+		return nil, fmt.Errorf("read tokens file: %w", err)
+	}
+
+	var entries []TokenConfig
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse tokens file: %w", err)
+	}
+
+	if err := store.add(entries, "tokens file entry"); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// add registers token entries, naming them in errors as "source i".
+func (ts *TokenStore) add(entries []TokenConfig, source string) error {
+	for i, entry := range entries {
+		if entry.Token == "" {
+			return fmt.Errorf("%s %d: token is required", source, i)
+		}
+		role, err := ParseRole(entry.Role)
+		if err != nil {
+			return fmt.Errorf("%s %d: %w", source, i, err)
+		}
+		for _, pattern := range entry.KeyPatterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s %d: invalid key pattern %q", source, i, pattern)
+			}
+		}
+		name := entry.Name
+		if name == "" {
+			name = fmt.Sprintf("token-%d", i)
+		}
+		ts.tokens[hashToken(entry.Token)] = &Principal{
+			ID:          "token:" + name,
+			Name:        name,
+			Role:        role,
+			KeyPatterns: entry.KeyPatterns,
+			Namespaces:  entry.Namespaces,
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether any tokens are configured.
+func (ts *TokenStore) Enabled() bool {
+	return ts != nil && len(ts.tokens) > 0
+}
+
+// Len returns the number of configured tokens.
+func (ts *TokenStore) Len() int {
+	return len(ts.tokens)
+}
+
+// Lookup returns the principal for a token, or nil if it is unknown.
+func (ts *TokenStore) Lookup(token string) *Principal {
+	return ts.tokens[hashToken(token)]
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the authenticated principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the authenticated principal, or nil when
+// authentication is disabled.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextKey{}).(*Principal)
+	return p
+}
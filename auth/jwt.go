@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig configures bearer JWT validation against an identity provider.
+type JWTConfig struct {
+	JWKSURL         string
+	Issuer          string
+	Audience        string
+	RoleClaim       string          // Claim holding the role (string or array of strings)
+	RoleMap         map[string]Role // Maps claim values such as group names to roles
+	NamespaceClaim  string          // Claim holding the namespaces the token is bound to
+	RefreshInterval time.Duration
+}
+
+// jwksMinRefresh limits how often an unknown key ID can trigger a refetch.
+const jwksMinRefresh = time.Minute
+
+// JWTVerifier validates RS256 and ES256 tokens using keys from a JWKS URL.
+type JWTVerifier struct {
+	config JWTConfig
+	client *http.Client
+	parser *jwt.Parser
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+// NewJWTVerifier fetches the key set once so misconfiguration fails at
+// startup, then keeps it fresh in the background until ctx is cancelled.
+func NewJWTVerifier(ctx context.Context, config JWTConfig) (*JWTVerifier, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+	if config.RoleClaim == "" {
+		config.RoleClaim = "role"
+	}
+	if config.NamespaceClaim == "" {
+		config.NamespaceClaim = "namespaces"
+	}
+
+	v := &JWTVerifier{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		parser: jwt.NewParser(opts...),
+		keys:   make(map[string]crypto.PublicKey),
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	if config.RefreshInterval > 0 {
+		go v.refreshLoop(ctx)
+	}
+	return v, nil
+}
+
+func (v *JWTVerifier) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(v.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.refresh(ctx); err != nil {
+				log.Printf("Failed to refresh JWKS: %v", err)
+			}
+		}
+	}
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *JWTVerifier) refresh(ctx context.Context) error {
+	// Stamped on every attempt, so a failing JWKS endpoint isn't refetched
+	// for each unknown key ID
+	v.mu.Lock()
+	v.lastRefresh = time.Now()
+	v.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.JWKSURL, nil)
+	if err != nil {
+		return fmt.Errorf("build JWKS request: %w", err)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: unexpected status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys we can't use rather than rejecting the whole set
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+func decodeBase64URLInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBase64URLInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URLInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBase64URLInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URLInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+// key returns the public key for a key ID, refetching the key set when the
+// ID is unknown in case the provider has rotated its keys.
+func (v *JWTVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	stale := time.Since(v.lastRefresh) > jwksMinRefresh
+	v.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if err := v.refresh(ctx); err != nil {
+			return nil, err
+		}
+		v.mu.RLock()
+		key, ok = v.keys[kid]
+		v.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// Verify validates a token and maps its claims to a principal.
+func (v *JWTVerifier) Verify(ctx context.Context, tokenString string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	role := v.roleFromClaims(claims)
+	if role == 0 {
+		return nil, errors.New("token does not grant a role")
+	}
+
+	name, _ := claims.GetSubject()
+	if name == "" {
+		name = "jwt"
+	}
+	return &Principal{ID: "jwt:" + name, Name: name, Role: role, Namespaces: claimValues(claims, v.config.NamespaceClaim)}, nil
+}
+
+// claimValues reads a claim that may be a single space-separated string or
+// an array of strings.
+func claimValues(claims jwt.MapClaims, claim string) []string {
+	var values []string
+	switch c := claims[claim].(type) {
+	case string:
+		values = strings.Fields(c)
+	case []interface{}:
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// roleFromClaims returns the highest role granted by the role claim, which
+// may be a single string or an array of strings. With a role map, only the
+// values it names grant a role; without one, values are role names.
+func (v *JWTVerifier) roleFromClaims(claims jwt.MapClaims) Role {
+	var best Role
+	for _, value := range claimValues(claims, v.config.RoleClaim) {
+		var role Role
+		if len(v.config.RoleMap) > 0 {
+			role = v.config.RoleMap[value]
+		} else {
+			var err error
+			if role, err = ParseRole(value); err != nil {
+				continue
+			}
+		}
+		if role > best {
+			best = role
+		}
+	}
+	return best
+}
+
+// ParseRoleMap parses "claim-value=role" pairs separated by commas, e.g.
+// "platform-admins=admin,viewers=read".
+func ParseRoleMap(s string) (map[string]Role, error) {
+	roles := make(map[string]Role)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		value, roleName, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid role mapping %q", pair)
+		}
+		role, err := ParseRole(strings.TrimSpace(roleName))
+		if err != nil {
+			return nil, err
+		}
+		roles[strings.TrimSpace(value)] = role
+	}
+	return roles, nil
+}
+
+// LooksLikeJWT distinguishes JWTs from static tokens, which never contain dots
+// when generated as recommended (openssl rand -hex 32).
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
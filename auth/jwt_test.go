@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestJWKS serves a key set holding one P-256 key with ID "k1" and
+// counts the fetches. Once failing is set, every fetch fails.
+func newTestJWKS(t *testing.T) (*ecdsa.PrivateKey, *httptest.Server, *atomic.Int64, *atomic.Bool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int64
+	var failing atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kid: "k1",
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	}))
+	t.Cleanup(srv.Close)
+	return key, srv, &fetches, &failing
+}
+
+func signTestJWT(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJWTRoleMap(t *testing.T) {
+	key, srv, _, _ := newTestJWKS(t)
+	ctx := context.Background()
+
+	mapped, err := NewJWTVerifier(ctx, JWTConfig{JWKSURL: srv.URL, RoleMap: map[string]Role{"viewers": RoleRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmapped, err := NewJWTVerifier(ctx, JWTConfig{JWKSURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		verifier *JWTVerifier
+		role     interface{}
+		want     Role // 0 when the token must be rejected
+	}{
+		{"mapped value", mapped, "viewers", RoleRead},
+		{"role name outside the map", mapped, "admin", 0},
+		{"highest mapped value", mapped, []interface{}{"admin", "viewers"}, RoleRead},
+		{"role name without a map", unmapped, "admin", RoleAdmin},
+		{"unknown role name", unmapped, "viewers", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.verifier.Verify(ctx, signTestJWT(t, key, "k1", jwt.MapClaims{"sub": "u", "role": tc.role}))
+			if tc.want == 0 {
+				if err == nil {
+					t.Errorf("Verify granted %v, want rejection", p.Role)
+				}
+				return
+			}
+			if err != nil || p.Role != tc.want {
+				t.Errorf("Verify = %v, %v; want role %v", p, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestJWTUnknownKeyRefetchLimited(t *testing.T) {
+	key, srv, fetches, failing := newTestJWKS(t)
+	ctx := context.Background()
+
+	v, err := NewJWTVerifier(ctx, JWTConfig{JWKSURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing.Store(true)
+	// Let the first unknown key ID find the key set stale
+	v.mu.Lock()
+	v.lastRefresh = time.Now().Add(-2 * jwksMinRefresh)
+	v.mu.Unlock()
+
+	token := signTestJWT(t, key, "rotated", jwt.MapClaims{"sub": "u", "role": "read"})
+	for range 5 {
+		if _, err := v.Verify(ctx, token); err == nil {
+			t.Fatal("Verify accepted an unknown key ID")
+		}
+	}
+	// One at startup and one failed refetch
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
@@ -0,0 +1,310 @@
+// Package bench drives synthetic GET and SET load against a running
+// valkey-rest server, for capacity planning.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Options configure a benchmark run.
+type Options struct {
+	Target      string // Base URL of the server, such as http://localhost:8080
+	Token       string // Bearer token with the write role; optional if auth is off
+	Duration    time.Duration
+	Concurrency int     // Requests in flight at once
+	Keys        int     // Distinct keys the load is spread over
+	ValueSize   int     // Bytes in each value written
+	ReadRatio   float64 // Share of requests that are GETs, 0 to 1
+	KeyPrefix   string
+	Cleanup     bool // Delete the keys afterwards
+}
+
+// Validate reports the first invalid option.
+func (o Options) Validate() error {
+	u, err := url.Parse(o.Target)
+	switch {
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		return errors.New("target must be an http or https URL")
+	case o.Duration <= 0:
+		return errors.New("duration must be positive")
+	case o.Concurrency <= 0:
+		return errors.New("concurrency must be positive")
+	case o.Keys <= 0:
+		return errors.New("keys must be positive")
+	case o.ValueSize < 0:
+		return errors.New("value size must not be negative")
+	case o.ReadRatio < 0 || o.ReadRatio > 1:
+		return errors.New("read ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// OpResult summarizes the requests of one operation.
+type OpResult struct {
+	Op        string
+	Requests  int
+	Errors    int            // Requests that failed or got an unexpected status
+	Statuses  map[string]int // Count of each failure: a status code or "transport"
+	latencies []time.Duration
+}
+
+// Percentile returns the latency below which p (0 to 100) percent of the
+// successful requests finished.
+func (r *OpResult) Percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.latencies)-1) * p / 100)
+	return r.latencies[i]
+}
+
+func (r *OpResult) record(latency time.Duration, failure string) {
+	r.Requests++
+	if failure != "" {
+		r.Errors++
+		r.Statuses[failure]++
+		return
+	}
+	r.latencies = append(r.latencies, latency)
+}
+
+func (r *OpResult) merge(other *OpResult) {
+	r.Requests += other.Requests
+	r.Errors += other.Errors
+	for status, n := range other.Statuses {
+		r.Statuses[status] += n
+	}
+	r.latencies = append(r.latencies, other.latencies...)
+}
+
+// Result is the outcome of a benchmark run.
+type Result struct {
+	Elapsed time.Duration
+	Ops     []*OpResult // GET, then SET
+}
+
+// runner holds the state shared by a run's workers.
+type runner struct {
+	opts   Options
+	client *http.Client
+	value  []byte // JSON body of every SET
+}
+
+// Run writes every key once so reads hit, then sends a mix of GETs and SETs
+// from opts.Concurrency workers until opts.Duration has passed or ctx ends.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	opts.Target = strings.TrimRight(opts.Target, "/")
+	value, _ := json.Marshal(map[string]string{"value": strings.Repeat("x", opts.ValueSize)})
+	r := &runner{
+		opts:  opts,
+		value: value,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{MaxIdleConnsPerHost: opts.Concurrency},
+		},
+	}
+	defer r.client.CloseIdleConnections()
+
+	if err := r.eachKey(ctx, r.set); err != nil {
+		return nil, fmt.Errorf("write keys: %w", err)
+	}
+	if opts.Cleanup {
+		defer r.eachKey(context.WithoutCancel(ctx), r.del)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	results := make([][2]*OpResult, opts.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range results {
+		results[i] = [2]*OpResult{newOpResult("GET"), newOpResult("SET")}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, results[i][0], results[i][1])
+		}()
+	}
+	wg.Wait()
+
+	res := &Result{Elapsed: time.Since(start), Ops: []*OpResult{newOpResult("GET"), newOpResult("SET")}}
+	for _, worker := range results {
+		res.Ops[0].merge(worker[0])
+		res.Ops[1].merge(worker[1])
+	}
+	for _, op := range res.Ops {
+		slices.Sort(op.latencies)
+	}
+	return res, nil
+}
+
+func newOpResult(op string) *OpResult {
+	return &OpResult{Op: op, Statuses: make(map[string]int)}
+}
+
+// work sends requests until ctx ends. A request cut off by the end of the
+// run isn't counted.
+func (r *runner) work(ctx context.Context, gets, sets *OpResult) {
+	for ctx.Err() == nil {
+		key := r.key(rand.IntN(r.opts.Keys))
+		op, result := r.get, gets
+		if rand.Float64() >= r.opts.ReadRatio {
+			op, result = r.set, sets
+		}
+
+		start := time.Now()
+		err := op(ctx, key)
+		if ctx.Err() != nil {
+			return
+		}
+		result.record(time.Since(start), failure(err))
+	}
+}
+
+// statusError is a response with an unexpected status.
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", int(e))
+}
+
+// failure names an error for OpResult.Statuses, or returns "" for nil.
+func failure(err error) string {
+	var status statusError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &status):
+		return strconv.Itoa(int(status))
+	}
+	return "transport"
+}
+
+func (r *runner) key(i int) string {
+	return r.opts.KeyPrefix + strconv.Itoa(i)
+}
+
+// eachKey applies op to every key with opts.Concurrency workers, stopping at
+// the first error.
+func (r *runner) eachKey(ctx context.Context, op func(context.Context, string) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	keys := make(chan string)
+	var wg sync.WaitGroup
+	for range r.opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keys {
+				if err := op(ctx, key); err != nil {
+					cancel(fmt.Errorf("%s: %w", key, err))
+				}
+			}
+		}()
+	}
+	for i := 0; i < r.opts.Keys && ctx.Err() == nil; i++ {
+		select {
+		case keys <- r.key(i):
+		case <-ctx.Done():
+		}
+	}
+	close(keys)
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+func (r *runner) get(ctx context.Context, key string) error {
+	return r.do(ctx, http.MethodGet, key, nil, http.StatusOK)
+}
+
+func (r *runner) set(ctx context.Context, key string) error {
+	return r.do(ctx, http.MethodPost, key, r.value, http.StatusCreated)
+}
+
+// del deletes a key, which may already be gone.
+func (r *runner) del(ctx context.Context, key string) error {
+	err := r.do(ctx, http.MethodDelete, key, nil, http.StatusOK)
+	if errors.Is(err, statusError(http.StatusNotFound)) {
+		return nil
+	}
+	return err
+}
+
+func (r *runner) do(ctx context.Context, method, key string, body []byte, want int) error {
+	req, err := http.NewRequestWithContext(ctx, method, r.opts.Target+"/keys/"+url.PathEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.opts.Token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Read the body so the connection is reused
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != want {
+		return statusError(resp.StatusCode)
+	}
+	return nil
+}
+
+// Report writes a table of the result's throughput and latencies.
+func (res *Result) Report(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\trequests\terrors\treq/s\tp50\tp90\tp99\tmax\t")
+	for _, op := range res.Ops {
+		if op.Requests == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f\t%s\t%s\t%s\t%s\t\n",
+			op.Op, op.Requests, op.Errors, float64(op.Requests)/res.Elapsed.Seconds(),
+			round(op.Percentile(50)), round(op.Percentile(90)), round(op.Percentile(99)), round(op.Percentile(100)))
+	}
+	tw.Flush()
+
+	for _, op := range res.Ops {
+		if op.Errors == 0 {
+			continue
+		}
+		failures := make([]string, 0, len(op.Statuses))
+		for status, n := range op.Statuses {
+			failures = append(failures, fmt.Sprintf("%s x%d", status, n))
+		}
+		slices.Sort(failures)
+		fmt.Fprintf(w, "%s failures: %s\n", op.Op, strings.Join(failures, ", "))
+	}
+}
+
+// round trims a latency to three significant digits or so for display.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
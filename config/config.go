@@ -0,0 +1,487 @@
+// Package config reads the proxy settings from an optional config file and
+// the environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"valkey-rest/auth"
+)
+
+const (
+	// defaultMaxBodyBytes is the request body limit used when MAX_BODY_BYTES is unset.
+	defaultMaxBodyBytes = 1 << 20
+	// defaultMaxImportBytes is the body limit for /import when MAX_IMPORT_BYTES is unset.
+	defaultMaxImportBytes = 64 << 20
+	// internalKeyPrefix is handlers.InternalKeyPrefix, which clients can't
+	// reach through the key endpoints.
+	internalKeyPrefix = "valkey-rest:"
+)
+
+// defaultCommandDeny is used when COMMAND_DENY is unset. It covers commands
+// that destroy data, reconfigure or stop the server.
+const defaultCommandDeny = "FLUSHALL,FLUSHDB,SHUTDOWN,DEBUG,CONFIG SET,CONFIG REWRITE,CONFIG RESETSTAT," +
+	"MODULE,ACL,REPLICAOF,SLAVEOF,FAILOVER,CLUSTER RESET,CLUSTER FAILOVER,SCRIPT FLUSH,FUNCTION FLUSH"
+
+// Config holds every setting of the proxy. Load reads it from a config file
+// and the environment; embedders can start from Default and fill it in
+// directly.
+type Config struct {
+	File                        string // Config file Load read, if any
+	Port                        string
+	GRPCPort                    string
+	Listen                      string // Comma-separated host:port or unix:/path addresses; replaces Port when set
+	AdminListen                 string // Addresses that also serve admin routes, which other listeners then refuse
+	DebugListen                 string // Addresses serving pprof and expvar; off when empty
+	Backend                     string
+	ValkeyAddress               string
+	ValkeyPassword              string
+	ValkeyDB                    int64  // Logical database selected on connect
+	ValkeyDBAllow               string // Comma-separated databases requests may select with ?db=
+	ValkeyTLS                   bool
+	ValkeyTLSCAFile             string
+	ValkeyTLSCertFile           string
+	ValkeyTLSKeyFile            string
+	ValkeyTLSServerName         string
+	ValkeyTLSInsecure           bool
+	ReadFromReplicas            bool
+	ReplicaAddresses            string
+	SentinelMaster              string
+	SentinelAddresses           string
+	SentinelPassword            string
+	MigrationAddress            string // Secondary Valkey mirrored by the /keys endpoints; off when empty
+	MigrationPassword           string
+	MigrationMode               string // dual_write or read_fallback
+	AuthToken                   string
+	AuthTokensFile              string
+	Tokens                      []auth.TokenConfig // Inline tokens from the config file
+	JWT                         auth.JWTConfig
+	RateLimitPerIP              int64
+	RateLimitPerToken           int64
+	RateLimitPeriod             time.Duration
+	UsageTracking               bool          // Count each token's requests and bytes per month in Valkey
+	UsageMonthlyRequests        int64         // Requests each token may make per month; 0 for no quota
+	UsageMonthlyBytes           int64         // Bytes each token may send and receive per month; 0 for no quota
+	MaxInFlight                 int           // Requests handled at once; 0 for no limit
+	MaxInFlightPerToken         int           // Requests handled at once for each token; 0 for no limit
+	MaxQueue                    int           // Requests that may wait for a slot once a limit is reached
+	QueueTimeout                time.Duration // How long a request waits for a slot
+	MaxBodyBytes                int64
+	MaxImportBytes              int64
+	ValueCompression            string
+	ValueCompressionThreshold   int
+	ScriptsDir                  string
+	CommandAllow                string
+	CommandDeny                 string
+	WebhooksEnabled             bool
+	SchedulerEnabled            bool          // Run due scheduled jobs from this instance
+	ScheduleKey                 string        // Sorted set of scheduled jobs
+	SchedulerInterval           time.Duration // How often the schedule is checked
+	SchedulerCallbackURL        string
+	SchedulerCallbackSecret     string
+	DocsEnabled                 bool
+	ReadOnly                    bool // Refuse mutating endpoints
+	OTLPEndpoint                string
+	ServiceName                 string
+	LogLevel                    string
+	LogFormat                   string
+	TLSCertFile                 string
+	TLSKeyFile                  string
+	TLSClientCAFile             string
+	CommandTimeout              time.Duration // Default limit for the Valkey commands of a request
+	MaxCommandTimeout           time.Duration // Cap on timeouts requested with X-Timeout-Ms
+	CacheSize                   int           // Values kept by the GET cache; 0 disables it
+	CacheTTL                    time.Duration
+	CacheStale                  time.Duration // Stale-while-revalidate window after CacheTTL
+	IdempotencyTTL              time.Duration // How long responses are kept for Idempotency-Key replays
+	KeyVersions                 int           // Versions kept per key written with versioned: true
+	SessionTTL                  time.Duration // Idle time after which a session expires
+	CommandRetries              int           // Retries of read-only commands after connection errors
+	CommandRetryBackoff         time.Duration
+	ReadyTimeout                time.Duration // Limit for the /readyz checks
+	ReadyCheckLoading           bool
+	ReadyCheckReplication       bool
+	CircuitBreakerThreshold     int // Consecutive failures that open the circuit; 0 disables it
+	CircuitBreakerProbeInterval time.Duration
+	CORSAllowedOrigins          string // Comma-separated; CORS is off when empty
+	CORSAllowedMethods          string
+	CORSAllowedHeaders          string
+	CORSAllowCredentials        bool
+	CORSMaxAge                  time.Duration // How long browsers may cache a preflight
+	IPAllow                     string        // Comma-separated CIDRs; every client is allowed when empty
+	IPDeny                      string
+	AdminIPAllow                string // CIDRs allowed to reach admin routes; unrestricted when empty
+	TrustedProxies              string // CIDRs of proxies whose X-Forwarded-For is used
+	AuditLog                    string // Audit sink: stdout, file or stream; off when empty
+	AuditFile                   string
+	AuditStream                 string
+	AuditStreamMaxLen           int64  // Approximate cap on the audit stream's length
+	AuditSecret                 string // HMAC key for the audit hash chain
+	ReadTimeout                 time.Duration
+	WriteTimeout                time.Duration
+	IdleTimeout                 time.Duration
+	ShutdownDelay               time.Duration // How long /readyz reports unready before listeners close
+	ShutdownTimeout             time.Duration // How long shutdown waits for requests in flight
+}
+
+// Default returns the configuration used for anything not set in the config
+// file or the environment.
+func Default() *Config {
+	return &Config{
+		Port:          "8080",
+		Backend:       "valkey",
+		ValkeyAddress: "localhost:6379",
+		JWT: auth.JWTConfig{
+			RefreshInterval: time.Hour,
+		},
+		// Rate limits are requests per RateLimitPeriod; 0 disables a limit
+		RateLimitPeriod: time.Minute,
+		MaxQueue:        100,
+		QueueTimeout:    time.Second,
+		MaxBodyBytes:    defaultMaxBodyBytes,
+		MaxImportBytes:  defaultMaxImportBytes,
+		// Values at least this many bytes long are compressed when ValueCompression is set
+		ValueCompressionThreshold:   1024,
+		CommandDeny:                 defaultCommandDeny,
+		ServiceName:                 "valkey-rest",
+		LogLevel:                    "info",
+		LogFormat:                   "json",
+		CommandTimeout:              5 * time.Second,
+		MaxCommandTimeout:           60 * time.Second,
+		CacheTTL:                    time.Second,
+		IdempotencyTTL:              24 * time.Hour,
+		KeyVersions:                 10,
+		SessionTTL:                  30 * time.Minute,
+		MigrationMode:               "dual_write",
+		ScheduleKey:                 "valkey-rest:scheduled",
+		SchedulerInterval:           time.Second,
+		CommandRetryBackoff:         50 * time.Millisecond,
+		ReadyTimeout:                2 * time.Second,
+		CircuitBreakerThreshold:     5,
+		CircuitBreakerProbeInterval: 5 * time.Second,
+		CORSAllowedMethods:          "GET,HEAD,POST,PUT,PATCH,DELETE",
+		CORSAllowedHeaders:          "Authorization,Content-Type,Idempotency-Key,If-Match,If-None-Match,X-Namespace,X-Timeout-Ms",
+		CORSMaxAge:                  10 * time.Minute,
+		AuditStream:                 "valkey-rest:audit",
+		AuditStreamMaxLen:           1000000,
+		ReadTimeout:                 10 * time.Second,
+		WriteTimeout:                10 * time.Second,
+		IdleTimeout:                 120 * time.Second,
+		ShutdownTimeout:             30 * time.Second,
+	}
+}
+
+// Load builds the configuration from the defaults, then the config file at
+// path if one is given, then environment variables, so the environment wins
+// over the file. The result is validated and every problem found is
+// reported, naming the file field and environment variable it came from.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	cfg.File = path
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// fieldError reports an invalid setting by its config file field and
+// environment variable.
+func fieldError(field, env, format string, args ...interface{}) error {
+	return fmt.Errorf("%s (%s): %s", field, env, fmt.Sprintf(format, args...))
+}
+
+// SplitList splits a comma-separated setting into its items, trimming
+// spaces and dropping empty items.
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validPort reports whether port is a TCP port number.
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// validListenAddress reports whether addr is a host:port TCP address or a
+// unix:/path socket.
+func validListenAddress(addr string) bool {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return path != ""
+	}
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && validPort(port)
+}
+
+// Validate checks for settings the server can't start with.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if !validPort(c.Port) {
+		errs = append(errs, fieldError("api.port", "PORT", "%q is not a valid port", c.Port))
+	}
+	if c.GRPCPort != "" {
+		if !validPort(c.GRPCPort) {
+			errs = append(errs, fieldError("api.grpc_port", "GRPC_PORT", "%q is not a valid port", c.GRPCPort))
+		} else if c.GRPCPort == c.Port {
+			errs = append(errs, fieldError("api.grpc_port", "GRPC_PORT", "must differ from the HTTP port"))
+		}
+	}
+
+	for _, list := range []struct{ field, env, value string }{
+		{"api.listen", "LISTEN", c.Listen},
+		{"api.admin_listen", "ADMIN_LISTEN", c.AdminListen},
+		{"api.debug_listen", "DEBUG_LISTEN", c.DebugListen},
+	} {
+		for _, addr := range SplitList(list.value) {
+			if !validListenAddress(addr) {
+				errs = append(errs, fieldError(list.field, list.env, "%q must be host:port or unix:/path/to/socket", addr))
+			}
+		}
+	}
+
+	if c.ValkeyDB < 0 {
+		errs = append(errs, fieldError("valkey.db", "VALKEY_DB", "must not be negative"))
+	}
+	for _, db := range SplitList(c.ValkeyDBAllow) {
+		if n, err := strconv.ParseInt(db, 10, 64); err != nil || n < 0 {
+			errs = append(errs, fieldError("valkey.db_allow", "VALKEY_DB_ALLOW", "%q is not a database number", db))
+		}
+	}
+
+	switch c.Backend {
+	case "valkey", "memory":
+	default:
+		errs = append(errs, fieldError("api.backend", "BACKEND", "must be valkey or memory, got %q", c.Backend))
+	}
+	if c.Backend != "memory" && strings.TrimSpace(c.ValkeyAddress) == "" && c.SentinelMaster == "" {
+		errs = append(errs, fieldError("valkey.address", "VALKEY_ADDRESS", "is required"))
+	}
+	if c.SentinelMaster != "" && strings.TrimSpace(c.SentinelAddresses) == "" {
+		errs = append(errs, fieldError("valkey.sentinel.addresses", "VALKEY_SENTINEL_ADDRESSES", "is required when the sentinel master is set"))
+	}
+	if (c.ValkeyTLSCertFile == "") != (c.ValkeyTLSKeyFile == "") {
+		errs = append(errs, fieldError("valkey.tls.cert_file", "VALKEY_TLS_CERT_FILE", "must be set together with the key file"))
+	}
+	switch c.MigrationMode {
+	case "dual_write", "read_fallback":
+	default:
+		errs = append(errs, fieldError("migration.mode", "MIGRATION_MODE", "must be dual_write or read_fallback, got %q", c.MigrationMode))
+	}
+	if c.MigrationAddress != "" && c.Backend == "memory" {
+		errs = append(errs, fieldError("migration.secondary_address", "MIGRATION_SECONDARY_ADDRESS", "requires the valkey backend"))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, fieldError("tls.cert_file", "TLS_CERT_FILE", "must be set together with the key file"))
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		errs = append(errs, fieldError("tls.client_ca_file", "TLS_CLIENT_CA_FILE", "requires a server certificate and key"))
+	}
+
+	for i, token := range c.Tokens {
+		if token.Token == "" {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d].token: is required", i))
+		}
+		if _, err := auth.ParseRole(token.Role); err != nil {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d].role: %v", i, err))
+		}
+	}
+	if c.JWT.RefreshInterval <= 0 {
+		errs = append(errs, fieldError("auth.jwt.refresh_interval", "JWT_JWKS_REFRESH_INTERVAL", "must be positive"))
+	}
+
+	if c.RateLimitPerIP < 0 {
+		errs = append(errs, fieldError("rate_limit.per_ip", "RATE_LIMIT_PER_IP", "must not be negative"))
+	}
+	if c.RateLimitPerToken < 0 {
+		errs = append(errs, fieldError("rate_limit.per_token", "RATE_LIMIT_PER_TOKEN", "must not be negative"))
+	}
+	if c.RateLimitPeriod <= 0 {
+		errs = append(errs, fieldError("rate_limit.period", "RATE_LIMIT_PERIOD", "must be positive"))
+	}
+
+	if c.UsageMonthlyRequests < 0 {
+		errs = append(errs, fieldError("usage.monthly_requests", "USAGE_MONTHLY_REQUESTS", "must not be negative"))
+	}
+	if c.UsageMonthlyBytes < 0 {
+		errs = append(errs, fieldError("usage.monthly_bytes", "USAGE_MONTHLY_BYTES", "must not be negative"))
+	}
+	if (c.UsageMonthlyRequests > 0 || c.UsageMonthlyBytes > 0) && !c.UsageTracking {
+		errs = append(errs, fieldError("usage.enabled", "USAGE_TRACKING", "must be true when a monthly quota is set"))
+	}
+
+	if c.MaxInFlight < 0 {
+		errs = append(errs, fieldError("concurrency.max_in_flight", "MAX_IN_FLIGHT", "must not be negative"))
+	}
+	if c.MaxInFlightPerToken < 0 {
+		errs = append(errs, fieldError("concurrency.max_in_flight_per_token", "MAX_IN_FLIGHT_PER_TOKEN", "must not be negative"))
+	}
+	if c.MaxQueue < 0 {
+		errs = append(errs, fieldError("concurrency.max_queue", "MAX_QUEUE", "must not be negative"))
+	}
+	if c.QueueTimeout < 0 {
+		errs = append(errs, fieldError("concurrency.queue_timeout", "QUEUE_TIMEOUT", "must not be negative"))
+	}
+
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, fieldError("api.max_body_bytes", "MAX_BODY_BYTES", "must be positive"))
+	}
+	if c.MaxImportBytes <= 0 {
+		errs = append(errs, fieldError("api.max_import_bytes", "MAX_IMPORT_BYTES", "must be positive"))
+	}
+	if c.CommandTimeout <= 0 {
+		errs = append(errs, fieldError("api.command_timeout", "COMMAND_TIMEOUT", "must be positive"))
+	}
+	if c.MaxCommandTimeout < c.CommandTimeout {
+		errs = append(errs, fieldError("api.max_command_timeout", "MAX_COMMAND_TIMEOUT", "must be at least the command timeout"))
+	}
+	if c.CacheSize < 0 {
+		errs = append(errs, fieldError("cache.size", "CACHE_SIZE", "must not be negative"))
+	}
+	if c.CacheTTL <= 0 {
+		errs = append(errs, fieldError("cache.ttl", "CACHE_TTL", "must be positive"))
+	}
+	if c.CacheStale < 0 {
+		errs = append(errs, fieldError("cache.stale", "CACHE_STALE", "must not be negative"))
+	}
+	if c.IdempotencyTTL <= 0 {
+		errs = append(errs, fieldError("api.idempotency_ttl", "IDEMPOTENCY_TTL", "must be positive"))
+	}
+	if c.KeyVersions < 1 || c.KeyVersions > 1000 {
+		errs = append(errs, fieldError("api.key_versions", "KEY_VERSIONS", "must be between 1 and 1000"))
+	}
+	if c.SessionTTL < time.Second {
+		errs = append(errs, fieldError("api.session_ttl", "SESSION_TTL", "must be at least 1s"))
+	}
+	if strings.TrimSpace(c.ScheduleKey) == "" {
+		errs = append(errs, fieldError("scheduler.key", "SCHEDULER_KEY", "must not be empty"))
+	}
+	if c.SchedulerInterval <= 0 {
+		errs = append(errs, fieldError("scheduler.interval", "SCHEDULER_INTERVAL", "must be positive"))
+	}
+	if c.SchedulerCallbackURL != "" {
+		if u, err := url.Parse(c.SchedulerCallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fieldError("scheduler.callback_url", "SCHEDULER_CALLBACK_URL", "must be an absolute http or https URL"))
+		}
+	}
+	if c.CommandRetries < 0 {
+		errs = append(errs, fieldError("valkey.retries", "VALKEY_RETRIES", "must not be negative"))
+	}
+	if c.CommandRetryBackoff <= 0 {
+		errs = append(errs, fieldError("valkey.retry_backoff", "VALKEY_RETRY_BACKOFF", "must be positive"))
+	}
+	if c.ReadyTimeout <= 0 {
+		errs = append(errs, fieldError("probes.ready_timeout", "READY_TIMEOUT", "must be positive"))
+	}
+	if c.CircuitBreakerThreshold < 0 {
+		errs = append(errs, fieldError("circuit_breaker.threshold", "CIRCUIT_BREAKER_THRESHOLD", "must not be negative"))
+	}
+	if c.CircuitBreakerProbeInterval <= 0 {
+		errs = append(errs, fieldError("circuit_breaker.probe_interval", "CIRCUIT_BREAKER_PROBE_INTERVAL", "must be positive"))
+	}
+	// The HTTP server timeouts can only be set in the config file
+	if c.ReadTimeout <= 0 {
+		errs = append(errs, errors.New("api.read_timeout: must be positive"))
+	}
+	if c.WriteTimeout <= 0 {
+		errs = append(errs, errors.New("api.write_timeout: must be positive"))
+	}
+	if c.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("api.idle_timeout: must be positive"))
+	}
+	if c.ShutdownDelay < 0 {
+		errs = append(errs, fieldError("api.shutdown_delay", "SHUTDOWN_DELAY", "must not be negative"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fieldError("api.shutdown_timeout", "SHUTDOWN_TIMEOUT", "must be positive"))
+	}
+
+	for _, origin := range SplitList(c.CORSAllowedOrigins) {
+		if origin == "*" && c.CORSAllowCredentials {
+			errs = append(errs, fieldError("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "* can't be combined with allow_credentials; list the origins"))
+		} else if origin != "*" && !strings.Contains(origin, "://") {
+			errs = append(errs, fieldError("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "%q must include the scheme, such as https://app.example.com", origin))
+		}
+	}
+	if c.CORSMaxAge < 0 {
+		errs = append(errs, fieldError("cors.max_age", "CORS_MAX_AGE", "must not be negative"))
+	}
+
+	for _, list := range []struct{ field, env, value string }{
+		{"ip_filter.allow", "IP_ALLOW", c.IPAllow},
+		{"ip_filter.deny", "IP_DENY", c.IPDeny},
+		{"ip_filter.admin_allow", "ADMIN_IP_ALLOW", c.AdminIPAllow},
+		{"ip_filter.trusted_proxies", "TRUSTED_PROXIES", c.TrustedProxies},
+	} {
+		for _, item := range SplitList(list.value) {
+			if _, err := netip.ParsePrefix(item); err == nil {
+				continue
+			}
+			if _, err := netip.ParseAddr(item); err != nil {
+				errs = append(errs, fieldError(list.field, list.env, "%q is not an IP address or CIDR range", item))
+			}
+		}
+	}
+
+	switch c.AuditLog {
+	case "", "stdout", "stream":
+	case "file":
+		if c.AuditFile == "" {
+			errs = append(errs, fieldError("audit.file", "AUDIT_FILE", "is required when the audit sink is file"))
+		}
+	default:
+		errs = append(errs, fieldError("audit.sink", "AUDIT_LOG", "must be stdout, file or stream, got %q", c.AuditLog))
+	}
+	if c.AuditLog == "stream" && c.AuditStream == "" {
+		errs = append(errs, fieldError("audit.stream", "AUDIT_STREAM", "is required when the audit sink is stream"))
+	} else if c.AuditLog == "stream" && !strings.HasPrefix(c.AuditStream, internalKeyPrefix) {
+		// Anywhere else, a write token could delete the trail
+		errs = append(errs, fieldError("audit.stream", "AUDIT_STREAM", "must start with %q", internalKeyPrefix))
+	}
+	if c.AuditStreamMaxLen < 0 {
+		errs = append(errs, fieldError("audit.max_len", "AUDIT_STREAM_MAXLEN", "must not be negative"))
+	}
+
+	switch strings.ToLower(c.ValueCompression) {
+	case "", "none", "gzip", "zstd":
+	default:
+		errs = append(errs, fieldError("compression.algorithm", "VALUE_COMPRESSION", "must be none, gzip or zstd, got %q", c.ValueCompression))
+	}
+	if c.ValueCompressionThreshold < 0 {
+		errs = append(errs, fieldError("compression.threshold", "VALUE_COMPRESSION_THRESHOLD", "must not be negative"))
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		errs = append(errs, fieldError("logging.level", "LOG_LEVEL", "must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+	switch strings.ToLower(c.LogFormat) {
+	case "json", "pretty":
+	default:
+		errs = append(errs, fieldError("logging.format", "LOG_FORMAT", "must be json or pretty, got %q", c.LogFormat))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,16 @@
+package config
+
+import "testing"
+
+func TestAuditStreamMustBeReserved(t *testing.T) {
+	cfg := Default()
+	cfg.AuditLog = "stream"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default audit stream rejected: %v", err)
+	}
+
+	cfg.AuditStream = "audit"
+	if err := cfg.Validate(); err == nil {
+		t.Error("audit stream outside valkey-rest: accepted")
+	}
+}
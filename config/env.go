@@ -0,0 +1,204 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"valkey-rest/auth"
+)
+
+// envReader copies set environment variables into the configuration,
+// collecting parse errors so they can all be reported at once. Variables
+// that are unset or empty leave the current value alone.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) string(name string, dst *string) {
+	if v := os.Getenv(name); v != "" {
+		*dst = v
+	}
+}
+
+func (e *envReader) bool(name string, dst *bool) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a boolean", name, v))
+		return
+	}
+	*dst = b
+}
+
+func (e *envReader) int(name string, dst *int) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", name, v))
+		return
+	}
+	*dst = n
+}
+
+func (e *envReader) int64(name string, dst *int64) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", name, v))
+		return
+	}
+	*dst = n
+}
+
+func (e *envReader) duration(name string, dst *time.Duration) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a duration such as 30s or 5m", name, v))
+		return
+	}
+	*dst = d
+}
+
+// loadEnv overrides cfg with the environment variables that are set.
+func loadEnv(cfg *Config) error {
+	var e envReader
+
+	e.string("PORT", &cfg.Port)
+	e.string("GRPC_PORT", &cfg.GRPCPort)
+	e.string("LISTEN", &cfg.Listen)
+	e.string("ADMIN_LISTEN", &cfg.AdminListen)
+	e.string("DEBUG_LISTEN", &cfg.DebugListen)
+	// "memory" keeps keys in process instead of connecting to Valkey
+	e.string("BACKEND", &cfg.Backend)
+	e.bool("DOCS_ENABLED", &cfg.DocsEnabled)
+	e.bool("READ_ONLY", &cfg.ReadOnly)
+	e.int64("MAX_BODY_BYTES", &cfg.MaxBodyBytes)
+	e.int64("MAX_IMPORT_BYTES", &cfg.MaxImportBytes)
+	e.duration("COMMAND_TIMEOUT", &cfg.CommandTimeout)
+	e.duration("MAX_COMMAND_TIMEOUT", &cfg.MaxCommandTimeout)
+	e.duration("IDEMPOTENCY_TTL", &cfg.IdempotencyTTL)
+	e.int("KEY_VERSIONS", &cfg.KeyVersions)
+	e.duration("SESSION_TTL", &cfg.SessionTTL)
+	e.int("CACHE_SIZE", &cfg.CacheSize)
+	e.duration("CACHE_TTL", &cfg.CacheTTL)
+	e.duration("CACHE_STALE", &cfg.CacheStale)
+	e.duration("SHUTDOWN_DELAY", &cfg.ShutdownDelay)
+	e.duration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+
+	e.duration("READY_TIMEOUT", &cfg.ReadyTimeout)
+	e.bool("READY_CHECK_LOADING", &cfg.ReadyCheckLoading)
+	e.bool("READY_CHECK_REPLICATION", &cfg.ReadyCheckReplication)
+	e.int("CIRCUIT_BREAKER_THRESHOLD", &cfg.CircuitBreakerThreshold)
+	e.duration("CIRCUIT_BREAKER_PROBE_INTERVAL", &cfg.CircuitBreakerProbeInterval)
+
+	e.string("VALKEY_ADDRESS", &cfg.ValkeyAddress)
+	e.string("VALKEY_PASSWORD", &cfg.ValkeyPassword)
+	e.int64("VALKEY_DB", &cfg.ValkeyDB)
+	e.string("VALKEY_DB_ALLOW", &cfg.ValkeyDBAllow)
+	e.bool("VALKEY_TLS", &cfg.ValkeyTLS)
+	e.string("VALKEY_TLS_CA_FILE", &cfg.ValkeyTLSCAFile)
+	e.string("VALKEY_TLS_CERT_FILE", &cfg.ValkeyTLSCertFile)
+	e.string("VALKEY_TLS_KEY_FILE", &cfg.ValkeyTLSKeyFile)
+	e.string("VALKEY_TLS_SERVER_NAME", &cfg.ValkeyTLSServerName)
+	e.bool("VALKEY_TLS_INSECURE_SKIP_VERIFY", &cfg.ValkeyTLSInsecure)
+	e.int("VALKEY_RETRIES", &cfg.CommandRetries)
+	e.duration("VALKEY_RETRY_BACKOFF", &cfg.CommandRetryBackoff)
+	e.bool("VALKEY_READ_FROM_REPLICAS", &cfg.ReadFromReplicas)
+	e.string("VALKEY_REPLICA_ADDRESSES", &cfg.ReplicaAddresses)
+	e.string("VALKEY_SENTINEL_MASTER", &cfg.SentinelMaster)
+	e.string("VALKEY_SENTINEL_ADDRESSES", &cfg.SentinelAddresses)
+	e.string("VALKEY_SENTINEL_PASSWORD", &cfg.SentinelPassword)
+	e.string("MIGRATION_SECONDARY_ADDRESS", &cfg.MigrationAddress)
+	e.string("MIGRATION_SECONDARY_PASSWORD", &cfg.MigrationPassword)
+	e.string("MIGRATION_MODE", &cfg.MigrationMode)
+
+	// HTTPS is served when both a certificate and key are configured
+	e.string("TLS_CERT_FILE", &cfg.TLSCertFile)
+	e.string("TLS_KEY_FILE", &cfg.TLSKeyFile)
+	e.string("TLS_CLIENT_CA_FILE", &cfg.TLSClientCAFile)
+
+	e.string("AUTH_TOKEN", &cfg.AuthToken)
+	e.string("AUTH_TOKENS_FILE", &cfg.AuthTokensFile)
+	e.string("JWT_JWKS_URL", &cfg.JWT.JWKSURL)
+	e.string("JWT_ISSUER", &cfg.JWT.Issuer)
+	e.string("JWT_AUDIENCE", &cfg.JWT.Audience)
+	e.string("JWT_ROLE_CLAIM", &cfg.JWT.RoleClaim)
+	e.string("JWT_NAMESPACE_CLAIM", &cfg.JWT.NamespaceClaim)
+	e.duration("JWT_JWKS_REFRESH_INTERVAL", &cfg.JWT.RefreshInterval)
+	if v := os.Getenv("JWT_ROLE_MAP"); v != "" {
+		roleMap, err := auth.ParseRoleMap(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("JWT_ROLE_MAP: %w", err))
+		} else {
+			cfg.JWT.RoleMap = roleMap
+		}
+	}
+
+	e.int64("RATE_LIMIT_PER_IP", &cfg.RateLimitPerIP)
+	e.int64("RATE_LIMIT_PER_TOKEN", &cfg.RateLimitPerToken)
+	e.duration("RATE_LIMIT_PERIOD", &cfg.RateLimitPeriod)
+	e.bool("USAGE_TRACKING", &cfg.UsageTracking)
+	e.int64("USAGE_MONTHLY_REQUESTS", &cfg.UsageMonthlyRequests)
+	e.int64("USAGE_MONTHLY_BYTES", &cfg.UsageMonthlyBytes)
+	e.int("MAX_IN_FLIGHT", &cfg.MaxInFlight)
+	e.int("MAX_IN_FLIGHT_PER_TOKEN", &cfg.MaxInFlightPerToken)
+	e.int("MAX_QUEUE", &cfg.MaxQueue)
+	e.duration("QUEUE_TIMEOUT", &cfg.QueueTimeout)
+
+	e.string("VALUE_COMPRESSION", &cfg.ValueCompression)
+	e.int("VALUE_COMPRESSION_THRESHOLD", &cfg.ValueCompressionThreshold)
+	e.string("SCRIPTS_DIR", &cfg.ScriptsDir)
+	e.string("COMMAND_ALLOW", &cfg.CommandAllow)
+	// An empty COMMAND_DENY disables the deny list, so it counts as set
+	if v, ok := os.LookupEnv("COMMAND_DENY"); ok {
+		cfg.CommandDeny = v
+	}
+	e.bool("WEBHOOKS_ENABLED", &cfg.WebhooksEnabled)
+	e.bool("SCHEDULER_ENABLED", &cfg.SchedulerEnabled)
+	e.string("SCHEDULER_KEY", &cfg.ScheduleKey)
+	e.duration("SCHEDULER_INTERVAL", &cfg.SchedulerInterval)
+	e.string("SCHEDULER_CALLBACK_URL", &cfg.SchedulerCallbackURL)
+	e.string("SCHEDULER_CALLBACK_SECRET", &cfg.SchedulerCallbackSecret)
+
+	e.string("CORS_ALLOWED_ORIGINS", &cfg.CORSAllowedOrigins)
+	e.string("CORS_ALLOWED_METHODS", &cfg.CORSAllowedMethods)
+	e.string("CORS_ALLOWED_HEADERS", &cfg.CORSAllowedHeaders)
+	e.bool("CORS_ALLOW_CREDENTIALS", &cfg.CORSAllowCredentials)
+	e.duration("CORS_MAX_AGE", &cfg.CORSMaxAge)
+
+	e.string("IP_ALLOW", &cfg.IPAllow)
+	e.string("IP_DENY", &cfg.IPDeny)
+	e.string("ADMIN_IP_ALLOW", &cfg.AdminIPAllow)
+	e.string("TRUSTED_PROXIES", &cfg.TrustedProxies)
+
+	e.string("AUDIT_LOG", &cfg.AuditLog)
+	e.string("AUDIT_FILE", &cfg.AuditFile)
+	e.string("AUDIT_STREAM", &cfg.AuditStream)
+	e.int64("AUDIT_STREAM_MAXLEN", &cfg.AuditStreamMaxLen)
+	e.string("AUDIT_SECRET", &cfg.AuditSecret)
+
+	// Tracing is enabled when an OTLP endpoint is configured
+	e.string("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.OTLPEndpoint)
+	e.string("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", &cfg.OTLPEndpoint)
+	e.string("OTEL_SERVICE_NAME", &cfg.ServiceName)
+	e.string("LOG_LEVEL", &cfg.LogLevel)
+	e.string("LOG_FORMAT", &cfg.LogFormat)
+
+	return errors.Join(e.errs...)
+}
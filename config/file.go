@@ -0,0 +1,423 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"valkey-rest/auth"
+)
+
+// duration reads time.Duration values written as strings such as "30s".
+type duration time.Duration
+
+func (d *duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("%q is not a duration such as 30s or 5m", text)
+	}
+	*d = duration(v)
+	return nil
+}
+
+// fileConfig is the layout of the config file. Every setting is a pointer,
+// or a nil slice or map, so fields missing from the file keep their defaults.
+type fileConfig struct {
+	API         apiSection         `yaml:"api" toml:"api"`
+	Valkey      valkeySection      `yaml:"valkey" toml:"valkey"`
+	Migration   migrationSection   `yaml:"migration" toml:"migration"`
+	TLS         tlsSection         `yaml:"tls" toml:"tls"`
+	CORS        corsSection        `yaml:"cors" toml:"cors"`
+	IPFilter    ipFilterSection    `yaml:"ip_filter" toml:"ip_filter"`
+	Cache       cacheSection       `yaml:"cache" toml:"cache"`
+	Audit       auditSection       `yaml:"audit" toml:"audit"`
+	Auth        authSection        `yaml:"auth" toml:"auth"`
+	RateLimit   rateLimitSection   `yaml:"rate_limit" toml:"rate_limit"`
+	Usage       usageSection       `yaml:"usage" toml:"usage"`
+	Concurrency concurrencySection `yaml:"concurrency" toml:"concurrency"`
+	Compression compressionSection `yaml:"compression" toml:"compression"`
+	Scripts     scriptsSection     `yaml:"scripts" toml:"scripts"`
+	Commands    commandsSection    `yaml:"commands" toml:"commands"`
+	Webhooks    webhooksSection    `yaml:"webhooks" toml:"webhooks"`
+	Scheduler   schedulerSection   `yaml:"scheduler" toml:"scheduler"`
+	Logging     loggingSection     `yaml:"logging" toml:"logging"`
+	Probes      probesSection      `yaml:"probes" toml:"probes"`
+	Breaker     breakerSection     `yaml:"circuit_breaker" toml:"circuit_breaker"`
+	Tracing     tracingSection     `yaml:"tracing" toml:"tracing"`
+	// Read by manage.sh to start the container; ignored here
+	Docker map[string]interface{} `yaml:"docker" toml:"docker"`
+}
+
+type apiSection struct {
+	Port              *int      `yaml:"port" toml:"port"`
+	GRPCPort          *int      `yaml:"grpc_port" toml:"grpc_port"`
+	Listen            *[]string `yaml:"listen" toml:"listen"`
+	AdminListen       *[]string `yaml:"admin_listen" toml:"admin_listen"`
+	DebugListen       *[]string `yaml:"debug_listen" toml:"debug_listen"`
+	AuthToken         *string   `yaml:"auth_token" toml:"auth_token"`
+	Backend           *string   `yaml:"backend" toml:"backend"`
+	DocsEnabled       *bool     `yaml:"docs_enabled" toml:"docs_enabled"`
+	ReadOnly          *bool     `yaml:"read_only" toml:"read_only"`
+	MaxBodyBytes      *int64    `yaml:"max_body_bytes" toml:"max_body_bytes"`
+	MaxImportBytes    *int64    `yaml:"max_import_bytes" toml:"max_import_bytes"`
+	ReadTimeout       *duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout      *duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       *duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownDelay     *duration `yaml:"shutdown_delay" toml:"shutdown_delay"`
+	ShutdownTimeout   *duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	CommandTimeout    *duration `yaml:"command_timeout" toml:"command_timeout"`
+	MaxCommandTimeout *duration `yaml:"max_command_timeout" toml:"max_command_timeout"`
+	IdempotencyTTL    *duration `yaml:"idempotency_ttl" toml:"idempotency_ttl"`
+	KeyVersions       *int      `yaml:"key_versions" toml:"key_versions"`
+	SessionTTL        *duration `yaml:"session_ttl" toml:"session_ttl"`
+}
+
+type valkeyTLSSection struct {
+	Enabled            *bool   `yaml:"enabled" toml:"enabled"`
+	CAFile             *string `yaml:"ca_file" toml:"ca_file"`
+	CertFile           *string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile            *string `yaml:"key_file" toml:"key_file"`
+	ServerName         *string `yaml:"server_name" toml:"server_name"`
+	InsecureSkipVerify *bool   `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
+}
+
+type sentinelSection struct {
+	Master    *string `yaml:"master" toml:"master"`
+	Addresses *string `yaml:"addresses" toml:"addresses"`
+	Password  *string `yaml:"password" toml:"password"`
+}
+
+type valkeySection struct {
+	Address          *string          `yaml:"address" toml:"address"`
+	Password         *string          `yaml:"password" toml:"password"`
+	DB               *int64           `yaml:"db" toml:"db"`
+	DBAllow          *[]int64         `yaml:"db_allow" toml:"db_allow"`
+	ReadFromReplicas *bool            `yaml:"read_from_replicas" toml:"read_from_replicas"`
+	ReplicaAddresses *string          `yaml:"replica_addresses" toml:"replica_addresses"`
+	Retries          *int             `yaml:"retries" toml:"retries"`
+	RetryBackoff     *duration        `yaml:"retry_backoff" toml:"retry_backoff"`
+	TLS              valkeyTLSSection `yaml:"tls" toml:"tls"`
+	Sentinel         sentinelSection  `yaml:"sentinel" toml:"sentinel"`
+}
+
+type migrationSection struct {
+	SecondaryAddress  *string `yaml:"secondary_address" toml:"secondary_address"`
+	SecondaryPassword *string `yaml:"secondary_password" toml:"secondary_password"`
+	Mode              *string `yaml:"mode" toml:"mode"`
+}
+
+type tlsSection struct {
+	CertFile     *string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile      *string `yaml:"key_file" toml:"key_file"`
+	ClientCAFile *string `yaml:"client_ca_file" toml:"client_ca_file"`
+}
+
+type cacheSection struct {
+	Size  *int      `yaml:"size" toml:"size"`
+	TTL   *duration `yaml:"ttl" toml:"ttl"`
+	Stale *duration `yaml:"stale" toml:"stale"`
+}
+
+type ipFilterSection struct {
+	Allow          *[]string `yaml:"allow" toml:"allow"`
+	Deny           *[]string `yaml:"deny" toml:"deny"`
+	AdminAllow     *[]string `yaml:"admin_allow" toml:"admin_allow"`
+	TrustedProxies *[]string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+type auditSection struct {
+	Sink   *string `yaml:"sink" toml:"sink"`
+	File   *string `yaml:"file" toml:"file"`
+	Stream *string `yaml:"stream" toml:"stream"`
+	MaxLen *int64  `yaml:"max_len" toml:"max_len"`
+	Secret *string `yaml:"secret" toml:"secret"`
+}
+
+type corsSection struct {
+	AllowedOrigins   *[]string `yaml:"allowed_origins" toml:"allowed_origins"`
+	AllowedMethods   *[]string `yaml:"allowed_methods" toml:"allowed_methods"`
+	AllowedHeaders   *[]string `yaml:"allowed_headers" toml:"allowed_headers"`
+	AllowCredentials *bool     `yaml:"allow_credentials" toml:"allow_credentials"`
+	MaxAge           *duration `yaml:"max_age" toml:"max_age"`
+}
+
+type jwtSection struct {
+	JWKSURL         *string           `yaml:"jwks_url" toml:"jwks_url"`
+	Issuer          *string           `yaml:"issuer" toml:"issuer"`
+	Audience        *string           `yaml:"audience" toml:"audience"`
+	RoleClaim       *string           `yaml:"role_claim" toml:"role_claim"`
+	NamespaceClaim  *string           `yaml:"namespace_claim" toml:"namespace_claim"`
+	RoleMap         map[string]string `yaml:"role_map" toml:"role_map"`
+	RefreshInterval *duration         `yaml:"refresh_interval" toml:"refresh_interval"`
+}
+
+type authSection struct {
+	TokensFile *string            `yaml:"tokens_file" toml:"tokens_file"`
+	Tokens     []auth.TokenConfig `yaml:"tokens" toml:"tokens"`
+	JWT        jwtSection         `yaml:"jwt" toml:"jwt"`
+}
+
+type rateLimitSection struct {
+	PerIP    *int64    `yaml:"per_ip" toml:"per_ip"`
+	PerToken *int64    `yaml:"per_token" toml:"per_token"`
+	Period   *duration `yaml:"period" toml:"period"`
+}
+
+type usageSection struct {
+	Enabled         *bool  `yaml:"enabled" toml:"enabled"`
+	MonthlyRequests *int64 `yaml:"monthly_requests" toml:"monthly_requests"`
+	MonthlyBytes    *int64 `yaml:"monthly_bytes" toml:"monthly_bytes"`
+}
+
+type concurrencySection struct {
+	MaxInFlight         *int      `yaml:"max_in_flight" toml:"max_in_flight"`
+	MaxInFlightPerToken *int      `yaml:"max_in_flight_per_token" toml:"max_in_flight_per_token"`
+	MaxQueue            *int      `yaml:"max_queue" toml:"max_queue"`
+	QueueTimeout        *duration `yaml:"queue_timeout" toml:"queue_timeout"`
+}
+
+type compressionSection struct {
+	Algorithm *string `yaml:"algorithm" toml:"algorithm"`
+	Threshold *int    `yaml:"threshold" toml:"threshold"`
+}
+
+type scriptsSection struct {
+	Dir *string `yaml:"dir" toml:"dir"`
+}
+
+type commandsSection struct {
+	Allow *[]string `yaml:"allow" toml:"allow"`
+	Deny  *[]string `yaml:"deny" toml:"deny"`
+}
+
+type webhooksSection struct {
+	Enabled *bool `yaml:"enabled" toml:"enabled"`
+}
+
+type schedulerSection struct {
+	Enabled        *bool     `yaml:"enabled" toml:"enabled"`
+	Key            *string   `yaml:"key" toml:"key"`
+	Interval       *duration `yaml:"interval" toml:"interval"`
+	CallbackURL    *string   `yaml:"callback_url" toml:"callback_url"`
+	CallbackSecret *string   `yaml:"callback_secret" toml:"callback_secret"`
+}
+
+type loggingSection struct {
+	Level  *string `yaml:"level" toml:"level"`
+	Format *string `yaml:"format" toml:"format"`
+}
+
+type probesSection struct {
+	ReadyTimeout     *duration `yaml:"ready_timeout" toml:"ready_timeout"`
+	CheckLoading     *bool     `yaml:"check_loading" toml:"check_loading"`
+	CheckReplication *bool     `yaml:"check_replication" toml:"check_replication"`
+}
+
+type breakerSection struct {
+	Threshold     *int      `yaml:"threshold" toml:"threshold"`
+	ProbeInterval *duration `yaml:"probe_interval" toml:"probe_interval"`
+}
+
+type tracingSection struct {
+	Endpoint    *string `yaml:"endpoint" toml:"endpoint"`
+	ServiceName *string `yaml:"service_name" toml:"service_name"`
+}
+
+// set copies a value from the file if it was present.
+func set[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setDuration(dst *time.Duration, src *duration) {
+	if src != nil {
+		*dst = time.Duration(*src)
+	}
+}
+
+func setPort(dst *string, src *int) {
+	if src != nil {
+		*dst = strconv.Itoa(*src)
+	}
+}
+
+func setList(dst *string, src *[]string) {
+	if src != nil {
+		*dst = strings.Join(*src, ",")
+	}
+}
+
+func setIntList(dst *string, src *[]int64) {
+	if src != nil {
+		items := make([]string, len(*src))
+		for i, n := range *src {
+			items[i] = strconv.FormatInt(n, 10)
+		}
+		*dst = strings.Join(items, ",")
+	}
+}
+
+// loadFile applies the YAML or TOML config file at path to cfg. The format
+// is chosen by extension, and unknown fields are rejected so typos don't go
+// unnoticed.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var f fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), &f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown field %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("%s: config file must end in .yaml, .yml or .toml", path)
+	}
+
+	setPort(&cfg.Port, f.API.Port)
+	setPort(&cfg.GRPCPort, f.API.GRPCPort)
+	setList(&cfg.Listen, f.API.Listen)
+	setList(&cfg.AdminListen, f.API.AdminListen)
+	setList(&cfg.DebugListen, f.API.DebugListen)
+	set(&cfg.AuthToken, f.API.AuthToken)
+	set(&cfg.Backend, f.API.Backend)
+	set(&cfg.DocsEnabled, f.API.DocsEnabled)
+	set(&cfg.ReadOnly, f.API.ReadOnly)
+	set(&cfg.MaxBodyBytes, f.API.MaxBodyBytes)
+	set(&cfg.MaxImportBytes, f.API.MaxImportBytes)
+	setDuration(&cfg.ReadTimeout, f.API.ReadTimeout)
+	setDuration(&cfg.WriteTimeout, f.API.WriteTimeout)
+	setDuration(&cfg.IdleTimeout, f.API.IdleTimeout)
+	setDuration(&cfg.ShutdownDelay, f.API.ShutdownDelay)
+	setDuration(&cfg.ShutdownTimeout, f.API.ShutdownTimeout)
+	setDuration(&cfg.CommandTimeout, f.API.CommandTimeout)
+	setDuration(&cfg.MaxCommandTimeout, f.API.MaxCommandTimeout)
+	setDuration(&cfg.IdempotencyTTL, f.API.IdempotencyTTL)
+	set(&cfg.KeyVersions, f.API.KeyVersions)
+	setDuration(&cfg.SessionTTL, f.API.SessionTTL)
+
+	set(&cfg.ValkeyAddress, f.Valkey.Address)
+	set(&cfg.ValkeyPassword, f.Valkey.Password)
+	set(&cfg.ValkeyDB, f.Valkey.DB)
+	setIntList(&cfg.ValkeyDBAllow, f.Valkey.DBAllow)
+	set(&cfg.ReadFromReplicas, f.Valkey.ReadFromReplicas)
+	set(&cfg.ReplicaAddresses, f.Valkey.ReplicaAddresses)
+	set(&cfg.CommandRetries, f.Valkey.Retries)
+	setDuration(&cfg.CommandRetryBackoff, f.Valkey.RetryBackoff)
+	set(&cfg.ValkeyTLS, f.Valkey.TLS.Enabled)
+	set(&cfg.ValkeyTLSCAFile, f.Valkey.TLS.CAFile)
+	set(&cfg.ValkeyTLSCertFile, f.Valkey.TLS.CertFile)
+	set(&cfg.ValkeyTLSKeyFile, f.Valkey.TLS.KeyFile)
+	set(&cfg.ValkeyTLSServerName, f.Valkey.TLS.ServerName)
+	set(&cfg.ValkeyTLSInsecure, f.Valkey.TLS.InsecureSkipVerify)
+	set(&cfg.SentinelMaster, f.Valkey.Sentinel.Master)
+	set(&cfg.SentinelAddresses, f.Valkey.Sentinel.Addresses)
+	set(&cfg.SentinelPassword, f.Valkey.Sentinel.Password)
+
+	set(&cfg.MigrationAddress, f.Migration.SecondaryAddress)
+	set(&cfg.MigrationPassword, f.Migration.SecondaryPassword)
+	set(&cfg.MigrationMode, f.Migration.Mode)
+
+	set(&cfg.TLSCertFile, f.TLS.CertFile)
+	set(&cfg.TLSKeyFile, f.TLS.KeyFile)
+	set(&cfg.TLSClientCAFile, f.TLS.ClientCAFile)
+
+	set(&cfg.CacheSize, f.Cache.Size)
+	setDuration(&cfg.CacheTTL, f.Cache.TTL)
+	setDuration(&cfg.CacheStale, f.Cache.Stale)
+
+	setList(&cfg.IPAllow, f.IPFilter.Allow)
+	setList(&cfg.IPDeny, f.IPFilter.Deny)
+	setList(&cfg.AdminIPAllow, f.IPFilter.AdminAllow)
+	setList(&cfg.TrustedProxies, f.IPFilter.TrustedProxies)
+
+	set(&cfg.AuditLog, f.Audit.Sink)
+	set(&cfg.AuditFile, f.Audit.File)
+	set(&cfg.AuditStream, f.Audit.Stream)
+	set(&cfg.AuditStreamMaxLen, f.Audit.MaxLen)
+	set(&cfg.AuditSecret, f.Audit.Secret)
+
+	setList(&cfg.CORSAllowedOrigins, f.CORS.AllowedOrigins)
+	setList(&cfg.CORSAllowedMethods, f.CORS.AllowedMethods)
+	setList(&cfg.CORSAllowedHeaders, f.CORS.AllowedHeaders)
+	set(&cfg.CORSAllowCredentials, f.CORS.AllowCredentials)
+	setDuration(&cfg.CORSMaxAge, f.CORS.MaxAge)
+
+	set(&cfg.AuthTokensFile, f.Auth.TokensFile)
+	if f.Auth.Tokens != nil {
+		cfg.Tokens = f.Auth.Tokens
+	}
+	set(&cfg.JWT.JWKSURL, f.Auth.JWT.JWKSURL)
+	set(&cfg.JWT.Issuer, f.Auth.JWT.Issuer)
+	set(&cfg.JWT.Audience, f.Auth.JWT.Audience)
+	set(&cfg.JWT.RoleClaim, f.Auth.JWT.RoleClaim)
+	set(&cfg.JWT.NamespaceClaim, f.Auth.JWT.NamespaceClaim)
+	setDuration(&cfg.JWT.RefreshInterval, f.Auth.JWT.RefreshInterval)
+	if f.Auth.JWT.RoleMap != nil {
+		cfg.JWT.RoleMap = make(map[string]auth.Role, len(f.Auth.JWT.RoleMap))
+		for value, name := range f.Auth.JWT.RoleMap {
+			role, err := auth.ParseRole(name)
+			if err != nil {
+				return fmt.Errorf("%s: auth.jwt.role_map.%s: %w", path, value, err)
+			}
+			cfg.JWT.RoleMap[value] = role
+		}
+	}
+
+	set(&cfg.RateLimitPerIP, f.RateLimit.PerIP)
+	set(&cfg.RateLimitPerToken, f.RateLimit.PerToken)
+	setDuration(&cfg.RateLimitPeriod, f.RateLimit.Period)
+	set(&cfg.UsageTracking, f.Usage.Enabled)
+	set(&cfg.UsageMonthlyRequests, f.Usage.MonthlyRequests)
+	set(&cfg.UsageMonthlyBytes, f.Usage.MonthlyBytes)
+	set(&cfg.MaxInFlight, f.Concurrency.MaxInFlight)
+	set(&cfg.MaxInFlightPerToken, f.Concurrency.MaxInFlightPerToken)
+	set(&cfg.MaxQueue, f.Concurrency.MaxQueue)
+	setDuration(&cfg.QueueTimeout, f.Concurrency.QueueTimeout)
+
+	set(&cfg.ValueCompression, f.Compression.Algorithm)
+	set(&cfg.ValueCompressionThreshold, f.Compression.Threshold)
+	set(&cfg.ScriptsDir, f.Scripts.Dir)
+	setList(&cfg.CommandAllow, f.Commands.Allow)
+	setList(&cfg.CommandDeny, f.Commands.Deny)
+	set(&cfg.WebhooksEnabled, f.Webhooks.Enabled)
+
+	set(&cfg.SchedulerEnabled, f.Scheduler.Enabled)
+	set(&cfg.ScheduleKey, f.Scheduler.Key)
+	setDuration(&cfg.SchedulerInterval, f.Scheduler.Interval)
+	set(&cfg.SchedulerCallbackURL, f.Scheduler.CallbackURL)
+	set(&cfg.SchedulerCallbackSecret, f.Scheduler.CallbackSecret)
+
+	set(&cfg.LogLevel, f.Logging.Level)
+	set(&cfg.LogFormat, f.Logging.Format)
+	setDuration(&cfg.ReadyTimeout, f.Probes.ReadyTimeout)
+	set(&cfg.ReadyCheckLoading, f.Probes.CheckLoading)
+	set(&cfg.ReadyCheckReplication, f.Probes.CheckReplication)
+	set(&cfg.CircuitBreakerThreshold, f.Breaker.Threshold)
+	setDuration(&cfg.CircuitBreakerProbeInterval, f.Breaker.ProbeInterval)
+	set(&cfg.OTLPEndpoint, f.Tracing.Endpoint)
+	set(&cfg.ServiceName, f.Tracing.ServiceName)
+	return nil
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"valkey-rest/auth"
+	"valkey-rest/store"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// parseInfo turns INFO output into sections of field/value pairs, keyed by
+// lower-cased section name.
+func parseInfo(info string) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "# "); ok {
+			current = make(map[string]string)
+			sections[strings.ToLower(name)] = current
+			continue
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok || current == nil {
+			continue
+		}
+		current[field] = value
+	}
+	return sections
+}
+
+func (h *Handlers) HandleInfo(w http.ResponseWriter, r *http.Request) {
+	section := r.URL.Query().Get("section")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	h.writeNodeReport(w, "info", func(node valkey.Client) (interface{}, error) {
+		var cmd valkey.Completed
+		if section != "" {
+			cmd = node.B().Info().Section(section).Build()
+		} else {
+			cmd = node.B().Info().Build()
+		}
+		info, err := node.Do(ctx, cmd).ToString()
+		if err != nil {
+			return nil, err
+		}
+		return parseInfo(info), nil
+	})
+}
+
+// writeNodeReport responds with report's result for the server under field.
+// Diagnostics describe a single node, so in cluster mode every node is
+// reported separately under "nodes", keyed by address.
+func (h *Handlers) writeNodeReport(w http.ResponseWriter, field string, report func(node valkey.Client) (interface{}, error)) {
+	if h.client.Mode() != valkey.ClientModeCluster {
+		result, err := report(h.client)
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{field: result})
+		return
+	}
+
+	nodes, addrs := h.sortedNodes()
+	results := make(map[string]interface{}, len(addrs))
+	for _, addr := range addrs {
+		result, err := report(nodes[addr])
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		results[addr] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"nodes": results})
+}
+
+func (h *Handlers) HandleDBSize(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.client.Mode() != valkey.ClientModeCluster {
+		size, err := h.client.Do(ctx, h.client.B().Dbsize().Build()).AsInt64()
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int64{"dbsize": size})
+		return
+	}
+
+	// Sum primaries only, since replicas hold copies of their primary's keys
+	nodes, addrs := h.sortedNodes()
+	var total int64
+	sizes := make(map[string]int64, len(addrs))
+	for _, addr := range addrs {
+		node := nodes[addr]
+		size, err := node.Do(ctx, node.B().Dbsize().Build()).AsInt64()
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		sizes[addr] = size
+
+		primary, err := store.IsPrimary(ctx, node)
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		if primary {
+			total += size
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dbsize": total,
+		"nodes":  sizes,
+	})
+}
+
+// FlushRequest empties the database, or only the keys matching Pattern. The
+// confirmation must repeat the database name ("db0") or the pattern exactly.
+type FlushRequest struct {
+	Confirm string `json:"confirm"`
+	Pattern string `json:"pattern,omitempty"`
+	Async   bool   `json:"async,omitempty"`
+}
+
+// databaseName is the name a full flush must be confirmed with.
+func (h *Handlers) databaseName(r *http.Request) string {
+	return fmt.Sprintf("db%d", h.requestDB(r))
+}
+
+func (h *Handlers) HandleFlush(w http.ResponseWriter, r *http.Request) {
+	var req FlushRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Pattern != "" {
+		if req.Confirm != req.Pattern {
+			writeError(w, http.StatusBadRequest, "confirm must match the pattern")
+			return
+		}
+
+		ctx, cancel := extendForBlock(w, r, bulkDeleteTimeout)
+		defer cancel()
+
+		count, _, err := h.deleteMatching(ctx, r, req.Pattern, false, !req.Async)
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "flushed",
+			"pattern": req.Pattern,
+			"count":   count,
+		})
+		return
+	}
+
+	// A namespace shares the database with other tenants
+	if RequestNamespace(r) != "" {
+		writeError(w, http.StatusBadRequest, "pattern is required within a namespace")
+		return
+	}
+	if p := auth.FromContext(r.Context()); p != nil && len(p.KeyPatterns) > 0 {
+		writeError(w, http.StatusForbidden, "tokens restricted to key patterns can only flush by pattern")
+		return
+	}
+	if req.Confirm != h.databaseName(r) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("confirm must be %q to flush the database", h.databaseName(r)))
+		return
+	}
+
+	ctx, cancel := extendForBlock(w, r, bulkDeleteTimeout)
+	defer cancel()
+
+	flush := func(node valkey.Client) error {
+		if req.Async {
+			return node.Do(ctx, node.B().Flushdb().Async().Build()).Error()
+		}
+		return node.Do(ctx, node.B().Flushdb().Sync().Build()).Error()
+	}
+
+	if h.client.Mode() != valkey.ClientModeCluster {
+		if err := flush(h.client); err != nil {
+			writeCommandError(w, err)
+			return
+		}
+	} else {
+		// FLUSHDB only empties the node it runs on, and replicas follow their primary
+		nodes, addrs := h.sortedNodes()
+		for _, addr := range addrs {
+			primary, err := store.IsPrimary(ctx, nodes[addr])
+			if err != nil {
+				writeCommandError(w, err)
+				return
+			}
+			if !primary {
+				continue
+			}
+			if err := flush(nodes[addr]); err != nil {
+				writeCommandError(w, err)
+				return
+			}
+		}
+	}
+
+	if c, ok := h.store.(store.Invalidator); ok {
+		c.InvalidateAll()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "flushed",
+		"database": h.databaseName(r),
+		"async":    req.Async,
+	})
+}
+
+const (
+	defaultSlowlogCount = 10
+	maxSlowlogCount     = 1000
+)
+
+// SlowlogEntry is a parsed SLOWLOG GET entry.
+type SlowlogEntry struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	DurationUS int64     `json:"duration_us"`
+	Command    []string  `json:"command"`
+	Client     string    `json:"client,omitempty"`
+	ClientName string    `json:"client_name,omitempty"`
+}
+
+// LatencySample is one LATENCY HISTORY data point.
+type LatencySample struct {
+	Time      time.Time `json:"time"`
+	LatencyMS int64     `json:"latency_ms"`
+}
+
+// LatencyEvent is a parsed LATENCY LATEST entry.
+type LatencyEvent struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	LatestMS int64     `json:"latest_ms"`
+	MaxMS    int64     `json:"max_ms"`
+}
+
+func parseSlowlog(entries []valkey.ValkeyMessage) ([]SlowlogEntry, error) {
+	result := make([]SlowlogEntry, 0, len(entries))
+	for _, entry := range entries {
+		fields, err := entry.ToArray()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 4 {
+			continue
+		}
+
+		var e SlowlogEntry
+		if e.ID, err = fields[0].AsInt64(); err != nil {
+			return nil, err
+		}
+		ts, err := fields[1].AsInt64()
+		if err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(ts, 0).UTC()
+		if e.DurationUS, err = fields[2].AsInt64(); err != nil {
+			return nil, err
+		}
+		if e.Command, err = fields[3].AsStrSlice(); err != nil {
+			return nil, err
+		}
+		// Client details were added in Redis 4.0
+		if len(fields) >= 6 {
+			e.Client, _ = fields[4].ToString()
+			e.ClientName, _ = fields[5].ToString()
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (h *Handlers) HandleSlowlog(w http.ResponseWriter, r *http.Request) {
+	count := int64(defaultSlowlogCount)
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxSlowlogCount {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("count must be between 1 and %d", maxSlowlogCount))
+			return
+		}
+		count = n
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	h.writeNodeReport(w, "slowlog", func(node valkey.Client) (interface{}, error) {
+		entries, err := node.Do(ctx, node.B().SlowlogGet().Count(count).Build()).ToArray()
+		if err != nil {
+			return nil, err
+		}
+		return parseSlowlog(entries)
+	})
+}
+
+func (h *Handlers) HandleLatency(w http.ResponseWriter, r *http.Request) {
+	event := r.URL.Query().Get("event")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	// Without an event, report the latest spike of every event instead
+	if event == "" {
+		h.writeNodeReport(w, "latency", func(node valkey.Client) (interface{}, error) {
+			entries, err := node.Do(ctx, node.B().LatencyLatest().Build()).ToArray()
+			if err != nil {
+				return nil, err
+			}
+			events := make([]LatencyEvent, 0, len(entries))
+			for _, entry := range entries {
+				fields, err := entry.ToArray()
+				if err != nil {
+					return nil, err
+				}
+				if len(fields) < 4 {
+					continue
+				}
+				var e LatencyEvent
+				if e.Event, err = fields[0].ToString(); err != nil {
+					return nil, err
+				}
+				ts, err := fields[1].AsInt64()
+				if err != nil {
+					return nil, err
+				}
+				e.Time = time.Unix(ts, 0).UTC()
+				if e.LatestMS, err = fields[2].AsInt64(); err != nil {
+					return nil, err
+				}
+				if e.MaxMS, err = fields[3].AsInt64(); err != nil {
+					return nil, err
+				}
+				events = append(events, e)
+			}
+			return events, nil
+		})
+		return
+	}
+
+	h.writeNodeReport(w, "history", func(node valkey.Client) (interface{}, error) {
+		entries, err := node.Do(ctx, node.B().LatencyHistory().Event(event).Build()).ToArray()
+		if err != nil {
+			return nil, err
+		}
+		samples := make([]LatencySample, 0, len(entries))
+		for _, entry := range entries {
+			pair, err := entry.AsIntSlice()
+			if err != nil {
+				return nil, err
+			}
+			if len(pair) < 2 {
+				continue
+			}
+			samples = append(samples, LatencySample{Time: time.Unix(pair[0], 0).UTC(), LatencyMS: pair[1]})
+		}
+		return samples, nil
+	})
+}
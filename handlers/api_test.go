@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"valkey-rest/store"
+)
+
+func TestKeyLifecycle(t *testing.T) {
+	h := routes(newTestHandlers(t, store.NewMemory()))
+
+	if rec := request(h, http.MethodPost, "/keys/greeting", `{"value":"hello"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("set = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := request(h, http.MethodGet, "/keys/greeting", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get = %d: %s", rec.Code, rec.Body)
+	}
+	var got GetResponse
+	decode(t, rec, &got)
+	if got.Key != "greeting" || got.Value != "hello" {
+		t.Errorf("get = %+v, want greeting=hello", got)
+	}
+
+	if rec := request(h, http.MethodHead, "/keys/greeting", ""); rec.Code != http.StatusOK {
+		t.Errorf("head = %d, want 200", rec.Code)
+	}
+	if rec := request(h, http.MethodDelete, "/keys/greeting", ""); rec.Code != http.StatusOK {
+		t.Errorf("delete = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := request(h, http.MethodGet, "/keys/greeting", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete = %d, want 404", rec.Code)
+	}
+	if rec := request(h, http.MethodHead, "/keys/greeting", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("head after delete = %d, want 404", rec.Code)
+	}
+}
+
+func TestSetEncodings(t *testing.T) {
+	h := routes(newTestHandlers(t, store.NewMemory()))
+	large := strings.Repeat("compressible ", 100)
+
+	for _, tc := range []struct {
+		name, body, want string
+		header           []string
+	}{
+		{"base64", `{"value":"AAEC","encoding":"base64"}`, "\x00\x01\x02", nil},
+		{"raw", "raw bytes", "raw bytes", []string{"Content-Type", "application/octet-stream"}},
+		{"compressed", `{"value":"` + large + `"}`, large, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := request(h, http.MethodPost, "/keys/"+tc.name, tc.body, tc.header...); rec.Code != http.StatusCreated {
+				t.Fatalf("set = %d: %s", rec.Code, rec.Body)
+			}
+			rec := request(h, http.MethodGet, "/keys/"+tc.name, "", "Accept", "application/octet-stream")
+			if rec.Code != http.StatusOK || rec.Body.String() != tc.want {
+				t.Errorf("get = %d %q, want %q", rec.Code, rec.Body, tc.want)
+			}
+		})
+	}
+
+	if rec := request(h, http.MethodPost, "/keys/bad", `{"value":"!","encoding":"base64"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid base64 = %d, want 400", rec.Code)
+	}
+	if rec := request(h, http.MethodPost, "/keys/empty", `{"value":""}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty value = %d, want 400", rec.Code)
+	}
+}
+
+func TestNamespacesIsolateKeys(t *testing.T) {
+	st := store.NewMemory()
+	h := routes(newTestHandlers(t, st))
+
+	request(h, http.MethodPost, "/keys/k", `{"value":"acme"}`, NamespaceHeader, "acme")
+	request(h, http.MethodPost, "/keys/k", `{"value":"globex"}`, NamespaceHeader, "globex")
+
+	for _, ns := range []string{"acme", "globex"} {
+		var got GetResponse
+		decode(t, request(h, http.MethodGet, "/keys/k", "", NamespaceHeader, ns), &got)
+		if got.Key != "k" || got.Value != ns {
+			t.Errorf("namespace %s: got %+v", ns, got)
+		}
+	}
+	if rec := request(h, http.MethodGet, "/keys/k", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("outside any namespace = %d, want 404", rec.Code)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	h := routes(newTestHandlers(t, store.NewMemory()))
+
+	rec := request(h, http.MethodPost, "/keys/doc", `{"value":"v1"}`)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("set returned no ETag")
+	}
+
+	if rec := request(h, http.MethodGet, "/keys/doc", "", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("get with current ETag = %d, want 304", rec.Code)
+	}
+	// If-None-Match compares weakly, If-Match strongly
+	if rec := request(h, http.MethodGet, "/keys/doc", "", "If-None-Match", `"other", W/`+etag); rec.Code != http.StatusNotModified {
+		t.Errorf("get with weak current ETag = %d, want 304", rec.Code)
+	}
+	if rec := request(h, http.MethodPost, "/keys/doc", `{"value":"weak"}`, "If-Match", "W/"+etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("set with weak If-Match = %d, want 412", rec.Code)
+	}
+
+	rec = request(h, http.MethodPost, "/keys/doc", `{"value":"v2"}`, "If-Match", etag)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("set with current ETag = %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("ETag unchanged after the value changed")
+	}
+
+	// The first ETag is stale now
+	if rec := request(h, http.MethodPost, "/keys/doc", `{"value":"v3"}`, "If-Match", etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("set with stale ETag = %d, want 412", rec.Code)
+	}
+	if rec := request(h, http.MethodDelete, "/keys/doc", "", "If-Match", etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("delete with stale ETag = %d, want 412", rec.Code)
+	}
+	if rec := request(h, http.MethodPost, "/keys/missing", `{"value":"v"}`, "If-Match", "*"); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("If-Match * on a missing key = %d, want 412", rec.Code)
+	}
+
+	var got GetResponse
+	decode(t, request(h, http.MethodGet, "/keys/doc", ""), &got)
+	if got.Value != "v2" {
+		t.Errorf("value = %q, want v2", got.Value)
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	h := routes(newTestHandlers(t, store.NewMemory()))
+	for _, key := range []string{"a:1", "a:2", "a:3", "a:4", "a:5", "b:1"} {
+		request(h, http.MethodPost, "/keys/"+key, `{"value":"x"}`)
+	}
+
+	var listed []string
+	cursor := "0"
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("cursor never returned to 0")
+		}
+		var page struct {
+			Keys   []string `json:"keys"`
+			Cursor string   `json:"cursor"`
+		}
+		rec := request(h, http.MethodGet, "/keys?pattern=a:*&limit=2&cursor="+cursor, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list = %d: %s", rec.Code, rec.Body)
+		}
+		decode(t, rec, &page)
+		listed = append(listed, page.Keys...)
+		if cursor = page.Cursor; cursor == "0" {
+			break
+		}
+	}
+	if strings.Join(listed, ",") != "a:1,a:2,a:3,a:4,a:5" {
+		t.Errorf("listed %q, want a:1 to a:5", listed)
+	}
+
+	if rec := request(h, http.MethodGet, "/keys?cursor=bogus", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid cursor = %d, want 400", rec.Code)
+	}
+}
+
+func TestVersionedWriteRefusesIfMatch(t *testing.T) {
+	h := routes(newTestHandlers(t, store.NewMemory()))
+	request(h, http.MethodPost, "/keys/doc", `{"value":"v1"}`)
+
+	for _, etag := range []string{`"stale"`, "*"} {
+		rec := request(h, http.MethodPost, "/keys/doc", `{"value":"v2","versioned":true}`, "If-Match", etag)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "If-Match") {
+			t.Errorf("versioned write with If-Match %s = %d, want 400: %s", etag, rec.Code, rec.Body)
+		}
+	}
+
+	var got GetResponse
+	decode(t, request(h, http.MethodGet, "/keys/doc", ""), &got)
+	if got.Value != "v1" {
+		t.Errorf("value = %q, want v1", got.Value)
+	}
+}
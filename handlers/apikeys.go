@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+)
+
+func (h *Handlers) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req auth.CreateAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "role must be one of read, write or admin")
+		return
+	}
+
+	for _, pattern := range req.KeyPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid key pattern")
+			return
+		}
+	}
+	for _, ns := range req.Namespaces {
+		if !ValidNamespace(ns) {
+			writeError(w, http.StatusBadRequest, "invalid namespace")
+			return
+		}
+	}
+
+	if req.ExpiresIn < 0 || req.RateLimit < 0 {
+		writeError(w, http.StatusBadRequest, "expires_in and rate_limit must not be negative")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	key, secret, err := h.apiKeys.Create(ctx, req, role)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		*auth.APIKey
+		Key string `json:"key"`
+	}{key, secret})
+}
+
+func (h *Handlers) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*CommandTimeout(r.Context()))
+	defer cancel()
+
+	ids, err := h.scanKeys(ctx, auth.APIKeyIDKey("*"), 1000)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	keys := []*auth.APIKey{}
+	for _, idKey := range ids {
+		key, err := h.apiKeys.Get(ctx, idKey[len(auth.APIKeyIDKey("")):])
+		if err != nil {
+			// Expired between SCAN and GET
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"apikeys": keys,
+		"count":   len(keys),
+	})
+}
+
+func (h *Handlers) HandleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	if err := h.apiKeys.Delete(ctx, id); err != nil {
+		if valkey.IsValkeyNil(err) {
+			writeError(w, http.StatusNotFound, "api key not found")
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "id": id})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// maxBitOffset is the largest offset SETBIT accepts (512 MB strings).
+const maxBitOffset = 1<<32 - 1
+
+type SetBitRequest struct {
+	Value *int64 `json:"value"` // 0 or 1
+}
+
+type BitOpRequest struct {
+	Operation string   `json:"operation"` // AND, OR, XOR or NOT
+	Sources   []string `json:"sources"`
+}
+
+// bitOffset parses the {offset} path value, writing a 400 response if it is
+// invalid.
+func bitOffset(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	offset, err := strconv.ParseInt(r.PathValue("offset"), 10, 64)
+	if err != nil || offset < 0 || offset > maxBitOffset {
+		writeError(w, http.StatusBadRequest, "offset must be between 0 and 4294967295")
+		return 0, false
+	}
+	return offset, true
+}
+
+func (h *Handlers) HandleSetBit(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	offset, ok := bitOffset(w, r)
+	if !ok {
+		return
+	}
+
+	var req SetBitRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Value == nil || (*req.Value != 0 && *req.Value != 1) {
+		writeError(w, http.StatusBadRequest, "value must be 0 or 1")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	previous, err := h.client.Do(ctx, h.client.B().Setbit().Key(namespacedKey(r, key)).Offset(offset).Value(*req.Value).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":      key,
+		"offset":   offset,
+		"value":    *req.Value,
+		"previous": previous,
+	})
+}
+
+func (h *Handlers) HandleGetBit(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	offset, ok := bitOffset(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	value, err := h.client.Do(ctx, h.client.B().Getbit().Key(namespacedKey(r, key)).Offset(offset).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":    key,
+		"offset": offset,
+		"value":  value,
+	})
+}
+
+// HandleBitCount counts set bits, optionally within ?start=&end= given in
+// bytes, or in bits with ?unit=bit. Negative indexes count from the end.
+func (h *Handlers) HandleBitCount(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	q := r.URL.Query()
+
+	cmd := h.client.B().Bitcount().Key(namespacedKey(r, key))
+	var built valkey.Completed
+	if q.Get("start") == "" && q.Get("end") == "" {
+		built = cmd.Build()
+	} else {
+		start, err1 := strconv.ParseInt(q.Get("start"), 10, 64)
+		end, err2 := strconv.ParseInt(q.Get("end"), 10, 64)
+		if err1 != nil || err2 != nil {
+			writeError(w, http.StatusBadRequest, "start and end must both be integers")
+			return
+		}
+		switch q.Get("unit") {
+		case "", "byte":
+			built = cmd.Start(start).End(end).Byte().Build()
+		case "bit":
+			built = cmd.Start(start).End(end).Bit().Build()
+		default:
+			writeError(w, http.StatusBadRequest, "unit must be byte or bit")
+			return
+		}
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	count, err := h.client.Do(ctx, built).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":   key,
+		"count": count,
+	})
+}
+
+// HandleBitOp combines source bitmaps into {key} with BITOP.
+func (h *Handlers) HandleBitOp(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	var req BitOpRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.Sources) == 0 {
+		writeError(w, http.StatusBadRequest, "sources are required")
+		return
+	}
+	if !checkKeys(w, r, req.Sources...) {
+		return
+	}
+	sources := make([]string, len(req.Sources))
+	for i, k := range req.Sources {
+		sources[i] = namespacedKey(r, k)
+	}
+
+	dest := namespacedKey(r, key)
+	var cmd valkey.Completed
+	switch strings.ToUpper(req.Operation) {
+	case "AND":
+		cmd = h.client.B().Bitop().And().Destkey(dest).Key(sources...).Build()
+	case "OR":
+		cmd = h.client.B().Bitop().Or().Destkey(dest).Key(sources...).Build()
+	case "XOR":
+		cmd = h.client.B().Bitop().Xor().Destkey(dest).Key(sources...).Build()
+	case "NOT":
+		if len(sources) != 1 {
+			writeError(w, http.StatusBadRequest, "NOT takes exactly one source")
+			return
+		}
+		cmd = h.client.B().Bitop().Not().Destkey(dest).Key(sources...).Build()
+	default:
+		writeError(w, http.StatusBadRequest, "operation must be one of AND, OR, XOR or NOT")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	length, err := h.client.Do(ctx, cmd).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "stored",
+		"key":       key,
+		"operation": strings.ToUpper(req.Operation),
+		"length":    length, // Size of the resulting string in bytes
+	})
+}
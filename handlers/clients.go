@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+type ConfigSetRequest struct {
+	Parameters map[string]string `json:"parameters"`
+}
+
+// parseClientList turns CLIENT LIST output into one field/value map per
+// connection. Values are left as strings, as INFO's are, since the set of
+// fields differs between server versions.
+func parseClientList(list string) []map[string]string {
+	clients := []map[string]string{}
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		client := make(map[string]string)
+		for _, pair := range strings.Fields(line) {
+			field, value, _ := strings.Cut(pair, "=")
+			client[field] = value
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// HandleListClients reports the connections of each server, optionally
+// filtered by ?type=normal|master|replica|pubsub.
+func (h *Handlers) HandleListClients(w http.ResponseWriter, r *http.Request) {
+	clientType := r.URL.Query().Get("type")
+	switch clientType {
+	case "", "normal", "master", "replica", "pubsub":
+	default:
+		writeError(w, http.StatusBadRequest, "type must be normal, master, replica or pubsub")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	h.writeNodeReport(w, "clients", func(node valkey.Client) (interface{}, error) {
+		var cmd valkey.Completed
+		switch clientType {
+		case "normal":
+			cmd = node.B().ClientList().TypeNormal().Build()
+		case "master":
+			cmd = node.B().ClientList().TypeMaster().Build()
+		case "replica":
+			cmd = node.B().ClientList().TypeReplica().Build()
+		case "pubsub":
+			cmd = node.B().ClientList().TypePubsub().Build()
+		default:
+			cmd = node.B().ClientList().Build()
+		}
+		list, err := node.Do(ctx, cmd).ToString()
+		if err != nil {
+			return nil, err
+		}
+		return parseClientList(list), nil
+	})
+}
+
+// HandleKillClient closes a connection by its CLIENT LIST id. Client IDs are
+// only unique per server, so in cluster mode ?node= must name the node.
+func (h *Handlers) HandleKillClient(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "id must be a positive integer")
+		return
+	}
+
+	node := h.client
+	addr := r.URL.Query().Get("node")
+	if h.client.Mode() == valkey.ClientModeCluster {
+		nodes := h.client.Nodes()
+		if node = nodes[addr]; node == nil {
+			writeError(w, http.StatusBadRequest, "node must be the address of a cluster node")
+			return
+		}
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	// The proxy's own connection is skipped, as CLIENT KILL does by default
+	killed, err := node.Do(ctx, node.B().ClientKill().Id(id).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if killed == 0 {
+		writeError(w, http.StatusNotFound, "client not found")
+		return
+	}
+
+	resp := map[string]interface{}{"status": "killed", "id": id}
+	if addr != "" {
+		resp["node"] = addr
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleGetConfig returns the configuration parameters matching {param},
+// which may be a glob such as maxmemory*.
+func (h *Handlers) HandleGetConfig(w http.ResponseWriter, r *http.Request) {
+	param := r.PathValue("param")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	h.writeNodeReport(w, "config", func(node valkey.Client) (interface{}, error) {
+		return node.Do(ctx, node.B().ConfigGet().Parameter(param).Build()).AsStrMap()
+	})
+}
+
+// HandleSetConfig changes configuration parameters with CONFIG SET. In
+// cluster mode every node is changed, replicas included, so they stay alike;
+// nodes changed before a failing one keep the new values.
+func (h *Handlers) HandleSetConfig(w http.ResponseWriter, r *http.Request) {
+	var req ConfigSetRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.Parameters) == 0 {
+		writeError(w, http.StatusBadRequest, "parameters are required")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	set := func(node valkey.Client) error {
+		builder := node.B().ConfigSet().ParameterValue()
+		for param, value := range req.Parameters {
+			builder = builder.ParameterValue(param, value)
+		}
+		return node.Do(ctx, builder.Build()).Error()
+	}
+
+	if h.client.Mode() != valkey.ClientModeCluster {
+		if err := set(h.client); err != nil {
+			writeCommandError(w, err)
+			return
+		}
+	} else {
+		nodes, addrs := h.sortedNodes()
+		for _, addr := range addrs {
+			if err := set(nodes[addr]); err != nil {
+				writeCommandError(w, err)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "updated", "parameters": req.Parameters})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// scanKeys collects up to limit keys matching pattern. SCAN only walks the
+// keyspace of the node it is sent to, so in cluster mode every node is
+// scanned in turn.
+func (h *Handlers) scanKeys(ctx context.Context, pattern string, limit int) ([]string, error) {
+	if h.client.Mode() != valkey.ClientModeCluster {
+		return scanNode(ctx, h.client, pattern, limit)
+	}
+
+	// Walk nodes in a stable order so repeated listings are consistent
+	nodes, addrs := h.sortedNodes()
+
+	keys := []string{}
+	// Replicas hold copies of their primary's keys
+	seen := make(map[string]struct{})
+	for _, addr := range addrs {
+		nodeKeys, err := scanNode(ctx, nodes[addr], pattern, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range nodeKeys {
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+			if len(keys) >= limit {
+				return keys, nil
+			}
+		}
+	}
+	return keys, nil
+}
+
+// sortedNodes returns the cluster's nodes and their addresses in a stable order.
+func (h *Handlers) sortedNodes() (map[string]valkey.Client, []string) {
+	nodes := h.client.Nodes()
+	addrs := make([]string, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return nodes, addrs
+}
+
+// scanNode runs SCAN against a single node until limit keys are found or the
+// cursor wraps around.
+func scanNode(ctx context.Context, client valkey.Client, pattern string, limit int) ([]string, error) {
+	cursor := uint64(0)
+	keys := []string{}
+
+	for {
+		result, err := client.Do(ctx, client.B().Scan().Cursor(cursor).Match(pattern).Count(int64(limit)).Build()).AsScanEntry()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, result.Elements...)
+		cursor = result.Cursor
+
+		if cursor == 0 || len(keys) >= limit {
+			break
+		}
+	}
+
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
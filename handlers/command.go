@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+)
+
+// connectionStateCommands change the state of the pooled connection they run
+// on, or never return, so they are refused whatever the configuration.
+var connectionStateCommands = map[string]bool{
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "PUNSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+	"MONITOR": true, "SYNC": true, "PSYNC": true,
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+	"SELECT": true, "AUTH": true, "HELLO": true, "RESET": true, "QUIT": true,
+	"CLIENT REPLY": true, "CLIENT TRACKING": true,
+}
+
+type CommandRequest struct {
+	Args []json.RawMessage `json:"args"` // Command name followed by its arguments
+}
+
+// CommandPolicy decides which commands the passthrough endpoint may run.
+// Entries are command names such as "GET", or a command and subcommand such
+// as "CONFIG GET".
+type CommandPolicy struct {
+	allow map[string]bool // Empty allows everything not denied
+	deny  map[string]bool
+}
+
+func parseCommandList(list string) map[string]bool {
+	commands := make(map[string]bool)
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.Join(strings.Fields(strings.ToUpper(entry)), " "); entry != "" {
+			commands[entry] = true
+		}
+	}
+	return commands
+}
+
+// NewCommandPolicy builds a policy from comma-separated allow and deny lists.
+func NewCommandPolicy(allow, deny string) *CommandPolicy {
+	return &CommandPolicy{allow: parseCommandList(allow), deny: parseCommandList(deny)}
+}
+
+// Allowed reports whether a command may run. The deny list wins over the
+// allow list.
+func (cp *CommandPolicy) Allowed(args []string) bool {
+	return cp.allowed(args, nil)
+}
+
+// allowed is Allowed, letting through the connection state commands in
+// exempt, which the caller runs on a connection of its own.
+func (cp *CommandPolicy) allowed(args []string, exempt map[string]bool) bool {
+	names := []string{strings.ToUpper(args[0])}
+	if len(args) > 1 {
+		names = append(names, names[0]+" "+strings.ToUpper(args[1]))
+	}
+
+	allowed := len(cp.allow) == 0
+	for _, name := range names {
+		if (connectionStateCommands[name] && !exempt[name]) || cp.deny[name] {
+			return false
+		}
+		if cp.allow[name] {
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// arbitraryCommand builds a command from raw arguments. The second argument
+// is taken as the key for cluster routing, which holds for nearly every
+// keyed command; MOVED redirects cover the rest.
+func arbitraryCommand(client valkey.CoreClient, args []string) valkey.Completed {
+	if len(args) < 2 {
+		return client.B().Arbitrary(args...).Build()
+	}
+	return client.B().Arbitrary(args[0]).Keys(args[1]).Args(args[2:]...).Build()
+}
+
+// HandleCommand runs a single command the typed endpoints don't cover.
+func (h *Handlers) HandleCommand(w http.ResponseWriter, r *http.Request) {
+	// As on the WebSocket gateway, arbitrary commands can't be confined to a
+	// namespace or to key patterns
+	if RequestNamespace(r) != "" {
+		writeError(w, http.StatusBadRequest, "namespaces are not supported for arbitrary commands")
+		return
+	}
+	if p := auth.FromContext(r.Context()); p != nil && len(p.KeyPatterns) > 0 {
+		writeError(w, http.StatusForbidden, "tokens restricted to key patterns cannot run arbitrary commands")
+		return
+	}
+
+	var req CommandRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	args, err := stringArgs(req.Args)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(args) == 0 || args[0] == "" {
+		writeError(w, http.StatusBadRequest, "args must start with a command name")
+		return
+	}
+
+	if !h.commands.Allowed(args) {
+		writeError(w, http.StatusForbidden, "command not allowed")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	result, err := h.client.Do(ctx, arbitraryCommand(h.client, args)).ToAny()
+	if err != nil && !valkey.IsValkeyNil(err) {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// No client: a command that got past the policy would panic
+var testPolicyHandlers = &Handlers{commands: NewCommandPolicy("", "FLUSHALL,CONFIG SET")}
+
+func TestCommandPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name, allow, deny string
+		args              []string
+		want              bool
+	}{
+		{"allowed by default", "", "", []string{"GET", "k"}, true},
+		{"denied", "", "FLUSHALL", []string{"flushall"}, false},
+		{"denied subcommand", "", "CONFIG SET", []string{"CONFIG", "set", "x", "1"}, false},
+		{"other subcommand", "", "CONFIG SET", []string{"CONFIG", "GET", "x"}, true},
+		{"not on the allow list", "GET", "", []string{"SET", "k", "v"}, false},
+		{"on the allow list", "GET", "", []string{"get", "k"}, true},
+		{"deny wins over allow", "GET", "GET", []string{"GET", "k"}, false},
+		{"connection state", "", "", []string{"SELECT", "1"}, false},
+		{"connection state even when allowed", "MULTI", "", []string{"MULTI"}, false},
+		{"connection state subcommand", "", "", []string{"CLIENT", "REPLY", "OFF"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NewCommandPolicy(tc.allow, tc.deny).Allowed(tc.args); got != tc.want {
+				t.Errorf("Allowed(%q) = %t, want %t", tc.args, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleCommandPolicy(t *testing.T) {
+	for _, body := range []string{
+		`{"args":["FLUSHALL"]}`,
+		`{"args":["CONFIG","SET","maxmemory","1"]}`,
+		`{"args":["SELECT",1]}`,
+		`{"args":["SUBSCRIBE","news"]}`,
+	} {
+		rec := httptest.NewRecorder()
+		testPolicyHandlers.HandleCommand(rec, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(body)))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s = %d, want 403: %s", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestHandleTransactionPolicy(t *testing.T) {
+	for _, body := range []string{
+		`{"commands":[["SET","k","v"],["FLUSHALL"]]}`,
+		`{"commands":[["INCR","k"],["CONFIG","SET","maxmemory","1"]]}`,
+		`{"commands":[["EXEC"]]}`,
+		`{"commands":[["SELECT",1],["SET","k","v"]]}`,
+	} {
+		rec := httptest.NewRecorder()
+		testPolicyHandlers.HandleTransaction(rec, httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body)))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s = %d, want 403: %s", body, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressedMagic marks a stored value as compressed. It is followed by one
+// byte naming the algorithm and then the compressed data.
+const compressedMagic = "\x00vrc"
+
+const (
+	compressGzip = 'g'
+	compressZstd = 'z'
+	// compressNone escapes an uncompressed value that happens to start with
+	// compressedMagic, so it isn't taken for a compressed one.
+	compressNone = 'n'
+)
+
+// errDecompressedTooLarge is returned for a value that decompresses to more
+// than the compressor's limit.
+var errDecompressedTooLarge = errors.New("decompressed value too large")
+
+// ValueCompressor compresses stored values above a size threshold and
+// transparently decompresses them on read. Compressed values can always be
+// read back, even after compression has been turned off.
+type ValueCompressor struct {
+	algorithm byte // 0 when compression of new values is disabled
+	threshold int
+	maxSize   int64 // Largest value Decode returns
+
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+}
+
+// NewValueCompressor returns a compressor for "gzip", "zstd" or "none".
+// Values that decompress to more than maxSize bytes, which no request could
+// have stored, fail to decode rather than being inflated in memory.
+func NewValueCompressor(algorithm string, threshold int, maxSize int64) (*ValueCompressor, error) {
+	c := &ValueCompressor{threshold: threshold, maxSize: maxSize}
+	switch strings.ToLower(algorithm) {
+	case "", "none":
+	case "gzip":
+		c.algorithm = compressGzip
+	case "zstd":
+		c.algorithm = compressZstd
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", algorithm)
+	}
+
+	var err error
+	if c.zstdEncoder, err = zstd.NewWriter(nil); err != nil {
+		return nil, err
+	}
+	// DecodeAll has no reader to limit, so the decoder enforces maxSize
+	if c.zstdDecoder, err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize))); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Encode returns the value to store, compressed when it is large enough and
+// compression actually makes it smaller. Values starting with
+// compressedMagic are escaped whether or not compression is enabled.
+func (c *ValueCompressor) Encode(value string) string {
+	if c.algorithm == 0 || len(value) < c.threshold {
+		return c.escape(value)
+	}
+
+	var compressed []byte
+	switch c.algorithm {
+	case compressGzip:
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		io.WriteString(gz, value)
+		if err := gz.Close(); err != nil {
+			return value
+		}
+		compressed = buf.Bytes()
+	case compressZstd:
+		compressed = c.zstdEncoder.EncodeAll([]byte(value), nil)
+	}
+
+	if len(compressed)+len(compressedMagic)+1 >= len(value) {
+		return c.escape(value)
+	}
+	return compressedMagic + string(c.algorithm) + string(compressed)
+}
+
+// escape marks an uncompressed value that starts with compressedMagic.
+func (c *ValueCompressor) escape(value string) string {
+	if strings.HasPrefix(value, compressedMagic) {
+		return compressedMagic + string(compressNone) + value
+	}
+	return value
+}
+
+// Decode reverses Encode. Values without the marker are returned unchanged.
+func (c *ValueCompressor) Decode(value string) (string, error) {
+	if len(value) <= len(compressedMagic) || !strings.HasPrefix(value, compressedMagic) {
+		return value, nil
+	}
+
+	data := value[len(compressedMagic)+1:]
+	switch value[len(compressedMagic)] {
+	case compressGzip:
+		gz, err := gzip.NewReader(strings.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("decompress gzip value: %w", err)
+		}
+		defer gz.Close()
+		out, err := io.ReadAll(io.LimitReader(gz, c.maxSize+1))
+		if err != nil {
+			return "", fmt.Errorf("decompress gzip value: %w", err)
+		}
+		if int64(len(out)) > c.maxSize {
+			return "", errDecompressedTooLarge
+		}
+		return string(out), nil
+	case compressZstd:
+		out, err := c.zstdDecoder.DecodeAll([]byte(data), nil)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+			return "", errDecompressedTooLarge
+		}
+		if err != nil {
+			return "", fmt.Errorf("decompress zstd value: %w", err)
+		}
+		return string(out), nil
+	case compressNone:
+		return data, nil
+	}
+	return value, nil
+}
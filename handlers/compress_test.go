@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestValueCompressorEscapesMarker(t *testing.T) {
+	for _, algorithm := range []string{"none", "gzip", "zstd"} {
+		c, err := NewValueCompressor(algorithm, 16, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, value := range []string{
+			compressedMagic + "g not gzip",
+			compressedMagic + "n",
+			compressedMagic + strings.Repeat("x", 100), // Compressible
+			compressedMagic,
+			"plain",
+		} {
+			got, err := c.Decode(c.Encode(value))
+			if err != nil || got != value {
+				t.Errorf("%s: round trip of %q = %q, %v", algorithm, value, got, err)
+			}
+		}
+	}
+}
+
+func TestValueCompressorLimitsDecompressedSize(t *testing.T) {
+	const limit = 1 << 10
+	c, err := NewValueCompressor("none", 0, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := strings.Repeat("a", limit+1)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(large))
+	zw.Close()
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zs := enc.EncodeAll([]byte(large), nil)
+
+	for name, stored := range map[string]string{
+		"gzip": compressedMagic + string(compressGzip) + gz.String(),
+		"zstd": compressedMagic + string(compressZstd) + string(zs),
+	} {
+		if _, err := c.Decode(stored); !errors.Is(err, errDecompressedTooLarge) {
+			t.Errorf("%s: Decode = %v, want %v", name, err, errDecompressedTooLarge)
+		}
+	}
+
+	// At the limit is fine
+	ok, err := NewValueCompressor("gzip", 0, limit+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ok.Decode(ok.Encode(large)); err != nil || got != large {
+		t.Errorf("value at the limit: %v", err)
+	}
+}
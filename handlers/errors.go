@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// ProblemContentType is the media type of error responses.
+const ProblemContentType = "application/problem+json"
+
+// Error codes tell clients why a request failed without parsing messages.
+// They are part of the API and don't change between releases.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeKeyNotFound        = "key_not_found"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodePayloadTooLarge    = "payload_too_large"
+	CodeRateLimited        = "rate_limited"
+	CodeQuotaExceeded      = "quota_exceeded"
+	CodeReadOnly           = "read_only"
+	CodeOverloaded         = "overloaded"
+	CodeTimeout            = "timeout"
+	CodeInternal           = "internal_error"
+	CodeValkeyUnavailable  = "valkey_unavailable"
+	CodeValkeyReadOnly     = "valkey_read_only"
+	CodeValkeyError        = "valkey_error"
+	CodeWrongType          = "wrong_type"
+	CodeOutOfMemory        = "out_of_memory"
+)
+
+// ErrorResponse is an RFC 7807 problem details body. Error repeats Detail
+// for clients written against the earlier {"error": "..."} bodies.
+type ErrorResponse struct {
+	Type   string `json:"type"` // urn:valkey-rest:error:<code>
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+	Error  string `json:"error"`
+}
+
+// NewErrorResponse returns the problem details for a failed request.
+func NewErrorResponse(status int, code, detail string) ErrorResponse {
+	return ErrorResponse{
+		Type:   "urn:valkey-rest:error:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+		Error:  detail,
+	}
+}
+
+// statusCode is the error code used for a status when nothing more specific
+// applies.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeValkeyUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// WriteError writes a problem details response with the given code.
+func WriteError(w http.ResponseWriter, status int, code, detail string) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(NewErrorResponse(status, code, detail))
+}
+
+// writeError writes a problem details response with the status's code.
+func writeError(w http.ResponseWriter, status int, detail string) {
+	WriteError(w, status, statusCode(status), detail)
+}
+
+// writeKeyNotFound reports a missing key.
+func writeKeyNotFound(w http.ResponseWriter) {
+	WriteError(w, http.StatusNotFound, CodeKeyNotFound, "key not found")
+}
+
+// writeCommandError reports a failed command. Error replies from Valkey are
+// passed on: those about the server's state, such as OOM or LOADING, with a
+// 5xx status and the rest, such as WRONGTYPE or CROSSSLOT, which the request
+// caused, with a 4xx. Timeouts and connection failures are told apart from
+// internal errors.
+func writeCommandError(w http.ResponseWriter, err error) {
+	status, code := commandErrorCode(err)
+	detail := "internal server error"
+	switch {
+	case code == CodeTimeout:
+		detail = "Valkey did not answer in time"
+	case code == CodeValkeyUnavailable && !isValkeyReply(err):
+		detail = "Valkey is unavailable"
+	case status < 500 || isValkeyReply(err):
+		detail = err.Error()
+	}
+	WriteError(w, status, code, detail)
+}
+
+// WriteCommandError is writeCommandError for handlers outside this package.
+func WriteCommandError(w http.ResponseWriter, err error) {
+	writeCommandError(w, err)
+}
+
+func isValkeyReply(err error) bool {
+	_, ok := valkey.IsValkeyErr(err)
+	return ok
+}
+
+// commandErrorCode classifies an error from a Valkey command.
+func commandErrorCode(err error) (int, string) {
+	if verr, ok := valkey.IsValkeyErr(err); ok {
+		prefix, _, _ := strings.Cut(verr.Error(), " ")
+		switch prefix {
+		case "WRONGTYPE":
+			return http.StatusConflict, CodeWrongType
+		case "OOM":
+			return http.StatusInsufficientStorage, CodeOutOfMemory
+		case "READONLY":
+			return http.StatusServiceUnavailable, CodeValkeyReadOnly
+		case "LOADING", "BUSY", "MASTERDOWN", "CLUSTERDOWN", "TRYAGAIN":
+			return http.StatusServiceUnavailable, CodeValkeyUnavailable
+		}
+		return http.StatusBadRequest, CodeValkeyError
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, CodeTimeout
+	case errors.As(err, &netErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, valkey.ErrClosing):
+		return http.StatusServiceUnavailable, CodeValkeyUnavailable
+	}
+	return http.StatusInternalServerError, CodeInternal
+}
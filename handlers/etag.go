@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// etagFor returns a strong ETag for a value as stored in Valkey. It is the
+// SHA-1 of the stored bytes, which is also what the Store compares against
+// for conditional writes.
+func etagFor(stored string) string {
+	sum := sha1.Sum([]byte(stored))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// parseETags splits an If-Match or If-None-Match header into opaque tags
+// without quotes; "*" is returned as is. With weak comparison, used by
+// If-None-Match, a W/ prefix is ignored. Strong comparison, used by
+// If-Match, drops weak tags, which can never match (RFC 9110, 13.1.1-2).
+func parseETags(header string, weak bool) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		switch {
+		case tag == "*":
+			tags = append(tags, tag)
+		case len(tag) >= 2 && tag[0] == '"' && tag[len(tag)-1] == '"':
+			tags = append(tags, tag[1:len(tag)-1])
+		}
+	}
+	return tags
+}
+
+// etagMatches reports whether etag matches any of the tags from parseETags.
+func etagMatches(etag string, tags []string) bool {
+	for _, tag := range tags {
+		if tag == "*" || `"`+tag+`"` == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchTags returns the tags of the request's If-Match header, or nil when
+// the header is absent.
+func ifMatchTags(r *http.Request) []string {
+	if r.Header.Get("If-Match") == "" {
+		return nil
+	}
+	tags := parseETags(r.Header.Get("If-Match"), false)
+	if tags == nil {
+		// A header with only weak tags can never match
+		tags = []string{}
+	}
+	return tags
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+)
+
+const exportBatch = 500
+
+// ExportRecord is one line of an export: a key with its DUMP payload, which
+// carries the value in Valkey's own serialization format.
+type ExportRecord struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	TTL   int64  `json:"ttl_ms"` // Remaining time to live in ms, -1 if the key doesn't expire
+	Value string `json:"value"`  // Base64 of the DUMP payload
+}
+
+// HandleExport streams every key matching pattern as newline-delimited JSON.
+// Keys that disappear while the export runs are skipped.
+func (h *Handlers) HandleExport(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	ctx := r.Context()
+	principal := auth.FromContext(ctx)
+
+	w.Header().Set("Content-Disposition", `attachment; filename="export.ndjson"`)
+	stream, ok := startNDJSON(w)
+	if !ok {
+		return
+	}
+
+	cursor := "0"
+	for {
+		storedKeys, next, err := h.store.Scan(ctx, scanPattern(r, pattern), cursor, exportBatch)
+		if err != nil {
+			stream.fail("export failed: ", err)
+			return
+		}
+
+		keys := make([]string, 0, len(storedKeys))
+		cmds := make(valkey.Commands, 0, len(storedKeys)*3)
+		for _, storedKey := range storedKeys {
+			key := stripNamespace(r, storedKey)
+			if ReservedKey(key) || (principal != nil && !principal.CanAccessKey(storedKey)) {
+				continue
+			}
+			keys = append(keys, storedKey)
+			cmds = append(cmds,
+				h.client.B().Type().Key(storedKey).Build(),
+				h.client.B().Pttl().Key(storedKey).Build(),
+				h.client.B().Dump().Key(storedKey).Build(),
+			)
+		}
+
+		if len(cmds) > 0 {
+			resps := h.client.DoMulti(ctx, cmds...)
+			for i, storedKey := range keys {
+				keyType, err := resps[i*3].ToString()
+				if err != nil {
+					stream.fail("export failed: ", err)
+					return
+				}
+				ttl, err := resps[i*3+1].AsInt64()
+				if err != nil {
+					stream.fail("export failed: ", err)
+					return
+				}
+				dump, err := resps[i*3+2].ToString()
+				if valkey.IsValkeyNil(err) || keyType == "none" || ttl == -2 {
+					continue
+				}
+				if err != nil {
+					stream.fail("export failed: ", err)
+					return
+				}
+
+				stream.write(ExportRecord{
+					Key:   stripNamespace(r, storedKey),
+					Type:  keyType,
+					TTL:   ttl,
+					Value: base64.StdEncoding.EncodeToString([]byte(dump)),
+				})
+			}
+			if !stream.flush() {
+				return
+			}
+		}
+
+		if cursor = next; cursor == "0" {
+			return
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// keyInfoScript reads a key's type, TTL and size in one round trip, and its
+// value if ARGV[1] is "1" and it is a string. Size is the length in bytes of
+// a string and the number of elements of anything else, or -1 for types it
+// doesn't know, such as module types.
+//
+// KEYS[1] key. Returns {type, pttl, size[, value]}, with type "none" for a
+// missing key.
+var keyInfoScript = valkey.NewLuaScriptReadOnly(`
+local t = redis.call('TYPE', KEYS[1])['ok']
+if t == 'none' then
+  return {t, -2, 0}
+end
+local size = -1
+if t == 'string' then
+  size = redis.call('STRLEN', KEYS[1])
+elseif t == 'list' then
+  size = redis.call('LLEN', KEYS[1])
+elseif t == 'set' then
+  size = redis.call('SCARD', KEYS[1])
+elseif t == 'zset' then
+  size = redis.call('ZCARD', KEYS[1])
+elseif t == 'hash' then
+  size = redis.call('HLEN', KEYS[1])
+elseif t == 'stream' then
+  size = redis.call('XLEN', KEYS[1])
+end
+local info = {t, redis.call('PTTL', KEYS[1]), size}
+if ARGV[1] == '1' and t == 'string' then
+  info[4] = redis.call('GET', KEYS[1])
+end
+return info
+`)
+
+// KeyInfo is a key in a listing along with the fields ?include= or ?fields=
+// asked for.
+type KeyInfo struct {
+	Key      string  `json:"key"`
+	Value    *string `json:"value,omitempty"`    // Strings only
+	Encoding string  `json:"encoding,omitempty"` // "base64" when the value isn't valid UTF-8
+	Type     string  `json:"type,omitempty"`
+	TTL      *int64  `json:"ttl_ms,omitempty"` // -1 if the key doesn't expire
+	Size     *int64  `json:"size,omitempty"`   // Bytes of a string, elements of anything else
+}
+
+// responseFields are the fields of a key a response carries.
+type responseFields struct {
+	key, value, keyType, ttl, size bool
+	// explicit is set by ?fields=, which leaves out everything not named
+	explicit bool
+}
+
+// meta reports whether any field needs more than the value.
+func (f responseFields) meta() bool {
+	return f.keyType || f.ttl || f.size
+}
+
+// has reports whether a JSON field of KeyInfo or GetResponse is wanted.
+func (f responseFields) has(name string) bool {
+	switch name {
+	case "key":
+		return f.key
+	case "value", "encoding":
+		return f.value
+	case "type":
+		return f.keyType
+	case "ttl_ms":
+		return f.ttl
+	case "size":
+		return f.size
+	}
+	return false
+}
+
+// parseResponseFields applies ?include=, which adds fields to the defaults,
+// and ?fields=, which replaces them, writing a 400 response and returning
+// false for unknown names.
+func parseResponseFields(w http.ResponseWriter, r *http.Request, defaults responseFields) (responseFields, bool) {
+	f := defaults
+	query := r.URL.Query()
+	if query.Has("fields") {
+		f = responseFields{explicit: true}
+	}
+	for _, param := range []string{"fields", "include"} {
+		for _, name := range strings.Split(query.Get(param), ",") {
+			switch strings.TrimSpace(name) {
+			case "":
+			case "key":
+				f.key = true
+			case "value":
+				f.value = true
+			case "type":
+				f.keyType = true
+			case "ttl":
+				f.ttl = true
+			case "size":
+				f.size = true
+			default:
+				writeError(w, http.StatusBadRequest, param+" must list key, value, type, ttl or size")
+				return f, false
+			}
+		}
+	}
+	return f, true
+}
+
+// filter returns v, a struct with the JSON fields of KeyInfo, trimmed to
+// the fields asked for with ?fields=.
+func (f responseFields) filter(v interface{}) interface{} {
+	if !f.explicit {
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return v
+	}
+	for name := range fields {
+		if !f.has(name) {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// keyInfo reads the type, TTL and size of stored keys, and their values if
+// withValue is set, with one script call each. Keys that no longer exist
+// come back with a nil entry.
+func (h *Handlers) keyInfo(ctx context.Context, storedKeys []string, withValue bool) ([]*KeyInfo, error) {
+	if len(storedKeys) == 0 {
+		return nil, nil
+	}
+	flag := "0"
+	if withValue {
+		flag = "1"
+	}
+	execs := make([]valkey.LuaExec, len(storedKeys))
+	for i, key := range storedKeys {
+		execs[i] = valkey.LuaExec{Keys: []string{key}, Args: []string{flag}}
+	}
+
+	infos := make([]*KeyInfo, len(storedKeys))
+	for i, resp := range keyInfoScript.ExecMulti(ctx, h.client, execs...) {
+		fields, err := resp.ToArray()
+		if err != nil {
+			return nil, err
+		}
+		keyType, _ := fields[0].ToString()
+		if keyType == "none" {
+			continue
+		}
+		ttl, _ := fields[1].AsInt64()
+		info := &KeyInfo{Key: storedKeys[i], Type: keyType, TTL: &ttl}
+		if size, _ := fields[2].AsInt64(); size >= 0 {
+			info.Size = &size
+		}
+		if len(fields) > 3 {
+			value, _ := fields[3].ToString()
+			if value, err = h.compressor.Decode(value); err != nil {
+				return nil, err
+			}
+			info.Value = &value
+			if !utf8.ValidString(value) {
+				encoded := base64.StdEncoding.EncodeToString([]byte(value))
+				info.Value, info.Encoding = &encoded, "base64"
+			}
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
+
+// requireValkey writes a 400 response and returns false if fields need to
+// be read with keyInfo but the handlers run on another Store.
+func (h *Handlers) requireValkey(w http.ResponseWriter, need bool) bool {
+	if need && h.client == nil {
+		writeError(w, http.StatusBadRequest, "these fields need the valkey backend")
+		return false
+	}
+	return true
+}
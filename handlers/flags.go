@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	flagKeyPrefix = "valkey-rest:flag:"
+	// flagIndexKey is a set of every flag's name, for listing them
+	flagIndexKey = "valkey-rest:flags"
+	maxFlagUsers = 10000
+	// rolloutBuckets is how finely percentage rollouts are divided, allowing
+	// percentages with two decimal places
+	rolloutBuckets = 10000
+)
+
+// Flag types.
+const (
+	FlagBoolean    = "boolean"    // On or off for everyone
+	FlagPercentage = "percentage" // On for a stable share of users
+	FlagTargeted   = "targeted"   // On for listed users, and optionally a share of the rest
+)
+
+// validFlagName keeps flag names to the characters lock names allow.
+var validFlagName = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,200}$`)
+
+type FlagRequest struct {
+	Type        string   `json:"type"`
+	Enabled     bool     `json:"enabled"`              // A disabled flag is off for everyone
+	Percentage  float64  `json:"percentage,omitempty"` // 0-100, for percentage and targeted flags
+	Users       []string `json:"users,omitempty"`      // For targeted flags
+	Description string   `json:"description,omitempty"`
+}
+
+type Flag struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Enabled     bool      `json:"enabled"`
+	Percentage  float64   `json:"percentage,omitempty"`
+	Users       []string  `json:"users,omitempty"`
+	Description string    `json:"description,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type FlagEvaluation struct {
+	Flag    string `json:"flag"`
+	User    string `json:"user,omitempty"`
+	Enabled bool   `json:"enabled"`
+	// Why: "disabled", "enabled", "targeted", "rollout" or "not_targeted"
+	Reason string `json:"reason"`
+}
+
+// flagName validates the {name} path value and returns the flag's key,
+// writing a 400 response if the name is invalid.
+func flagName(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	name := r.PathValue("name")
+	if !validFlagName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "flag name must be 1-200 letters, digits or _.:-")
+		return "", "", false
+	}
+	return name, namespacedKey(r, flagKeyPrefix+name), true
+}
+
+// parseFlag decodes a flag stored as a hash, returning nil for an empty
+// hash, which is how a missing flag reads.
+func parseFlag(name string, fields map[string]string) *Flag {
+	if len(fields) == 0 {
+		return nil
+	}
+	f := &Flag{
+		Name:        name,
+		Type:        fields["type"],
+		Enabled:     fields["enabled"] == "1",
+		Description: fields["description"],
+	}
+	f.Percentage, _ = strconv.ParseFloat(fields["percentage"], 64)
+	json.Unmarshal([]byte(fields["users"]), &f.Users)
+	if ms, err := strconv.ParseInt(fields["updated_at"], 10, 64); err == nil {
+		f.UpdatedAt = time.UnixMilli(ms).UTC()
+	}
+	return f
+}
+
+// rolloutBucket places a user in one of rolloutBuckets buckets. It depends
+// only on the flag and user, so every instance gives a user the same answer,
+// and raising a flag's percentage only ever adds users.
+func rolloutBucket(flag, user string) uint64 {
+	sum := sha1.Sum([]byte(flag + ":" + user))
+	return binary.BigEndian.Uint64(sum[:8]) % rolloutBuckets
+}
+
+// evaluate decides whether the flag is on for user.
+func (f *Flag) evaluate(user string) (bool, string) {
+	if !f.Enabled {
+		return false, "disabled"
+	}
+	switch f.Type {
+	case FlagBoolean:
+		return true, "enabled"
+	case FlagTargeted:
+		if slices.Contains(f.Users, user) {
+			return true, "targeted"
+		}
+		if f.Percentage == 0 {
+			return false, "not_targeted"
+		}
+	}
+	return rolloutBucket(f.Name, user) < uint64(f.Percentage*rolloutBuckets/100), "rollout"
+}
+
+// HandleEvaluateFlag reports whether a flag is on for the user in ?user=,
+// which percentage and targeted flags require.
+func (h *Handlers) HandleEvaluateFlag(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := flagName(w, r)
+	if !ok {
+		return
+	}
+	user := r.URL.Query().Get("user")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	fields, err := h.client.Do(ctx, h.client.B().Hgetall().Key(key).Build()).AsStrMap()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	flag := parseFlag(name, fields)
+	if flag == nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+	if user == "" && flag.Type != FlagBoolean {
+		writeError(w, http.StatusBadRequest, "user is required for "+flag.Type+" flags")
+		return
+	}
+
+	enabled, reason := flag.evaluate(user)
+	json.NewEncoder(w).Encode(FlagEvaluation{Flag: name, User: user, Enabled: enabled, Reason: reason})
+}
+
+// HandlePutFlag creates or replaces a flag.
+func (h *Handlers) HandlePutFlag(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := flagName(w, r)
+	if !ok {
+		return
+	}
+
+	var req FlagRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	switch req.Type {
+	case FlagBoolean:
+		if req.Percentage != 0 || len(req.Users) > 0 {
+			writeError(w, http.StatusBadRequest, "boolean flags take neither percentage nor users")
+			return
+		}
+	case FlagPercentage:
+		if len(req.Users) > 0 {
+			writeError(w, http.StatusBadRequest, "users are only used by targeted flags")
+			return
+		}
+	case FlagTargeted:
+		if len(req.Users) == 0 {
+			writeError(w, http.StatusBadRequest, "users are required for targeted flags")
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "type must be boolean, percentage or targeted")
+		return
+	}
+	if req.Percentage < 0 || req.Percentage > 100 {
+		writeError(w, http.StatusBadRequest, "percentage must be between 0 and 100")
+		return
+	}
+	if len(req.Users) > maxFlagUsers {
+		writeError(w, http.StatusBadRequest, "a flag can target at most 10000 users")
+		return
+	}
+
+	flag := Flag{
+		Name:        name,
+		Type:        req.Type,
+		Enabled:     req.Enabled,
+		Percentage:  req.Percentage,
+		Users:       req.Users,
+		Description: req.Description,
+		UpdatedAt:   time.UnixMilli(time.Now().UnixMilli()).UTC(),
+	}
+	enabled := "0"
+	if flag.Enabled {
+		enabled = "1"
+	}
+	users, _ := json.Marshal(flag.Users)
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	// Every field is written, so one HSET replaces the whole flag atomically
+	for _, resp := range h.client.DoMulti(ctx,
+		h.client.B().Hset().Key(key).FieldValue().
+			FieldValue("type", flag.Type).
+			FieldValue("enabled", enabled).
+			FieldValue("percentage", strconv.FormatFloat(flag.Percentage, 'f', -1, 64)).
+			FieldValue("users", string(users)).
+			FieldValue("description", flag.Description).
+			FieldValue("updated_at", strconv.FormatInt(flag.UpdatedAt.UnixMilli(), 10)).
+			Build(),
+		h.client.B().Sadd().Key(namespacedKey(r, flagIndexKey)).Member(name).Build(),
+	) {
+		if err := resp.Error(); err != nil {
+			writeCommandError(w, err)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(flag)
+}
+
+// HandleGetFlag returns a flag's definition.
+func (h *Handlers) HandleGetFlag(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := flagName(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	fields, err := h.client.Do(ctx, h.client.B().Hgetall().Key(key).Build()).AsStrMap()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	flag := parseFlag(name, fields)
+	if flag == nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(flag)
+}
+
+// HandleListFlags returns every flag, sorted by name.
+func (h *Handlers) HandleListFlags(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	names, err := h.client.Do(ctx, h.client.B().Smembers().Key(namespacedKey(r, flagIndexKey)).Build()).AsStrSlice()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	slices.Sort(names)
+
+	flags := make([]*Flag, 0, len(names))
+	if len(names) > 0 {
+		cmds := make(valkey.Commands, len(names))
+		for i, name := range names {
+			cmds[i] = h.client.B().Hgetall().Key(namespacedKey(r, flagKeyPrefix+name)).Build()
+		}
+		for i, resp := range h.client.DoMulti(ctx, cmds...) {
+			fields, err := resp.AsStrMap()
+			if err != nil {
+				writeCommandError(w, err)
+				return
+			}
+			// A flag deleted while the index was read is left out
+			if flag := parseFlag(names[i], fields); flag != nil {
+				flags = append(flags, flag)
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"flags": flags, "count": len(flags)})
+}
+
+// HandleDeleteFlag deletes a flag; evaluating it afterwards returns 404.
+func (h *Handlers) HandleDeleteFlag(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := flagName(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	resps := h.client.DoMulti(ctx,
+		h.client.B().Del().Key(key).Build(),
+		h.client.B().Srem().Key(namespacedKey(r, flagIndexKey)).Member(name).Build(),
+	)
+	deleted, err := resps[0].AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if err := resps[1].Error(); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "flag": name})
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Valkey's geo index covers latitudes up to about ±85.05 degrees.
+const maxGeoLatitude = 85.05112878
+
+type GeoMember struct {
+	Member    string  `json:"member"`
+	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
+}
+
+type GeoAddRequest struct {
+	Members []GeoMember `json:"members"`
+}
+
+type GeoResult struct {
+	Member    string  `json:"member"`
+	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
+	Distance  float64 `json:"distance"` // In the search's unit
+}
+
+func validCoordinates(longitude, latitude float64) bool {
+	return longitude >= -180 && longitude <= 180 && latitude >= -maxGeoLatitude && latitude <= maxGeoLatitude
+}
+
+func (h *Handlers) HandleGeoAdd(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	var req GeoAddRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.Members) == 0 {
+		writeError(w, http.StatusBadRequest, "members are required")
+		return
+	}
+
+	cmd := h.client.B().Geoadd().Key(namespacedKey(r, key)).LongitudeLatitudeMember()
+	for _, m := range req.Members {
+		if m.Member == "" || !validCoordinates(m.Longitude, m.Latitude) {
+			writeError(w, http.StatusBadRequest, "each member needs a name, a longitude within ±180 and a latitude within ±85.05112878")
+			return
+		}
+		cmd = cmd.LongitudeLatitudeMember(m.Longitude, m.Latitude, m.Member)
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	added, err := h.client.Do(ctx, cmd.Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "added",
+		"key":    key,
+		"added":  added, // Members that were new; existing ones are moved
+	})
+}
+
+// HandleGeoSearch runs GEOSEARCH around a member or a point, within a radius
+// or a box, and returns the matches with their coordinates and distance.
+func (h *Handlers) HandleGeoSearch(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	q := r.URL.Query()
+
+	badRequest := func(msg string) {
+		writeError(w, http.StatusBadRequest, msg)
+	}
+	parseFloat := func(name string) (float64, bool) {
+		v, err := strconv.ParseFloat(q.Get(name), 64)
+		return v, err == nil
+	}
+
+	var args []string
+	switch {
+	case q.Get("member") != "":
+		args = append(args, "FROMMEMBER", q.Get("member"))
+	case q.Has("longitude") || q.Has("latitude"):
+		lon, ok1 := parseFloat("longitude")
+		lat, ok2 := parseFloat("latitude")
+		if !ok1 || !ok2 || !validCoordinates(lon, lat) {
+			badRequest("longitude and latitude must be valid coordinates")
+			return
+		}
+		args = append(args, "FROMLONLAT", q.Get("longitude"), q.Get("latitude"))
+	default:
+		badRequest("member or longitude and latitude are required")
+		return
+	}
+
+	unit := strings.ToLower(q.Get("unit"))
+	switch unit {
+	case "":
+		unit = "m"
+	case "m", "km", "ft", "mi":
+	default:
+		badRequest("unit must be one of m, km, ft or mi")
+		return
+	}
+
+	switch {
+	case q.Has("radius"):
+		if radius, ok := parseFloat("radius"); !ok || radius <= 0 {
+			badRequest("radius must be a positive number")
+			return
+		}
+		args = append(args, "BYRADIUS", q.Get("radius"), unit)
+	case q.Has("width") || q.Has("height"):
+		width, ok1 := parseFloat("width")
+		height, ok2 := parseFloat("height")
+		if !ok1 || !ok2 || width <= 0 || height <= 0 {
+			badRequest("width and height must be positive numbers")
+			return
+		}
+		args = append(args, "BYBOX", q.Get("width"), q.Get("height"), unit)
+	default:
+		badRequest("radius or width and height are required")
+		return
+	}
+
+	switch strings.ToLower(q.Get("sort")) {
+	case "", "asc":
+		args = append(args, "ASC")
+	case "desc":
+		args = append(args, "DESC")
+	default:
+		badRequest("sort must be asc or desc")
+		return
+	}
+
+	count := int64(100)
+	if v := q.Get("count"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > 1000 {
+			badRequest("count must be between 1 and 1000")
+			return
+		}
+		count = n
+	}
+	args = append(args, "COUNT", strconv.FormatInt(count, 10), "WITHCOORD", "WITHDIST")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	// The typed GEOSEARCH builder has a type per unit and shape combination,
+	// so the command is assembled directly
+	cmd := h.client.B().Arbitrary("GEOSEARCH").Keys(namespacedKey(r, key)).Args(args...).Build()
+	locations, err := h.client.Do(ctx, cmd).AsGeosearch()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	results := make([]GeoResult, 0, len(locations))
+	for _, loc := range locations {
+		results = append(results, GeoResult{
+			Member:    loc.Name,
+			Longitude: loc.Longitude,
+			Latitude:  loc.Latitude,
+			Distance:  loc.Dist,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":     key,
+		"unit":    unit,
+		"results": results,
+		"count":   len(results),
+	})
+}
@@ -0,0 +1,569 @@
+// Package handlers implements the REST endpoints. Authentication, rate
+// limiting and the other cross-cutting concerns are applied around them by
+// the server package.
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+	"valkey-rest/store"
+)
+
+// Handlers holds the dependencies of the REST endpoints.
+type Handlers struct {
+	client      valkey.Client
+	store       store.Store
+	apiKeys     *auth.APIKeyStore
+	webhooks    *WebhookStore
+	compressor  *ValueCompressor
+	scripts     *ScriptRegistry
+	commands    *CommandPolicy
+	db          int64 // Logical database the client is connected to
+	keyVersions int   // Versions kept per versioned key
+	sessionTTL  time.Duration
+	scheduleKey string // Sorted set of scheduled key jobs
+}
+
+// New returns the handlers for client. The plain /keys endpoints go through
+// st, so they also work without a Valkey client when st is another backend.
+// API keys are managed through apiKeys, which should be the store requests
+// are authenticated against. db is the logical database client is connected
+// to; requests may select another with store.WithDB. keyVersions is the
+// number of versions kept of keys written with versioned set, sessionTTL
+// the idle lifetime of sessions and scheduleKey the sorted set of scheduled
+// key jobs.
+func New(client valkey.Client, st store.Store, apiKeys *auth.APIKeyStore, compressor *ValueCompressor, scripts *ScriptRegistry, commands *CommandPolicy, db int64, keyVersions int, sessionTTL time.Duration, scheduleKey string) *Handlers {
+	return &Handlers{
+		client:      client,
+		store:       st,
+		apiKeys:     apiKeys,
+		webhooks:    NewWebhookStore(client),
+		compressor:  compressor,
+		scripts:     scripts,
+		commands:    commands,
+		db:          db,
+		keyVersions: keyVersions,
+		sessionTTL:  sessionTTL,
+		scheduleKey: scheduleKey,
+	}
+}
+
+// requestDB returns the logical database a request runs against.
+func (h *Handlers) requestDB(r *http.Request) int64 {
+	if db, ok := store.DBFromContext(r.Context()); ok {
+		return db
+	}
+	return h.db
+}
+
+// allowedDB reports whether a request may name db: the configured database
+// or one in VALKEY_DB_ALLOW.
+func (h *Handlers) allowedDB(db int64) bool {
+	if db == h.db {
+		return true
+	}
+	checker, ok := h.client.(interface{ HasDB(int64) bool })
+	return ok && checker.HasDB(db)
+}
+
+// clientFor returns the client for the database selected in ctx, for
+// connections that are dedicated before any command carries the context.
+func (h *Handlers) clientFor(ctx context.Context) valkey.Client {
+	if router, ok := h.client.(interface {
+		ForContext(context.Context) valkey.Client
+	}); ok {
+		return router.ForContext(ctx)
+	}
+	return h.client
+}
+
+// errMirrored is reported by key writes that go around the store, which
+// would leave a migration secondary out of step.
+const errMirrored = "not available while keys are mirrored to a migration secondary"
+
+// refuseMirrored writes a 409 response and returns true if the store is
+// mirrored.
+func (h *Handlers) refuseMirrored(w http.ResponseWriter) bool {
+	if store.IsMirrored(h.store) {
+		writeError(w, http.StatusConflict, errMirrored)
+		return true
+	}
+	return false
+}
+
+// invalidate drops cached copies of stored keys written directly through
+// the client rather than the store.
+func (h *Handlers) invalidate(storedKeys ...string) {
+	if c, ok := h.store.(store.Invalidator); ok {
+		c.Invalidate(storedKeys...)
+	}
+}
+
+type SetRequest struct {
+	Value      string `json:"value"`
+	Encoding   string `json:"encoding,omitempty"`   // "base64" for binary values
+	Expiration int64  `json:"expiration,omitempty"` // Expiration in seconds
+	Versioned  bool   `json:"versioned,omitempty"`  // Keep the value in the key's version history
+}
+
+type GetResponse struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"` // "base64" when the value isn't valid UTF-8
+	// With ?include=type,ttl,size
+	Type string `json:"type,omitempty"`
+	TTL  *int64 `json:"ttl_ms,omitempty"`
+	Size *int64 `json:"size,omitempty"` // Bytes stored, which may be compressed
+}
+
+func (h *Handlers) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	// Test Valkey connection
+	if err := h.store.Ping(ctx); err != nil {
+		writeError(w, http.StatusServiceUnavailable, "Valkey connection failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+}
+
+func (h *Handlers) HandleGet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+	fields, ok := parseResponseFields(w, r, responseFields{key: true, value: true})
+	if !ok || !h.requireValkey(w, fields.meta()) {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	result, err := h.store.Get(ctx, namespacedKey(r, key))
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeKeyNotFound(w)
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	etag := etagFor(result)
+	w.Header().Set("ETag", etag)
+	if tags := parseETags(r.Header.Get("If-None-Match"), true); etagMatches(etag, tags) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	result, err = h.compressor.Decode(result)
+	if err != nil {
+		log.Printf("Failed to decode value of %s: %v", key, err)
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if acceptsRaw(r) {
+		w.Header().Set("Content-Type", octetStream)
+		w.Header().Set("Content-Length", strconv.Itoa(len(result)))
+		io.WriteString(w, result)
+		return
+	}
+
+	// JSON strings can't carry arbitrary bytes, so binary values are base64 encoded
+	resp := GetResponse{Key: key, Value: result}
+	if !utf8.ValidString(result) {
+		resp.Value = base64.StdEncoding.EncodeToString([]byte(result))
+		resp.Encoding = "base64"
+	}
+	if fields.meta() {
+		infos, err := h.keyInfo(ctx, []string{namespacedKey(r, key)}, false)
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		// Left out if the key expired since it was read
+		if info := infos[0]; info != nil {
+			if fields.keyType {
+				resp.Type = info.Type
+			}
+			if fields.ttl {
+				resp.TTL = info.TTL
+			}
+			if fields.size {
+				resp.Size = info.Size
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fields.filter(resp))
+}
+
+// keyExists reports whether the request's key exists.
+func (h *Handlers) keyExists(r *http.Request, key string) (bool, error) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	return h.store.Exists(ctx, namespacedKey(r, key))
+}
+
+// HandleHead reports whether a key exists through the status code alone,
+// without transferring the value.
+func (h *Handlers) HandleHead(w http.ResponseWriter, r *http.Request) {
+	exists, err := h.keyExists(r, r.PathValue("key"))
+	switch {
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+	case !exists:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h *Handlers) HandleExists(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	exists, err := h.keyExists(r, key)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"key": key, "exists": exists})
+}
+
+func (h *Handlers) HandleSet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	var req SetRequest
+	if hasContentType(r, octetStream) {
+		// Raw bodies are stored as-is, with the expiration in the query string
+		body, ok := readRawBody(w, r)
+		if !ok {
+			return
+		}
+		req.Value = string(body)
+		if v := r.URL.Query().Get("expiration"); v != "" {
+			expiration, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || expiration < 0 {
+				writeError(w, http.StatusBadRequest, "expiration must be a non-negative integer")
+				return
+			}
+			req.Expiration = expiration
+		}
+		req.Versioned = r.URL.Query().Get("versioned") == "true"
+	} else {
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		switch req.Encoding {
+		case "":
+		case "base64":
+			value, err := base64.StdEncoding.DecodeString(req.Value)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "value is not valid base64")
+				return
+			}
+			req.Value = string(value)
+		default:
+			writeError(w, http.StatusBadRequest, "encoding must be base64 or omitted")
+			return
+		}
+	}
+
+	if req.Value == "" {
+		writeError(w, http.StatusBadRequest, "value is required")
+		return
+	}
+	// The versioned write script doesn't compare ETags, so a versioned write
+	// with If-Match would skip the precondition
+	if req.Versioned && ifMatchTags(r) != nil {
+		writeError(w, http.StatusBadRequest, "versioned writes can't be combined with If-Match")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	stored := h.compressor.Encode(req.Value)
+	if req.Versioned {
+		h.setVersioned(w, r, key, stored, time.Duration(req.Expiration)*time.Second)
+		return
+	}
+
+	// If-Match makes the write conditional on the current value, so
+	// concurrent read-modify-write cycles can't overwrite each other
+	if tags := ifMatchTags(r); tags != nil {
+		applied, err := h.store.CompareAndSet(ctx, namespacedKey(r, key), stored, time.Duration(req.Expiration)*time.Second, tags)
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		if !applied {
+			writeError(w, http.StatusPreconditionFailed, "precondition failed")
+			return
+		}
+		w.Header().Set("ETag", etagFor(stored))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"status": "created", "key": key})
+		return
+	}
+
+	// Expiration is in seconds
+	err := h.store.Set(ctx, namespacedKey(r, key), stored, time.Duration(req.Expiration)*time.Second)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etagFor(stored))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "created", "key": key})
+}
+
+func (h *Handlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	if tags := ifMatchTags(r); tags != nil {
+		applied, err := h.store.CompareAndDelete(ctx, namespacedKey(r, key), tags)
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		if !applied {
+			writeError(w, http.StatusPreconditionFailed, "precondition failed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "key": key})
+		return
+	}
+
+	deleted, err := h.store.Del(ctx, namespacedKey(r, key))
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	if !deleted {
+		writeKeyNotFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "key": key})
+}
+
+func (h *Handlers) HandleList(w http.ResponseWriter, r *http.Request) {
+	fields, ok := parseResponseFields(w, r, responseFields{key: true})
+	if !ok || !h.requireValkey(w, fields.value || fields.meta()) {
+		return
+	}
+
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	limitStr := r.URL.Query().Get("limit")
+	limit := 100 // default limit
+	if limitStr != "" {
+		fmt.Sscanf(limitStr, "%d", &limit)
+		if limit > 1000 || limit < 1 {
+			limit = 100
+		}
+	}
+
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		cursor = "0"
+	}
+
+	if acceptsNDJSON(r) {
+		h.streamKeys(w, r, pattern, cursor, limit, fields)
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	// One SCAN step per request keeps large keyspaces from hitting the
+	// timeout; clients follow the returned cursor until it is "0"
+	keys, next, err := h.store.Scan(ctx, scanPattern(r, pattern), cursor, limit)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	visible := visibleKeys(r, keys)
+	w.Header().Set("Content-Type", "application/json")
+	if !fields.explicit && !fields.value && !fields.meta() {
+		for i, key := range visible {
+			visible[i] = stripNamespace(r, key)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys":   visible,
+			"count":  len(visible),
+			"cursor": next,
+		})
+		return
+	}
+
+	// With ?include= or ?fields=, keys are listed as objects
+	items, err := h.listItems(ctx, r, visible, fields)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys":   items,
+		"count":  len(items),
+		"cursor": next,
+	})
+}
+
+// streamKeys follows the SCAN cursor to the end of the keyspace, writing
+// each key as a line of NDJSON, an object with the requested fields, as
+// its batch is read. limit sets how many keys each SCAN step asks for.
+func (h *Handlers) streamKeys(w http.ResponseWriter, r *http.Request, pattern, cursor string, limit int, fields responseFields) {
+	// The first step runs before the status is sent, so a bad cursor or an
+	// unreachable Valkey still gets a proper error response
+	items, next, err := h.scanItems(r, pattern, cursor, limit, fields)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+	stream, ok := startNDJSON(w)
+	if !ok {
+		return
+	}
+	for {
+		for _, item := range items {
+			stream.write(item)
+		}
+		if !stream.flush() || next == "0" {
+			return
+		}
+		if items, next, err = h.scanItems(r, pattern, next, limit, fields); err != nil {
+			stream.fail("listing failed: ", err)
+			return
+		}
+	}
+}
+
+// scanItems runs one SCAN step for streamKeys, with its own command timeout
+// so a long listing isn't bounded by a single one.
+func (h *Handlers) scanItems(r *http.Request, pattern, cursor string, limit int, fields responseFields) ([]interface{}, string, error) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	keys, next, err := h.store.Scan(ctx, scanPattern(r, pattern), cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	items, err := h.listItems(ctx, r, visibleKeys(r, keys), fields)
+	return items, next, err
+}
+
+// visibleKeys drops the server's own keys and the stored keys that a token
+// restricted to key patterns may not access.
+func visibleKeys(r *http.Request, keys []string) []string {
+	principal := auth.FromContext(r.Context())
+	visible := make([]string, 0, len(keys))
+	for _, storedKey := range keys {
+		if !ReservedKey(stripNamespace(r, storedKey)) && (principal == nil || principal.CanAccessKey(storedKey)) {
+			visible = append(visible, storedKey)
+		}
+	}
+	return visible
+}
+
+// listItems describes stored keys as KeyInfo objects with the requested
+// fields, leaving out keys that expired or were deleted since the scan.
+func (h *Handlers) listItems(ctx context.Context, r *http.Request, storedKeys []string, fields responseFields) ([]interface{}, error) {
+	infos := make([]*KeyInfo, len(storedKeys))
+	for i, key := range storedKeys {
+		infos[i] = &KeyInfo{Key: key}
+	}
+	if fields.value || fields.meta() {
+		var err error
+		if infos, err = h.keyInfo(ctx, storedKeys, fields.value); err != nil {
+			return nil, err
+		}
+	}
+	items := make([]interface{}, 0, len(infos))
+	for _, info := range infos {
+		if info == nil {
+			continue // Expired or deleted since the scan
+		}
+		info.Key = stripNamespace(r, info.Key)
+		if !fields.keyType {
+			info.Type = ""
+		}
+		if !fields.ttl {
+			info.TTL = nil
+		}
+		if !fields.size {
+			info.Size = nil
+		}
+		items = append(items, fields.filter(info))
+	}
+	return items, nil
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"valkey-rest/store"
+)
+
+// newTestHandlers returns Handlers on st without a Valkey client, so only
+// the endpoints that go through the store can be exercised. Values of 64
+// bytes or more are compressed.
+func newTestHandlers(t *testing.T, st store.Store) *Handlers {
+	t.Helper()
+	compressor, err := NewValueCompressor("gzip", 64, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(nil, st, nil, compressor, nil, NewCommandPolicy("", ""), 0, 10, time.Hour, "valkey-rest:scheduled")
+}
+
+// routes mounts the key endpoints as the server does, so path values are
+// set.
+func routes(h *Handlers) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys", h.HandleList)
+	mux.HandleFunc("DELETE /keys", h.HandleBulkDelete)
+	mux.HandleFunc("GET /keys/{key}", h.HandleGet)
+	mux.HandleFunc("HEAD /keys/{key}", h.HandleHead)
+	mux.HandleFunc("GET /keys/{key}/exists", h.HandleExists)
+	mux.HandleFunc("POST /keys/{key}", h.HandleSet)
+	mux.HandleFunc("DELETE /keys/{key}", h.HandleDelete)
+	mux.HandleFunc("POST /keys/{key}/rename", h.HandleRename)
+	mux.HandleFunc("POST /keys/{key}/copy", h.HandleCopy)
+	mux.HandleFunc("POST /keys/{key}/append", h.HandleAppend)
+	mux.HandleFunc("POST /keys/{key}/setrange", h.HandleSetRange)
+	return mux
+}
+
+// request sends a request with optional header pairs and returns the
+// recorded response.
+func request(h http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body, err)
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"valkey-rest/config"
+)
+
+type HLLAddRequest struct {
+	Elements []string `json:"elements"`
+}
+
+type HLLMergeRequest struct {
+	Sources []string `json:"sources"` // Keys merged into {key} along with its current contents
+}
+
+func (h *Handlers) HandleHLLAdd(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	var req HLLAddRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.Elements) == 0 {
+		writeError(w, http.StatusBadRequest, "elements are required")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	changed, err := h.client.Do(ctx, h.client.B().Pfadd().Key(namespacedKey(r, key)).Element(req.Elements...).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "added",
+		"key":     key,
+		"changed": changed == 1, // Whether the estimated cardinality changed
+	})
+}
+
+// HandleHLLCount returns the estimated cardinality of {key}, or of the union
+// of {key} and the comma-separated keys in ?union=.
+func (h *Handlers) HandleHLLCount(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	keys := append([]string{key}, config.SplitList(r.URL.Query().Get("union"))...)
+	if !checkKeys(w, r, keys...) {
+		return
+	}
+	stored := make([]string, len(keys))
+	for i, k := range keys {
+		stored[i] = namespacedKey(r, k)
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	count, err := h.client.Do(ctx, h.client.B().Pfcount().Key(stored...).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":   key,
+		"keys":  keys,
+		"count": count,
+	})
+}
+
+func (h *Handlers) HandleHLLMerge(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	var req HLLMergeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.Sources) == 0 {
+		writeError(w, http.StatusBadRequest, "sources are required")
+		return
+	}
+	if !checkKeys(w, r, req.Sources...) {
+		return
+	}
+	sources := make([]string, len(req.Sources))
+	for i, k := range req.Sources {
+		sources[i] = namespacedKey(r, k)
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	if err := h.client.Do(ctx, h.client.B().Pfmerge().Destkey(namespacedKey(r, key)).Sourcekey(sources...).Build()).Error(); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "merged",
+		"key":     key,
+		"sources": req.Sources,
+	})
+}
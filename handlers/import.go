@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+)
+
+const (
+	importBatch     = 500
+	importTimeout   = 5 * time.Minute
+	maxImportErrors = 100
+)
+
+// ImportError reports a record that couldn't be restored. Line is the
+// 1-based line of the record in the request body.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+// importRecord is a parsed record waiting to be sent in the next batch.
+type importRecord struct {
+	line int
+	key  string
+	cmd  valkey.Completed
+}
+
+// HandleImport restores keys from the export format, or from CSV rows of
+// key,value[,ttl_ms] when the body is text/csv. Records are written in
+// pipelined batches and failures are reported per record without stopping
+// the import.
+func (h *Handlers) HandleImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	replace := false
+	if v := query.Get("replace"); v != "" {
+		var err error
+		if replace, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "replace must be true or false")
+			return
+		}
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "ndjson"
+		if hasContentType(r, "text/csv") {
+			format = "csv"
+		}
+	}
+	if format != "ndjson" && format != "csv" {
+		writeError(w, http.StatusBadRequest, "format must be ndjson or csv")
+		return
+	}
+
+	// Large imports outlast the server's read and write timeouts
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(importTimeout))
+	ctx, cancel := extendForBlock(w, r, importTimeout)
+	defer cancel()
+
+	principal := auth.FromContext(r.Context())
+	var imported int64
+	failures := []ImportError{}
+	failed := 0
+	fail := func(line int, key string, msg string) {
+		failed++
+		if len(failures) < maxImportErrors {
+			failures = append(failures, ImportError{Line: line, Key: key, Error: msg})
+		}
+	}
+
+	batch := make([]importRecord, 0, importBatch)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		cmds := make(valkey.Commands, len(batch))
+		for i, rec := range batch {
+			cmds[i] = rec.cmd
+		}
+		for i, resp := range h.client.DoMulti(ctx, cmds...) {
+			err := resp.Error()
+			switch {
+			case valkey.IsValkeyNil(err):
+				// SET NX skipped an existing key
+				fail(batch[i].line, batch[i].key, "key already exists")
+			case err != nil:
+				fail(batch[i].line, batch[i].key, err.Error())
+			default:
+				imported++
+			}
+		}
+		batch = batch[:0]
+	}
+
+	add := func(line int, key string, build func(storedKey string) valkey.Completed) {
+		if key == "" {
+			fail(line, key, "key is required")
+			return
+		}
+		if ReservedKey(key) {
+			fail(line, key, errReservedKey)
+			return
+		}
+		storedKey := namespacedKey(r, key)
+		if principal != nil && !principal.CanAccessKey(storedKey) {
+			fail(line, key, "access denied for key")
+			return
+		}
+		h.invalidate(storedKey)
+		batch = append(batch, importRecord{line: line, key: key, cmd: build(storedKey)})
+		if len(batch) == importBatch {
+			flush()
+		}
+	}
+
+	var readErr error
+	if format == "ndjson" {
+		readErr = h.readNDJSONImport(r.Body, replace, add, fail)
+	} else {
+		readErr = h.readCSVImport(r.Body, replace, add, fail)
+	}
+	flush()
+
+	if readErr != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(readErr, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes; %d records were imported before the limit", maxBytesErr.Limit, imported))
+			return
+		}
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v; %d records were imported", readErr, imported))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "imported",
+		"imported": imported,
+		"failed":   failed,
+		"errors":   failures,
+	})
+}
+
+// readNDJSONImport parses ExportRecord lines into RESTORE commands.
+func (h *Handlers) readNDJSONImport(body io.Reader, replace bool, add func(int, string, func(string) valkey.Completed), fail func(int, string, string)) error {
+	reader := bufio.NewReader(body)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			h.addExportRecord(line, data, replace, add, fail)
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (h *Handlers) addExportRecord(line int, data []byte, replace bool, add func(int, string, func(string) valkey.Completed), fail func(int, string, string)) {
+	var rec ExportRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		fail(line, "", "invalid record: "+err.Error())
+		return
+	}
+	payload, err := base64.StdEncoding.DecodeString(rec.Value)
+	if err != nil {
+		fail(line, rec.Key, "invalid record: value must be base64")
+		return
+	}
+
+	// RESTORE takes 0 for keys without an expiry
+	ttl := rec.TTL
+	if ttl < 0 {
+		ttl = 0
+	}
+	add(line, rec.Key, func(storedKey string) valkey.Completed {
+		cmd := h.client.B().Restore().Key(storedKey).Ttl(ttl).SerializedValue(string(payload))
+		if replace {
+			return cmd.Replace().Build()
+		}
+		return cmd.Build()
+	})
+}
+
+// readCSVImport parses key,value[,ttl_ms] rows into SET commands. A leading
+// header row starting with "key,value" is skipped.
+func (h *Handlers) readCSVImport(body io.Reader, replace bool, add func(int, string, func(string) valkey.Completed), fail func(int, string, string)) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	for first := true; ; first = false {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first && len(row) >= 2 && row[0] == "key" && row[1] == "value" {
+			continue
+		}
+		if len(row) < 2 || len(row) > 3 {
+			fail(line, row[0], "expected key,value[,ttl_ms]")
+			continue
+		}
+
+		var ttl int64
+		if len(row) == 3 && row[2] != "" {
+			if ttl, err = strconv.ParseInt(row[2], 10, 64); err != nil || ttl < 0 {
+				fail(line, row[0], "ttl_ms must be a non-negative integer")
+				continue
+			}
+		}
+
+		value := h.compressor.Encode(row[1])
+		add(line, row[0], func(storedKey string) valkey.Completed {
+			switch {
+			case ttl > 0 && replace:
+				return h.client.B().Set().Key(storedKey).Value(value).PxMilliseconds(ttl).Build()
+			case ttl > 0:
+				return h.client.B().Set().Key(storedKey).Value(value).Nx().PxMilliseconds(ttl).Build()
+			case replace:
+				return h.client.B().Set().Key(storedKey).Value(value).Build()
+			default:
+				return h.client.B().Set().Key(storedKey).Value(value).Nx().Build()
+			}
+		})
+	}
+}
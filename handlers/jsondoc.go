@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// writeJSONCommandError reports a failed JSON.* command, distinguishing a
+// server without the JSON module from errors caused by the request.
+func writeJSONCommandError(w http.ResponseWriter, err error) {
+	if verr, ok := valkey.IsValkeyErr(err); ok && strings.Contains(strings.ToLower(verr.Error()), "unknown command") {
+		writeError(w, http.StatusNotImplemented, "JSON documents require the valkey-json (or RedisJSON) module, which is not loaded")
+		return
+	}
+	writeCommandError(w, err)
+}
+
+// HandleJSONGet returns the whole document, or with ?path= (repeatable) the
+// values matching each JSONPath.
+func (h *Handlers) HandleJSONGet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	cmd := h.client.B().JsonGet().Key(namespacedKey(r, key))
+	var built valkey.Completed
+	if paths := r.URL.Query()["path"]; len(paths) > 0 {
+		built = cmd.Path(paths...).Build()
+	} else {
+		built = cmd.Build()
+	}
+
+	doc, err := h.client.Do(ctx, built).ToString()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			writeKeyNotFound(w)
+			return
+		}
+		writeJSONCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":   key,
+		"value": json.RawMessage(doc),
+	})
+}
+
+// HandleJSONSet stores the request body, which must be valid JSON, at ?path=
+// (default the root). ?condition=nx only creates and xx only updates.
+func (h *Handlers) HandleJSONSet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	body, ok := readRawBody(w, r)
+	if !ok {
+		return
+	}
+	if !json.Valid(body) {
+		writeError(w, http.StatusBadRequest, "request body must be a valid JSON value")
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		path = "$"
+	}
+
+	set := h.client.B().JsonSet().Key(namespacedKey(r, key)).Path(path).Value(string(body))
+	var cmd valkey.Completed
+	switch strings.ToLower(r.URL.Query().Get("condition")) {
+	case "":
+		cmd = set.Build()
+	case "nx":
+		cmd = set.Nx().Build()
+	case "xx":
+		cmd = set.Xx().Build()
+	default:
+		writeError(w, http.StatusBadRequest, "condition must be nx or xx")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	if err := h.client.Do(ctx, cmd).Error(); err != nil {
+		if valkey.IsValkeyNil(err) {
+			// The NX/XX condition wasn't met, or the path's parent doesn't exist
+			writeError(w, http.StatusConflict, "condition not met or path not found")
+			return
+		}
+		writeJSONCommandError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "created", "key": key, "path": path})
+}
+
+// HandleJSONDelete deletes the values at ?path=, or the whole document.
+func (h *Handlers) HandleJSONDelete(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	path := r.URL.Query().Get("path")
+	cmd := h.client.B().JsonDel().Key(namespacedKey(r, key))
+	var built valkey.Completed
+	if path != "" {
+		built = cmd.Path(path).Build()
+	} else {
+		built = cmd.Build()
+	}
+
+	deleted, err := h.client.Do(ctx, built).AsInt64()
+	if err != nil {
+		writeJSONCommandError(w, err)
+		return
+	}
+
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, "key or path not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "deleted", "key": key, "deleted": deleted})
+}
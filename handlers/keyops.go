@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+	"valkey-rest/store"
+)
+
+// KeyMeta describes a key without its value.
+type KeyMeta struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	TTL         int64  `json:"ttl_ms"`                 // Remaining time to live in ms, -1 if the key doesn't expire
+	Encoding    string `json:"encoding,omitempty"`     // Internal encoding reported by OBJECT ENCODING
+	MemoryUsage *int64 `json:"memory_usage,omitempty"` // Bytes, when MEMORY USAGE is available
+}
+
+func (h *Handlers) HandleKeyMeta(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	storedKey := namespacedKey(r, key)
+	resps := h.client.DoMulti(ctx,
+		h.client.B().Type().Key(storedKey).Build(),
+		h.client.B().Pttl().Key(storedKey).Build(),
+		h.client.B().ObjectEncoding().Key(storedKey).Build(),
+		h.client.B().MemoryUsage().Key(storedKey).Build(),
+	)
+
+	keyType, err := resps[0].ToString()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if keyType == "none" {
+		writeKeyNotFound(w)
+		return
+	}
+
+	ttl, err := resps[1].AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	meta := KeyMeta{Key: key, Type: keyType, TTL: ttl}
+
+	// OBJECT and MEMORY are often disabled on managed services, so their
+	// fields are left out rather than failing the request. The key may also
+	// have expired between commands.
+	if encoding, err := resps[2].ToString(); err == nil {
+		meta.Encoding = encoding
+	}
+	if usage, err := resps[3].AsInt64(); err == nil {
+		meta.MemoryUsage = &usage
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meta)
+}
+
+type MoveKeyRequest struct {
+	Destination string `json:"destination"`
+	Replace     bool   `json:"replace,omitempty"` // Overwrite an existing destination key
+	DB          *int64 `json:"db,omitempty"`      // Target database for copies; standalone servers only
+}
+
+// decodeMoveRequest parses and checks a rename or copy request, including
+// that the principal may access the destination key.
+func decodeMoveRequest(w http.ResponseWriter, r *http.Request) (MoveKeyRequest, bool) {
+	var req MoveKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return req, false
+	}
+
+	if req.Destination == "" {
+		writeError(w, http.StatusBadRequest, "destination is required")
+		return req, false
+	}
+
+	if !checkKeys(w, r, req.Destination) {
+		return req, false
+	}
+	return req, true
+}
+
+// writeMoveError maps errors from RENAME and COPY to responses. In cluster
+// mode both keys must hash to the same slot, which surfaces as CROSSSLOT.
+func writeMoveError(w http.ResponseWriter, err error) {
+	if verr, ok := valkey.IsValkeyErr(err); ok && strings.Contains(verr.Error(), "no such key") {
+		writeKeyNotFound(w)
+		return
+	}
+	writeCommandError(w, err)
+}
+
+func (h *Handlers) HandleRename(w http.ResponseWriter, r *http.Request) {
+	if h.refuseMirrored(w) {
+		return
+	}
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	req, ok := decodeMoveRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.DB != nil {
+		writeError(w, http.StatusBadRequest, "db is only supported when copying")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	source, destination := namespacedKey(r, key), namespacedKey(r, req.Destination)
+	defer h.invalidate(source, destination)
+	if req.Replace {
+		if err := h.client.Do(ctx, h.client.B().Rename().Key(source).Newkey(destination).Build()).Error(); err != nil {
+			writeMoveError(w, err)
+			return
+		}
+	} else {
+		renamed, err := h.client.Do(ctx, h.client.B().Renamenx().Key(source).Newkey(destination).Build()).AsInt64()
+		if err != nil {
+			writeMoveError(w, err)
+			return
+		}
+		if renamed == 0 {
+			writeError(w, http.StatusConflict, "destination key already exists")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "renamed", "key": key, "destination": req.Destination})
+}
+
+func (h *Handlers) HandleCopy(w http.ResponseWriter, r *http.Request) {
+	if h.refuseMirrored(w) {
+		return
+	}
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	req, ok := decodeMoveRequest(w, r)
+	if !ok {
+		return
+	}
+	// The target database is held to the same allow-list as ?db=
+	if req.DB != nil {
+		if *req.DB < 0 {
+			writeError(w, http.StatusBadRequest, "db must be a non-negative integer")
+			return
+		}
+		if !h.allowedDB(*req.DB) {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("database %d is not allowed", *req.DB))
+			return
+		}
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	source, destination := namespacedKey(r, key), namespacedKey(r, req.Destination)
+	defer h.invalidate(destination)
+	cmd := h.client.B().Copy().Source(source).Destination(destination)
+	var copied int64
+	var err error
+	switch {
+	case req.DB != nil && req.Replace:
+		copied, err = h.client.Do(ctx, cmd.Db(*req.DB).Replace().Build()).AsInt64()
+	case req.DB != nil:
+		copied, err = h.client.Do(ctx, cmd.Db(*req.DB).Build()).AsInt64()
+	case req.Replace:
+		copied, err = h.client.Do(ctx, cmd.Replace().Build()).AsInt64()
+	default:
+		copied, err = h.client.Do(ctx, cmd.Build()).AsInt64()
+	}
+	if err != nil {
+		writeMoveError(w, err)
+		return
+	}
+
+	if copied == 0 {
+		// COPY returns 0 both for a missing source and an existing destination
+		exists, err := h.client.Do(ctx, h.client.B().Exists().Key(source).Build()).AsInt64()
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+		if exists == 0 {
+			writeKeyNotFound(w)
+			return
+		}
+		writeError(w, http.StatusConflict, "destination key already exists")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "copied", "key": key, "destination": req.Destination})
+}
+
+const (
+	bulkDeleteBatch   = 500
+	bulkDeleteSample  = 100
+	bulkDeleteTimeout = 60 * time.Second
+)
+
+// HandleBulkDelete removes every key matching a pattern. Keys are found with
+// SCAN and removed with UNLINK in pipelined batches, so the server frees
+// memory in the background and other clients aren't blocked.
+func (h *Handlers) HandleBulkDelete(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		writeError(w, http.StatusBadRequest, "pattern is required")
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "dry_run must be true or false")
+			return
+		}
+	}
+
+	// The scan can outlast the server's write timeout on large keyspaces
+	ctx, cancel := extendForBlock(w, r, bulkDeleteTimeout)
+	defer cancel()
+
+	count, sample, err := h.deleteMatching(ctx, r, pattern, dryRun, false)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	status := "deleted"
+	if dryRun {
+		status = "dry_run"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  status,
+		"pattern": pattern,
+		"count":   count,
+		"keys":    sample,
+	})
+}
+
+// deleteMatching removes every key matching pattern in the request's
+// namespace that the caller may access, returning how many keys were removed
+// (or would be, for a dry run) and a sample of them. Keys are removed with
+// UNLINK, or DEL when sync is set; a mirrored store deletes them one at a
+// time instead.
+func (h *Handlers) deleteMatching(ctx context.Context, r *http.Request, pattern string, dryRun, sync bool) (int64, []string, error) {
+	principal := auth.FromContext(r.Context())
+	mirrored := store.IsMirrored(h.store)
+	sample := []string{}
+	var count int64
+	batch := make(valkey.Commands, 0, bulkDeleteBatch)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, resp := range h.client.DoMulti(ctx, batch...) {
+			n, err := resp.AsInt64()
+			if err != nil {
+				return err
+			}
+			count += n
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	cursor := "0"
+	for {
+		keys, next, err := h.store.Scan(ctx, scanPattern(r, pattern), cursor, bulkDeleteBatch)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		for _, storedKey := range keys {
+			key := stripNamespace(r, storedKey)
+			if ReservedKey(key) || (principal != nil && !principal.CanAccessKey(storedKey)) {
+				continue
+			}
+			if len(sample) < bulkDeleteSample {
+				sample = append(sample, key)
+			}
+			if dryRun {
+				count++
+				continue
+			}
+			if mirrored {
+				// Through the store, so the key goes from both servers
+				deleted, err := h.store.Del(ctx, storedKey)
+				if err != nil {
+					return 0, nil, err
+				}
+				if deleted {
+					count++
+				}
+				continue
+			}
+			h.invalidate(storedKey)
+			// One command per key, since keys in a batch may live in different cluster slots
+			if sync {
+				batch = append(batch, h.client.B().Del().Key(storedKey).Build())
+			} else {
+				batch = append(batch, h.client.B().Unlink().Key(storedKey).Build())
+			}
+			if len(batch) == bulkDeleteBatch {
+				if err := flush(); err != nil {
+					return 0, nil, err
+				}
+			}
+		}
+
+		if cursor = next; cursor == "0" {
+			break
+		}
+	}
+
+	if err := flush(); err != nil {
+		return 0, nil, err
+	}
+	return count, sample, nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/store"
+)
+
+func TestBulkDeleteReadFallback(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := store.NewMemory(), store.NewMemory()
+	for _, key := range []string{"user:1", "user:2"} {
+		if err := secondary.Set(ctx, key, "old", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := primary.Set(ctx, "user:3", "new", 0); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandlers(t, store.NewMirrored(primary, secondary, store.MirrorOptions{Mode: store.MirrorReadFallback}))
+
+	if rec := request(routes(h), http.MethodGet, "/keys/user:1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("GET before delete = %d, want 200 from the secondary: %s", rec.Code, rec.Body)
+	}
+	rec := request(routes(h), http.MethodDelete, "/keys?pattern=user:*", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("bulk delete = %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Count int64 `json:"count"`
+	}
+	if decode(t, rec, &resp); resp.Count != 3 {
+		t.Errorf("bulk delete count = %d, want 3", resp.Count)
+	}
+	for _, key := range []string{"user:1", "user:2", "user:3"} {
+		if rec := request(routes(h), http.MethodGet, "/keys/"+key, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s after delete = %d, want 404: %s", key, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestDirectWritesRefusedWhileMirrored(t *testing.T) {
+	h := newTestHandlers(t, store.NewMirrored(store.NewMemory(), store.NewMemory(), store.MirrorOptions{Mode: store.MirrorDualWrite}))
+
+	for _, path := range []string{"/keys/k/rename", "/keys/k/copy", "/keys/k/append", "/keys/k/setrange"} {
+		if rec := request(routes(h), http.MethodPost, path, `{"destination":"d","value":"v"}`); rec.Code != http.StatusConflict {
+			t.Errorf("POST %s = %d, want 409: %s", path, rec.Code, rec.Body)
+		}
+	}
+}
+
+// allowListClient stands in for the server's client routing between the
+// databases in VALKEY_DB_ALLOW.
+type allowListClient struct {
+	valkey.Client
+	dbs map[int64]bool
+}
+
+func (c allowListClient) HasDB(db int64) bool { return c.dbs[db] }
+
+func TestCopyTargetDB(t *testing.T) {
+	h := newTestHandlers(t, store.NewMemory())
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"destination":"d","db":5}`, http.StatusForbidden},
+		{`{"destination":"d","db":-1}`, http.StatusBadRequest},
+	} {
+		if rec := request(routes(h), http.MethodPost, "/keys/k/copy", tc.body); rec.Code != tc.want {
+			t.Errorf("copy %s = %d, want %d: %s", tc.body, rec.Code, tc.want, rec.Body)
+		}
+	}
+
+	h.client = allowListClient{dbs: map[int64]bool{2: true}}
+	for db, want := range map[int64]bool{0: true, 2: true, 5: false} {
+		if got := h.allowedDB(db); got != want {
+			t.Errorf("allowedDB(%d) = %v, want %v", db, got, want)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	leaderboardKeyPrefix  = "valkey-rest:leaderboard:"
+	defaultLeaderboardTop = 10
+	maxLeaderboardTop     = 1000
+	defaultAroundRadius   = 5
+	maxAroundRadius       = 100
+	maxMemberLength       = 512
+)
+
+// validLeaderboardName keeps leaderboard names to the characters lock names
+// allow.
+var validLeaderboardName = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,200}$`)
+
+// submitScoreScript records a score and returns the member's resulting
+// score and 0-based rank, so the response reflects the write even when
+// another client submits at the same time.
+//
+// KEYS[1] leaderboard; ARGV: member, score, mode, "asc" or "desc".
+// Returns {score, rank}, with the score as a string to keep its precision.
+var submitScoreScript = valkey.NewLuaScript(`
+local score
+if ARGV[3] == 'increment' then
+  score = redis.call('ZINCRBY', KEYS[1], ARGV[2], ARGV[1])
+else
+  if ARGV[3] == 'max' then
+    redis.call('ZADD', KEYS[1], 'GT', ARGV[2], ARGV[1])
+  elseif ARGV[3] == 'min' then
+    redis.call('ZADD', KEYS[1], 'LT', ARGV[2], ARGV[1])
+  else
+    redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+  end
+  score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+end
+local rank
+if ARGV[4] == 'asc' then
+  rank = redis.call('ZRANK', KEYS[1], ARGV[1])
+else
+  rank = redis.call('ZREVRANK', KEYS[1], ARGV[1])
+end
+return {score, rank}
+`)
+
+type ScoreRequest struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+	// "set" (default) replaces the score, "increment" adds to it, and "max"
+	// and "min" keep the better of the old and new scores
+	Mode string `json:"mode,omitempty"`
+}
+
+// LeaderboardEntry is a member's place on a leaderboard. Rank starts at 1;
+// members with equal scores are ordered by member name.
+type LeaderboardEntry struct {
+	Rank   int64   `json:"rank"`
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+type LeaderboardPage struct {
+	Leaderboard string             `json:"leaderboard"`
+	Order       string             `json:"order"`
+	Total       int64              `json:"total"` // Members on the leaderboard
+	Entries     []LeaderboardEntry `json:"entries"`
+	NextOffset  *int64             `json:"next_offset,omitempty"` // Set when more entries follow
+}
+
+// leaderboardKey validates the {name} path value and returns the
+// leaderboard's key, writing a 400 response if the name is invalid.
+func leaderboardKey(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	name := r.PathValue("name")
+	if !validLeaderboardName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "leaderboard name must be 1-200 letters, digits or _.:-")
+		return "", "", false
+	}
+	return name, namespacedKey(r, leaderboardKeyPrefix+name), true
+}
+
+// leaderboardOrder reads ?order=, which is desc (highest score first) unless
+// asc is asked for, as for race times.
+func leaderboardOrder(w http.ResponseWriter, r *http.Request) (string, bool) {
+	switch order := r.URL.Query().Get("order"); order {
+	case "", "desc":
+		return "desc", true
+	case "asc":
+		return order, true
+	}
+	writeError(w, http.StatusBadRequest, "order must be asc or desc")
+	return "", false
+}
+
+// boundedParam parses an optional integer query parameter between lo and
+// hi, writing a 400 response if it is out of range.
+func boundedParam(w http.ResponseWriter, r *http.Request, name string, fallback, lo, hi int64) (int64, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, true
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < lo || n > hi {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be between %d and %d", name, lo, hi))
+		return 0, false
+	}
+	return n, true
+}
+
+// rankRange reads entries from 0-based rank start to stop inclusive.
+func (h *Handlers) rankRange(r *http.Request, key, order string, start, stop int64) ([]LeaderboardEntry, error) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	cmd := h.client.B().Zrange().Key(key).Min(strconv.FormatInt(start, 10)).Max(strconv.FormatInt(stop, 10))
+	var resp valkey.ValkeyResult
+	if order == "asc" {
+		resp = h.client.Do(ctx, cmd.Withscores().Build())
+	} else {
+		resp = h.client.Do(ctx, cmd.Rev().Withscores().Build())
+	}
+	scores, err := resp.AsZScores()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]LeaderboardEntry, len(scores))
+	for i, s := range scores {
+		entries[i] = LeaderboardEntry{Rank: start + int64(i) + 1, Member: s.Member, Score: s.Score}
+	}
+	return entries, nil
+}
+
+// memberRank returns a member's 0-based rank and score, with found false if
+// it isn't on the leaderboard.
+func (h *Handlers) memberRank(r *http.Request, key, member, order string) (int64, float64, bool, error) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	rank := h.client.B().Zrevrank().Key(key).Member(member).Build()
+	if order == "asc" {
+		rank = h.client.B().Zrank().Key(key).Member(member).Build()
+	}
+	resps := h.client.DoMulti(ctx, rank, h.client.B().Zscore().Key(key).Member(member).Build())
+	n, err := resps[0].AsInt64()
+	if valkey.IsValkeyNil(err) {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, err
+	}
+	score, err := resps[1].AsFloat64()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return n, score, true, nil
+}
+
+// HandleSubmitScore records a member's score and returns its place.
+func (h *Handlers) HandleSubmitScore(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := leaderboardKey(w, r)
+	if !ok {
+		return
+	}
+	order, ok := leaderboardOrder(w, r)
+	if !ok {
+		return
+	}
+
+	var req ScoreRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Member == "" || len(req.Member) > maxMemberLength {
+		writeError(w, http.StatusBadRequest, "member must be 1-512 bytes")
+		return
+	}
+	if math.IsNaN(req.Score) || math.IsInf(req.Score, 0) {
+		writeError(w, http.StatusBadRequest, "score must be a finite number")
+		return
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = "set"
+	case "set", "increment", "max", "min":
+	default:
+		writeError(w, http.StatusBadRequest, "mode must be set, increment, max or min")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	result, err := submitScoreScript.Exec(ctx, h.client, []string{key}, []string{
+		req.Member,
+		strconv.FormatFloat(req.Score, 'g', -1, 64),
+		req.Mode,
+		order,
+	}).ToArray()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	score, err := result[0].AsFloat64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	rank, err := result[1].AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"leaderboard": name,
+		"order":       order,
+		"entry":       LeaderboardEntry{Rank: rank + 1, Member: req.Member, Score: score},
+	})
+}
+
+// HandleGetLeaderboard returns a page of the leaderboard from the top, with
+// ?offset= and ?limit=. Asked for NDJSON, it streams every entry from
+// ?offset= on.
+func (h *Handlers) HandleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := leaderboardKey(w, r)
+	if !ok {
+		return
+	}
+	order, ok := leaderboardOrder(w, r)
+	if !ok {
+		return
+	}
+	offset, ok := boundedParam(w, r, "offset", 0, 0, math.MaxInt32)
+	if !ok {
+		return
+	}
+	limit, ok := boundedParam(w, r, "limit", defaultLeaderboardTop, 1, maxLeaderboardTop)
+	if !ok {
+		return
+	}
+
+	if acceptsNDJSON(r) {
+		h.streamLeaderboard(w, r, key, order, offset, limit)
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	total, err := h.client.Do(ctx, h.client.B().Zcard().Key(key).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	entries, err := h.rankRange(r, key, order, offset, offset+limit-1)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	page := LeaderboardPage{Leaderboard: name, Order: order, Total: total, Entries: entries}
+	if next := offset + int64(len(entries)); len(entries) > 0 && next < total {
+		page.NextOffset = &next
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+// streamLeaderboard writes every entry from offset down as a line of
+// NDJSON, reading limit entries at a time.
+func (h *Handlers) streamLeaderboard(w http.ResponseWriter, r *http.Request, key, order string, offset, limit int64) {
+	stream, ok := startNDJSON(w)
+	if !ok {
+		return
+	}
+	for {
+		entries, err := h.rankRange(r, key, order, offset, offset+limit-1)
+		if err != nil {
+			stream.fail("leaderboard failed: ", err)
+			return
+		}
+		for _, entry := range entries {
+			stream.write(entry)
+		}
+		if !stream.flush() || int64(len(entries)) < limit {
+			return
+		}
+		offset += limit
+	}
+}
+
+// HandleGetMemberRank returns a member's rank and score.
+func (h *Handlers) HandleGetMemberRank(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := leaderboardKey(w, r)
+	if !ok {
+		return
+	}
+	order, ok := leaderboardOrder(w, r)
+	if !ok {
+		return
+	}
+	member := r.PathValue("member")
+
+	rank, score, found, err := h.memberRank(r, key, member, order)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "member not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"leaderboard": name,
+		"order":       order,
+		"entry":       LeaderboardEntry{Rank: rank + 1, Member: member, Score: score},
+	})
+}
+
+// HandleGetAroundMember returns the entries up to ?radius= places above and
+// below a member, for showing players their neighbours.
+func (h *Handlers) HandleGetAroundMember(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := leaderboardKey(w, r)
+	if !ok {
+		return
+	}
+	order, ok := leaderboardOrder(w, r)
+	if !ok {
+		return
+	}
+	radius, ok := boundedParam(w, r, "radius", defaultAroundRadius, 0, maxAroundRadius)
+	if !ok {
+		return
+	}
+	member := r.PathValue("member")
+
+	rank, _, found, err := h.memberRank(r, key, member, order)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "member not found")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+	total, err := h.client.Do(ctx, h.client.B().Zcard().Key(key).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	// The member may have moved since its rank was read, so the window is
+	// only approximately centred under concurrent writes
+	entries, err := h.rankRange(r, key, order, max(rank-radius, 0), rank+radius)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(LeaderboardPage{Leaderboard: name, Order: order, Total: total, Entries: entries})
+}
+
+// HandleRemoveMember takes a member off a leaderboard.
+func (h *Handlers) HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := leaderboardKey(w, r)
+	if !ok {
+		return
+	}
+	member := r.PathValue("member")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	removed, err := h.client.Do(ctx, h.client.B().Zrem().Key(key).Member(member).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if removed == 0 {
+		writeError(w, http.StatusNotFound, "member not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "removed", "leaderboard": name, "member": member})
+}
+
+// HandleDeleteLeaderboard deletes a leaderboard with all its members.
+func (h *Handlers) HandleDeleteLeaderboard(w http.ResponseWriter, r *http.Request) {
+	name, key, ok := leaderboardKey(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	deleted, err := h.client.Do(ctx, h.client.B().Del().Key(key).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, "leaderboard not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "leaderboard": name})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// defaultPopTimeout is how long a blocking pop waits without ?timeout=.
+const defaultPopTimeout = 30 * time.Second
+
+type PopResponse struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"` // "base64" when the value isn't valid UTF-8
+}
+
+// HandleBlockingPop pops an element from the head of a list, or the tail with
+// ?side=right, waiting up to ?timeout= seconds for one to be pushed. It
+// answers 204 No Content if the list stays empty, so workers can simply
+// call it again.
+func (h *Handlers) HandleBlockingPop(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	right := false
+	switch r.URL.Query().Get("side") {
+	case "", "left":
+	case "right":
+		right = true
+	default:
+		writeError(w, http.StatusBadRequest, "side must be left or right")
+		return
+	}
+
+	timeout := defaultPopTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil || seconds < 0 {
+			writeError(w, http.StatusBadRequest, "timeout must be a non-negative number of seconds")
+			return
+		}
+		timeout = min(time.Duration(seconds*float64(time.Second)), maxStreamBlock)
+	}
+
+	ctx, cancel := extendForBlock(w, r, timeout)
+	defer cancel()
+
+	// valkey-go sends blocking commands on a dedicated connection from its
+	// pool, so a waiting pop doesn't hold up other requests
+	storedKey := namespacedKey(r, key)
+	var value string
+	var err error
+	switch {
+	case timeout == 0 && right:
+		value, err = h.client.Do(ctx, h.client.B().Rpop().Key(storedKey).Build()).ToString()
+	case timeout == 0:
+		value, err = h.client.Do(ctx, h.client.B().Lpop().Key(storedKey).Build()).ToString()
+	default:
+		var popped []string
+		if right {
+			popped, err = h.client.Do(ctx, h.client.B().Brpop().Key(storedKey).Timeout(timeout.Seconds()).Build()).AsStrSlice()
+		} else {
+			popped, err = h.client.Do(ctx, h.client.B().Blpop().Key(storedKey).Timeout(timeout.Seconds()).Build()).AsStrSlice()
+		}
+		if err == nil && len(popped) == 2 {
+			value = popped[1]
+		}
+	}
+	if valkey.IsValkeyNil(err) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	resp := PopResponse{Key: key, Value: value}
+	if !utf8.ValidString(value) {
+		resp.Value = base64.StdEncoding.EncodeToString([]byte(value))
+		resp.Encoding = "base64"
+	}
+	json.NewEncoder(w).Encode(resp)
+}
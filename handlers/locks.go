@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	lockKeyPrefix     = "valkey-rest:lock:"
+	defaultLockTTL    = 30 * time.Second
+	maxLockTTL        = 24 * time.Hour
+	maxLockWait       = 30 * time.Second
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// validLockName keeps lock names free of the braces used for hash tags.
+var validLockName = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,200}$`)
+
+// lockKeys returns the lock key and its fencing counter. The hash tag keeps
+// both in the same cluster slot so the scripts can use them together.
+func lockKeys(r *http.Request, name string) (string, string) {
+	base := namespacedKey(r, lockKeyPrefix+"{"+name+"}")
+	return base, base + ":fence"
+}
+
+// acquireLockScript takes the lock if it is free and returns the next
+// fencing token, or 0 if the lock is held.
+//
+// KEYS[1] lock, KEYS[2] fencing counter; ARGV: owner token, ttl in ms.
+var acquireLockScript = valkey.NewLuaScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+  return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// renewLockScript extends the lock's TTL if the caller still owns it.
+//
+// KEYS[1] lock; ARGV: owner token, ttl in ms. Returns 1 if renewed.
+var renewLockScript = valkey.NewLuaScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLockScript deletes the lock if the caller still owns it, so a
+// client whose lock expired can't release someone else's.
+//
+// KEYS[1] lock; ARGV: owner token. Returns 1 if released.
+var releaseLockScript = valkey.NewLuaScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type LockRequest struct {
+	TTL  int64 `json:"ttl_ms,omitempty"`  // Lock lifetime, defaults to 30s
+	Wait int64 `json:"wait_ms,omitempty"` // How long to wait for a held lock, 0 to fail immediately
+}
+
+type LockResponse struct {
+	Name         string `json:"name"`
+	Token        string `json:"token"`
+	FencingToken int64  `json:"fencing_token"` // Increases with every acquisition of this lock
+	TTL          int64  `json:"ttl_ms"`
+}
+
+// lockName validates the {name} path value, writing a 400 response if it is
+// invalid.
+func lockName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.PathValue("name")
+	if !validLockName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "lock name must be 1-200 letters, digits or _.:-")
+		return "", false
+	}
+	return name, true
+}
+
+// lockTTL validates a TTL in milliseconds, applying the default for 0.
+func lockTTL(w http.ResponseWriter, ms int64) (time.Duration, bool) {
+	if ms == 0 {
+		return defaultLockTTL, true
+	}
+	ttl := time.Duration(ms) * time.Millisecond
+	if ms < 0 || ttl > maxLockTTL {
+		writeError(w, http.StatusBadRequest, "ttl_ms must be between 1 and 86400000")
+		return 0, false
+	}
+	return ttl, true
+}
+
+// lockToken returns the owner token from the X-Lock-Token header.
+func lockToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	token := r.Header.Get("X-Lock-Token")
+	if token == "" {
+		writeError(w, http.StatusBadRequest, "X-Lock-Token header is required")
+		return "", false
+	}
+	return token, true
+}
+
+func (h *Handlers) HandleAcquireLock(w http.ResponseWriter, r *http.Request) {
+	name, ok := lockName(w, r)
+	if !ok {
+		return
+	}
+
+	var req LockRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ttl, ok := lockTTL(w, req.TTL)
+	if !ok {
+		return
+	}
+	wait := time.Duration(req.Wait) * time.Millisecond
+	if req.Wait < 0 || wait > maxLockWait {
+		writeError(w, http.StatusBadRequest, "wait_ms must be between 0 and 30000")
+		return
+	}
+
+	token, err := randomHex(16)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	ctx, cancel := extendForBlock(w, r, wait)
+	defer cancel()
+
+	lockKey, fenceKey := lockKeys(r, name)
+	args := []string{token, strconv.FormatInt(ttl.Milliseconds(), 10)}
+	deadline := time.Now().Add(wait)
+	for {
+		fence, err := acquireLockScript.Exec(ctx, h.client, []string{lockKey, fenceKey}, args).AsInt64()
+		if err != nil {
+			writeCommandError(w, err)
+			return
+		}
+
+		if fence > 0 {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(LockResponse{Name: name, Token: token, FencingToken: fence, TTL: ttl.Milliseconds()})
+			return
+		}
+
+		if time.Now().Add(lockRetryInterval).After(deadline) {
+			writeError(w, http.StatusConflict, "lock is held")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func (h *Handlers) HandleRenewLock(w http.ResponseWriter, r *http.Request) {
+	name, ok := lockName(w, r)
+	if !ok {
+		return
+	}
+	token, ok := lockToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req LockRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+	ttl, ok := lockTTL(w, req.TTL)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	lockKey, _ := lockKeys(r, name)
+	renewed, err := renewLockScript.Exec(ctx, h.client, []string{lockKey}, []string{token, strconv.FormatInt(ttl.Milliseconds(), 10)}).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if renewed == 0 {
+		writeError(w, http.StatusConflict, "lock is not held by this token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "renewed", "name": name, "ttl_ms": ttl.Milliseconds()})
+}
+
+func (h *Handlers) HandleReleaseLock(w http.ResponseWriter, r *http.Request) {
+	name, ok := lockName(w, r)
+	if !ok {
+		return
+	}
+	token, ok := lockToken(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	lockKey, _ := lockKeys(r, name)
+	released, err := releaseLockScript.Exec(ctx, h.client, []string{lockKey}, []string{token}).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if released == 0 {
+		writeError(w, http.StatusConflict, "lock is not held by this token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "released", "name": name})
+}
+
+// HandleGetLock reports whether a lock is held and for how long, without
+// revealing the owner's token.
+func (h *Handlers) HandleGetLock(w http.ResponseWriter, r *http.Request) {
+	name, ok := lockName(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	lockKey, _ := lockKeys(r, name)
+	ttl, err := h.client.Do(ctx, h.client.B().Pttl().Key(lockKey).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	resp := map[string]interface{}{"name": name, "held": ttl != -2}
+	if ttl >= 0 {
+		resp["ttl_ms"] = ttl
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// NamespaceHeader selects the tenant namespace of a request.
+const NamespaceHeader = "X-Namespace"
+
+const (
+	namespaceSeparator = ":"
+	maxNamespaceLength = 64
+)
+
+// InternalKeyPrefix starts every key the server keeps its own state in: API
+// keys, webhooks, the audit stream, rate limit buckets and the rest. Clients
+// can't name these keys through the key endpoints or see them in listings,
+// and no namespace maps onto them.
+const InternalKeyPrefix = "valkey-rest:"
+
+// ReservedKey reports whether a client-visible key falls under
+// InternalKeyPrefix.
+func ReservedKey(key string) bool {
+	return strings.HasPrefix(key, InternalKeyPrefix)
+}
+
+// ValidNamespace rejects names that could escape their prefix, either by
+// containing the separator or glob characters that would widen SCAN patterns,
+// and the name that would map onto InternalKeyPrefix.
+func ValidNamespace(ns string) bool {
+	if ns == "" || len(ns) > maxNamespaceLength {
+		return false
+	}
+	// Its keys would be the server's own
+	if ns+namespaceSeparator == InternalKeyPrefix {
+		return false
+	}
+	return !strings.ContainsAny(ns, namespaceSeparator+"*?[]\\/ ")
+}
+
+// RequestNamespace returns the tenant namespace of a request, or "" if none.
+func RequestNamespace(r *http.Request) string {
+	return r.Header.Get(NamespaceHeader)
+}
+
+// namespacedKey maps a client-visible key to the key stored in Valkey.
+func namespacedKey(r *http.Request, key string) string {
+	return PrefixNamespace(RequestNamespace(r), key)
+}
+
+// stripNamespace maps a stored key back to the key the client sees.
+func stripNamespace(r *http.Request, key string) string {
+	return TrimNamespace(RequestNamespace(r), key)
+}
+
+// PrefixNamespace maps a key to the key stored in Valkey for namespace ns.
+func PrefixNamespace(ns, key string) string {
+	if ns != "" {
+		return ns + namespaceSeparator + key
+	}
+	return key
+}
+
+// TrimNamespace reverses PrefixNamespace.
+func TrimNamespace(ns, key string) string {
+	if ns != "" {
+		return strings.TrimPrefix(key, ns+namespaceSeparator)
+	}
+	return key
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// acceptsNDJSON reports whether the client asked for a listing as
+// newline-delimited JSON, which is streamed as it is read instead of being
+// collected into one array first.
+func acceptsNDJSON(r *http.Request) bool {
+	return accepts(r, ndjsonContentType)
+}
+
+// ndjsonStream writes one JSON value per line, flushing after each batch so
+// the client sees results as they arrive and the server holds only one
+// batch at a time.
+type ndjsonStream struct {
+	rc  *http.ResponseController
+	enc *json.Encoder
+}
+
+// startNDJSON lifts the write timeout, which a long listing would outlive,
+// and sends the 200 status. Headers the caller set beforehand are kept. It
+// writes a 500 response and returns false if the connection can't stream.
+func startNDJSON(w http.ResponseWriter) (*ndjsonStream, bool) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return nil, false
+	}
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	return &ndjsonStream{rc: rc, enc: json.NewEncoder(w)}, true
+}
+
+func (s *ndjsonStream) write(v interface{}) {
+	s.enc.Encode(v)
+}
+
+// flush sends the lines written so far, returning false once the client has
+// gone away.
+func (s *ndjsonStream) flush() bool {
+	return s.rc.Flush() == nil
+}
+
+// fail reports an error in-band as a final line, since the status has
+// already been sent.
+func (s *ndjsonStream) fail(prefix string, err error) {
+	status, code := commandErrorCode(err)
+	s.enc.Encode(NewErrorResponse(status, code, prefix+err.Error()))
+}
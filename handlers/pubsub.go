@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+type PublishRequest struct {
+	Message string `json:"message"`
+}
+
+type SubscribeMessage struct {
+	Channel string `json:"channel"`
+	Message string `json:"message"`
+}
+
+// sseKeepAlive is how often a comment line is written to idle SSE streams
+// so intermediaries don't close the connection.
+const sseKeepAlive = 15 * time.Second
+
+func (h *Handlers) HandlePublish(w http.ResponseWriter, r *http.Request) {
+	channel := r.PathValue("channel")
+	if channel == "" {
+		writeError(w, http.StatusBadRequest, "channel is required")
+		return
+	}
+
+	var req PublishRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Message == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	receivers, err := h.client.Do(ctx, h.client.B().Publish().Channel(namespacedKey(r, channel)).Message(req.Message).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "published",
+		"channel":   channel,
+		"receivers": receivers,
+	})
+}
+
+// HandleSubscribe streams messages published to a channel as Server-Sent Events
+// until the client disconnects.
+func (h *Handlers) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
+	channel := r.PathValue("channel")
+	if channel == "" {
+		writeError(w, http.StatusBadRequest, "channel is required")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server-wide write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	// Pub/Sub needs its own connection, so borrow a dedicated one from the pool
+	dedicated, release := h.client.Dedicate()
+	defer release()
+	// A subscribed connection can't go back into the pool, so close it outright
+	defer dedicated.Close()
+
+	messages := make(chan valkey.PubSubMessage, 64)
+	wait := dedicated.SetPubSubHooks(valkey.PubSubHooks{
+		OnMessage: func(m valkey.PubSubMessage) {
+			select {
+			case messages <- m:
+			default:
+				// Drop messages for slow consumers rather than blocking the connection
+			}
+		},
+	})
+
+	ctx := r.Context()
+	if err := dedicated.Do(ctx, dedicated.B().Subscribe().Channel(namespacedKey(r, channel)).Build()).Error(); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, ": subscribed to %s\n\n", channel)
+	rc.Flush()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait:
+			// The subscription connection was closed underneath us
+			return
+		case m := <-messages:
+			data, _ := json.Marshal(SubscribeMessage{Channel: stripNamespace(r, m.Channel), Message: m.Message})
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// maxStringLength is the largest string Valkey stores by default
+// (proto-max-bulk-len).
+const maxStringLength = 512 << 20
+
+// Results of the range scripts that aren't lengths.
+const (
+	rangeKeyMissing = -1
+	rangeCompressed = -2
+	rangeMarker     = -3 // The write would make the value start with the compression marker
+)
+
+// getRangeScript reads part of a string along with its length, refusing
+// values stored compressed, whose bytes don't correspond to the value's.
+//
+// KEYS[1] key; ARGV: compression marker, start, end.
+// Returns {length, bytes}, or {-1} for a missing key and {-2} for a
+// compressed value.
+var getRangeScript = valkey.NewLuaScript(`
+local length = redis.call('STRLEN', KEYS[1])
+if length == 0 and redis.call('EXISTS', KEYS[1]) == 0 then
+  return {-1}
+end
+if redis.call('GETRANGE', KEYS[1], 0, #ARGV[1] - 1) == ARGV[1] then
+  return {-2}
+end
+return {length, redis.call('GETRANGE', KEYS[1], ARGV[2], ARGV[3])}
+`)
+
+// appendScript appends to a string unless it is stored compressed, or the
+// result would look like it was.
+//
+// KEYS[1] key; ARGV: compression marker, value. Returns the new length, -2
+// for a compressed value or -3 if the value would start with the marker.
+var appendScript = valkey.NewLuaScript(`
+local head = redis.call('GETRANGE', KEYS[1], 0, #ARGV[1] - 1)
+if head == ARGV[1] then
+  return -2
+end
+if #head < #ARGV[1] and string.sub(head .. ARGV[2], 1, #ARGV[1]) == ARGV[1] then
+  return -3
+end
+return redis.call('APPEND', KEYS[1], ARGV[2])
+`)
+
+// setRangeScript overwrites part of a string unless it is stored
+// compressed, or the result would look like it was.
+//
+// KEYS[1] key; ARGV: compression marker, offset, value. Returns the new
+// length, -2 for a compressed value or -3 if the value would start with the
+// marker.
+var setRangeScript = valkey.NewLuaScript(`
+local head = redis.call('GETRANGE', KEYS[1], 0, #ARGV[1] - 1)
+if head == ARGV[1] then
+  return -2
+end
+local offset = tonumber(ARGV[2])
+if offset < #ARGV[1] then
+  -- SETRANGE pads with zero bytes up to the offset
+  head = head .. string.rep('\0', #ARGV[1] - #head)
+  local after = string.sub(head, 1, offset) .. ARGV[3] .. string.sub(head, offset + #ARGV[3] + 1)
+  if string.sub(after, 1, #ARGV[1]) == ARGV[1] then
+    return -3
+  end
+end
+return redis.call('SETRANGE', KEYS[1], ARGV[2], ARGV[3])
+`)
+
+type AppendRequest struct {
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"` // "base64" for binary values
+}
+
+type SetRangeRequest struct {
+	Offset   int64  `json:"offset"` // Byte offset to write at; the value is zero-padded up to it
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"` // "base64" for binary values
+}
+
+type RangeResponse struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"` // "base64" when the chunk isn't valid UTF-8
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`    // Inclusive; start - 1 for an empty chunk
+	Length   int64  `json:"length"` // Length of the whole value
+}
+
+// chunkValue decodes a chunk sent as JSON with an optional base64 encoding,
+// writing a 400 response and returning false if it can't be decoded.
+func chunkValue(w http.ResponseWriter, value, encoding string) (string, bool) {
+	switch encoding {
+	case "":
+		return value, true
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "value is not valid base64")
+			return "", false
+		}
+		return string(decoded), true
+	}
+	writeError(w, http.StatusBadRequest, "encoding must be base64 or omitted")
+	return "", false
+}
+
+// writeCompressedConflict reports a range operation on a compressed value.
+func writeCompressedConflict(w http.ResponseWriter) {
+	writeError(w, http.StatusConflict, "value is stored compressed and can only be read or written whole")
+}
+
+// writeMarkerConflict reports a range write that would make a value start
+// with the compression marker, so it would be read back as compressed.
+func writeMarkerConflict(w http.ResponseWriter) {
+	writeError(w, http.StatusBadRequest, "value must not start with the compression marker")
+}
+
+// rangeParam parses an optional byte index, which may be negative to count
+// from the end as in GETRANGE.
+func rangeParam(w http.ResponseWriter, r *http.Request, name string, fallback int64) (int64, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, true
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, name+" must be an integer")
+		return 0, false
+	}
+	return n, true
+}
+
+// resolveRange applies GETRANGE's rules to start and end for a value of the
+// given length, returning the inclusive byte range actually read.
+func resolveRange(start, end, length int64) (int64, int64) {
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if end < 0 {
+		end = length + end
+	}
+	end = min(end, length-1)
+	if start > end {
+		return start, start - 1
+	}
+	return start, end
+}
+
+// HandleGetRange returns bytes start to end (inclusive, default the whole
+// value) of a string, so large values can be read in chunks.
+func (h *Handlers) HandleGetRange(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	start, ok := rangeParam(w, r, "start", 0)
+	if !ok {
+		return
+	}
+	end, ok := rangeParam(w, r, "end", -1)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	result, err := getRangeScript.Exec(ctx, h.client, []string{namespacedKey(r, key)}, []string{
+		compressedMagic,
+		strconv.FormatInt(start, 10),
+		strconv.FormatInt(end, 10),
+	}).ToArray()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	length, err := result[0].AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	switch length {
+	case rangeKeyMissing:
+		writeKeyNotFound(w)
+		return
+	case rangeCompressed:
+		writeCompressedConflict(w)
+		return
+	}
+	chunk, err := result[1].ToString()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	start, end = resolveRange(start, end, length)
+	if acceptsRaw(r) {
+		w.Header().Set("Content-Type", octetStream)
+		w.Header().Set("Content-Length", strconv.Itoa(len(chunk)))
+		if len(chunk) > 0 {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, length))
+		}
+		io.WriteString(w, chunk)
+		return
+	}
+
+	resp := RangeResponse{Key: key, Value: chunk, Start: start, End: end, Length: length}
+	if !utf8.ValidString(chunk) {
+		resp.Value = base64.StdEncoding.EncodeToString([]byte(chunk))
+		resp.Encoding = "base64"
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleAppend appends a chunk to a string, creating it if it doesn't exist.
+func (h *Handlers) HandleAppend(w http.ResponseWriter, r *http.Request) {
+	if h.refuseMirrored(w) {
+		return
+	}
+	key := r.PathValue("key")
+
+	var value string
+	if hasContentType(r, octetStream) {
+		body, ok := readRawBody(w, r)
+		if !ok {
+			return
+		}
+		value = string(body)
+	} else {
+		var req AppendRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var ok bool
+		if value, ok = chunkValue(w, req.Value, req.Encoding); !ok {
+			return
+		}
+	}
+	if value == "" {
+		writeError(w, http.StatusBadRequest, "value is required")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	storedKey := namespacedKey(r, key)
+	length, err := appendScript.Exec(ctx, h.client, []string{storedKey}, []string{compressedMagic, value}).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	switch length {
+	case rangeCompressed:
+		writeCompressedConflict(w)
+		return
+	case rangeMarker:
+		writeMarkerConflict(w)
+		return
+	}
+	h.invalidate(storedKey)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "appended", "key": key, "length": length})
+}
+
+// HandleSetRange overwrites part of a string starting at an offset, padding
+// it with zero bytes if the offset is past its end.
+func (h *Handlers) HandleSetRange(w http.ResponseWriter, r *http.Request) {
+	if h.refuseMirrored(w) {
+		return
+	}
+	key := r.PathValue("key")
+
+	var req SetRangeRequest
+	if hasContentType(r, octetStream) {
+		// Raw bodies take the offset from the query string
+		body, ok := readRawBody(w, r)
+		if !ok {
+			return
+		}
+		req.Value = string(body)
+		offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "offset must be an integer")
+			return
+		}
+		req.Offset = offset
+	} else {
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var ok bool
+		if req.Value, ok = chunkValue(w, req.Value, req.Encoding); !ok {
+			return
+		}
+	}
+	if req.Value == "" {
+		writeError(w, http.StatusBadRequest, "value is required")
+		return
+	}
+	if req.Offset < 0 || req.Offset+int64(len(req.Value)) > maxStringLength {
+		writeError(w, http.StatusBadRequest, "offset must not be negative, and the value must end within 512MB")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	storedKey := namespacedKey(r, key)
+	length, err := setRangeScript.Exec(ctx, h.client, []string{storedKey}, []string{
+		compressedMagic,
+		strconv.FormatInt(req.Offset, 10),
+		req.Value,
+	}).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	switch length {
+	case rangeCompressed:
+		writeCompressedConflict(w)
+		return
+	case rangeMarker:
+		writeMarkerConflict(w)
+		return
+	}
+	h.invalidate(storedKey)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "updated", "key": key, "offset": req.Offset, "length": length})
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	// Kept apart from valkey-rest:ratelimit:, where the proxy's own request
+	// limits live, so clients can't drain those buckets
+	bucketKeyPrefix = "valkey-rest:limit:"
+	maxBucketLimit  = 100000
+	maxBucketPeriod = 24 * time.Hour
+)
+
+// validBucketName keeps bucket names to the same characters as lock names.
+var validBucketName = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,200}$`)
+
+// TokenBucketScript refills a bucket of `limit` tokens evenly over `period`
+// and takes `cost` tokens from it, using Valkey's clock so every caller
+// agrees on the time. It backs both the proxy's own request limits and the
+// client-defined buckets of /ratelimit.
+//
+// KEYS[1] bucket; ARGV: limit, period in ms, cost.
+// Returns {allowed (0/1), remaining, retry after in ms, reset in ms}.
+var TokenBucketScript = valkey.NewLuaScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local rate = limit / period
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+  tokens = tokens - cost
+  allowed = 1
+else
+  retry = math.ceil((cost - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, math.floor(tokens), retry, math.ceil((limit - tokens) / rate)}
+`)
+
+// bucketWindowScript counts requests in a sliding window with a sorted set
+// of timestamps, one member per unit of cost, and admits the request if the
+// window has room for it.
+//
+// KEYS[1] bucket; ARGV: limit, window in ms, cost, unique request ID.
+// Returns {allowed (0/1), remaining, retry after in ms, reset in ms}.
+var bucketWindowScript = valkey.NewLuaScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+local retry = 0
+if count + cost <= limit then
+  for i = 1, cost do
+    redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. i)
+  end
+  count = count + cost
+  allowed = 1
+else
+  -- The request fits once enough of the oldest entries have left the window
+  local i = count + cost - limit - 1
+  local oldest = redis.call('ZRANGE', KEYS[1], i, i, 'WITHSCORES')
+  retry = tonumber(oldest[2]) + window - now
+end
+
+local reset = 0
+if count > 0 then
+  local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+  reset = tonumber(newest[2]) + window - now
+  redis.call('PEXPIRE', KEYS[1], reset)
+end
+return {allowed, limit - count, retry, reset}
+`)
+
+type RateLimitRequest struct {
+	Algorithm string `json:"algorithm,omitempty"` // "token_bucket" (default) or "sliding_window"
+	Limit     int64  `json:"limit"`               // Requests allowed per period
+	Period    int64  `json:"period_ms"`
+	Cost      int64  `json:"cost,omitempty"` // Units this request takes, defaults to 1
+}
+
+type RateLimitResponse struct {
+	Bucket     string `json:"bucket"`
+	Allowed    bool   `json:"allowed"`
+	Limit      int64  `json:"limit"`
+	Remaining  int64  `json:"remaining"`
+	Reset      int64  `json:"reset_ms"`                 // Until the bucket is back to its full limit
+	RetryAfter int64  `json:"retry_after_ms,omitempty"` // Until the request would be allowed, if it wasn't
+}
+
+// HandleRateLimit takes from a client-defined rate limit bucket and reports
+// whether the caller may proceed. A refused check is still a successful
+// request, so it answers 200 either way and leaves 429 to the proxy's own
+// limits.
+func (h *Handlers) HandleRateLimit(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	if !validBucketName.MatchString(bucket) {
+		writeError(w, http.StatusBadRequest, "bucket name must be 1-200 letters, digits or _.:-")
+		return
+	}
+
+	var req RateLimitRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Limit < 1 || req.Limit > maxBucketLimit {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and 100000")
+		return
+	}
+	period := time.Duration(req.Period) * time.Millisecond
+	if req.Period < 1 || period > maxBucketPeriod {
+		writeError(w, http.StatusBadRequest, "period_ms must be between 1 and 86400000")
+		return
+	}
+	if req.Cost == 0 {
+		req.Cost = 1
+	}
+	if req.Cost < 1 || req.Cost > req.Limit {
+		writeError(w, http.StatusBadRequest, "cost must be between 1 and limit")
+		return
+	}
+
+	args := []string{
+		strconv.FormatInt(req.Limit, 10),
+		strconv.FormatInt(req.Period, 10),
+		strconv.FormatInt(req.Cost, 10),
+	}
+	if req.Algorithm == "" {
+		req.Algorithm = "token_bucket"
+	}
+	var script *valkey.Lua
+	switch req.Algorithm {
+	case "token_bucket":
+		script = TokenBucketScript
+	case "sliding_window":
+		id, err := randomHex(8)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		script = bucketWindowScript
+		args = append(args, id)
+	default:
+		writeError(w, http.StatusBadRequest, "algorithm must be token_bucket or sliding_window")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	// The algorithm is part of the key, as the two keep different state
+	key := namespacedKey(r, bucketKeyPrefix+req.Algorithm+":"+bucket)
+	result, err := script.Exec(ctx, h.client, []string{key}, args).AsIntSlice()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(RateLimitResponse{
+		Bucket:     bucket,
+		Allowed:    result[0] == 1,
+		Limit:      req.Limit,
+		Remaining:  result[1],
+		Reset:      result[3],
+		RetryAfter: result[2],
+	})
+}
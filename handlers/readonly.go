@@ -0,0 +1,18 @@
+package handlers
+
+import "context"
+
+type readOnlyKey struct{}
+
+// WithReadOnly returns a copy of ctx marking the server as being in
+// read-only mode, so reads skip the writes they would otherwise make on the
+// side, such as renewing a session's TTL.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// ReadOnly reports whether ctx was marked with WithReadOnly.
+func ReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	return readOnly
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"valkey-rest/auth"
+)
+
+// decodeJSON decodes a request body into v, rejecting unknown fields and
+// trailing data. It writes a 413 or 400 response and returns false on error.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("request body must contain a single JSON object")
+	}
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	msg := "invalid request body"
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit))
+		return false
+	case errors.Is(err, io.EOF):
+		msg = "request body is required"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		msg = "invalid request body: unexpected end of JSON"
+	case errors.As(err, &syntaxErr):
+		msg = fmt.Sprintf("invalid request body: malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		msg = fmt.Sprintf("invalid request body: field %q must be %s", typeErr.Field, typeErr.Type)
+	default:
+		// Unknown fields and the trailing data check have readable messages
+		msg = "invalid request body: " + err.Error()
+	}
+
+	writeError(w, http.StatusBadRequest, msg)
+	return false
+}
+
+// DecodeJSON is decodeJSON for handlers outside this package.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return decodeJSON(w, r, v)
+}
+
+const octetStream = "application/octet-stream"
+
+// hasContentType reports whether the request body has the given media type,
+// ignoring parameters such as charset.
+func hasContentType(r *http.Request, mediaType string) bool {
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && ct == mediaType
+}
+
+// acceptsRaw reports whether the client asked for raw bytes rather than JSON.
+// Only an explicit application/octet-stream in Accept selects raw output, so
+// clients sending */* or nothing keep getting JSON.
+func acceptsRaw(r *http.Request) bool {
+	return accepts(r, octetStream)
+}
+
+// accepts reports whether the Accept header lists mediaType explicitly,
+// with a non-zero quality. Wildcards don't count.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || accepted != mediaType {
+			continue
+		}
+		return params["q"] != "0"
+	}
+	return false
+}
+
+// readRawBody reads a whole request body. It writes a 413 response if the
+// body exceeds the limit, or a 400 if it can't be read, and returns false.
+func readRawBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit))
+			return nil, false
+		}
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return nil, false
+	}
+	return body, true
+}
+
+// ReadRawBody is readRawBody for handlers outside this package.
+func ReadRawBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	return readRawBody(w, r)
+}
+
+// errReservedKey is reported for keys under InternalKeyPrefix.
+const errReservedKey = "key is reserved for internal use"
+
+// checkKeys writes a 403 response and returns false unless the principal may
+// access every key and none is reserved. Used for keys that arrive in bodies
+// or query strings, which authMiddleware doesn't see.
+func checkKeys(w http.ResponseWriter, r *http.Request, keys ...string) bool {
+	for _, key := range keys {
+		if ReservedKey(key) {
+			writeError(w, http.StatusForbidden, errReservedKey)
+			return false
+		}
+	}
+	p := auth.FromContext(r.Context())
+	if p == nil {
+		return true
+	}
+	for _, key := range keys {
+		if !p.CanAccessKey(namespacedKey(r, key)) {
+			writeError(w, http.StatusForbidden, "access to key denied")
+			return false
+		}
+	}
+	return true
+}
+
+// scanPattern returns the stored SCAN pattern for a client's pattern,
+// narrowed to the keys the principal may access. Results must still be
+// checked with CanAccessKey.
+func scanPattern(r *http.Request, pattern string) string {
+	pattern = namespacedKey(r, pattern)
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.ScanPattern(pattern)
+	}
+	return pattern
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// failingBody returns some of a body and then a read error, like a client
+// that drops the connection mid-upload.
+type failingBody struct{ io.Reader }
+
+func (b failingBody) Read(p []byte) (int, error) {
+	if n, err := b.Reader.Read(p); err != io.EOF {
+		return n, err
+	}
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestBodyReadErrors(t *testing.T) {
+	readers := map[string]func(w http.ResponseWriter, r *http.Request) bool{
+		"decodeJSON": func(w http.ResponseWriter, r *http.Request) bool {
+			var v map[string]interface{}
+			return decodeJSON(w, r, &v)
+		},
+		"readRawBody": func(w http.ResponseWriter, r *http.Request) bool {
+			_, ok := readRawBody(w, r)
+			return ok
+		},
+	}
+
+	for name, read := range readers {
+		t.Run(name, func(t *testing.T) {
+			for _, tc := range []struct {
+				name string
+				body func(w http.ResponseWriter) io.ReadCloser
+				want int
+			}{
+				{"over the limit", func(w http.ResponseWriter) io.ReadCloser {
+					return http.MaxBytesReader(w, io.NopCloser(strings.NewReader(`{"value":"too long"}`)), 8)
+				}, http.StatusRequestEntityTooLarge},
+				{"read error", func(w http.ResponseWriter) io.ReadCloser {
+					return io.NopCloser(failingBody{strings.NewReader(`{"value":`)})
+				}, http.StatusBadRequest},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					rec := httptest.NewRecorder()
+					req := httptest.NewRequest(http.MethodPost, "/", nil)
+					req.Body = tc.body(rec)
+					if read(rec, req) {
+						t.Fatal("read succeeded")
+					}
+					if rec.Code != tc.want {
+						t.Errorf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body)
+					}
+				})
+			}
+		})
+	}
+}
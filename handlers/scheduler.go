@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	scheduleBatch = 100
+	// scheduleLease is how long a claimed job is hidden from other sweepers.
+	// A job whose sweeper dies before finishing it is picked up again after.
+	scheduleLease    = time.Minute
+	maxScheduleDelay = 365 * 24 * time.Hour
+)
+
+// claimScheduledScript takes up to ARGV[1] due jobs from the schedule by
+// moving them ARGV[2] ms into the future, so concurrent sweepers never claim
+// the same job, and one that isn't completed comes due again.
+//
+// KEYS[1] schedule. Returns {lease score, key, fire time, key, fire time...}.
+var claimScheduledScript = valkey.NewLuaScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'WITHSCORES', 'LIMIT', 0, ARGV[1])
+local lease = now + tonumber(ARGV[2])
+for i = 1, #due, 2 do
+  redis.call('ZADD', KEYS[1], lease, due[i])
+end
+table.insert(due, 1, lease)
+return due
+`)
+
+// completeScheduledScript removes a claimed job unless it was rescheduled
+// while it ran.
+//
+// KEYS[1] schedule; ARGV: key, lease score. Returns 1 if it was removed.
+var completeScheduledScript = valkey.NewLuaScript(`
+if tonumber(redis.call('ZSCORE', KEYS[1], ARGV[1])) == tonumber(ARGV[2]) then
+  return redis.call('ZREM', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+type ScheduleRequest struct {
+	At          *time.Time `json:"at,omitempty"`       // When the job fires; or use delay_ms
+	Delay       int64      `json:"delay_ms,omitempty"` // Milliseconds from now until the job fires
+	Action      string     `json:"action,omitempty"`   // "delete" (default) or "move"
+	Destination string     `json:"destination,omitempty"`
+}
+
+// ScheduledJob is a key's pending job.
+type ScheduledJob struct {
+	Key         string    `json:"key"`
+	Action      string    `json:"action"`
+	Destination string    `json:"destination,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// scheduledAction is what happens to a key when its job fires. Keys added to
+// the schedule without one are deleted.
+type scheduledAction struct {
+	Action      string `json:"action"`
+	Destination string `json:"destination,omitempty"` // Stored key
+}
+
+// ScheduledEvent is the JSON body delivered to the scheduler's callback.
+type ScheduledEvent struct {
+	ID          string    `json:"id"`
+	Key         string    `json:"key"`
+	Action      string    `json:"action"`
+	Destination string    `json:"destination,omitempty"`
+	Result      string    `json:"result"` // "deleted", "moved", "missing" or "failed"
+	Error       string    `json:"error,omitempty"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	FiredAt     time.Time `json:"fired_at"`
+}
+
+// SchedulerOptions configure RunScheduler.
+type SchedulerOptions struct {
+	Interval time.Duration // How often the schedule is checked for due jobs
+	// Callback, if set, receives a ScheduledEvent for every job run, signed
+	// with Secret as webhooks are
+	Callback string
+	Secret   string
+}
+
+// scheduleActionsKey holds each scheduled key's action, as a hash next to
+// the schedule sorted set.
+func (h *Handlers) scheduleActionsKey() string {
+	return h.scheduleKey + ":actions"
+}
+
+// HandleSchedule schedules a key to be deleted or moved at a given time,
+// replacing any job it already has.
+func (h *Handlers) HandleSchedule(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	var req ScheduleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if (req.At == nil) == (req.Delay == 0) {
+		writeError(w, http.StatusBadRequest, "exactly one of at and delay_ms is required")
+		return
+	}
+	at := time.Now().Add(time.Duration(req.Delay) * time.Millisecond)
+	if req.At != nil {
+		at = *req.At
+	}
+	if req.Delay < 0 || time.Until(at) > maxScheduleDelay {
+		writeError(w, http.StatusBadRequest, "the job must fire within a year")
+		return
+	}
+
+	action := scheduledAction{Action: req.Action}
+	switch req.Action {
+	case "", "delete":
+		action.Action = "delete"
+		if req.Destination != "" {
+			writeError(w, http.StatusBadRequest, "destination is only used by move")
+			return
+		}
+	case "move":
+		if req.Destination == "" {
+			writeError(w, http.StatusBadRequest, "destination is required")
+			return
+		}
+		if !checkKeys(w, r, req.Destination) {
+			return
+		}
+		action.Destination = namespacedKey(r, req.Destination)
+	default:
+		writeError(w, http.StatusBadRequest, "action must be delete or move")
+		return
+	}
+	data, err := json.Marshal(action)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	// The action is written first, so a sweeper never sees the job without it
+	storedKey := namespacedKey(r, key)
+	for _, resp := range h.client.DoMulti(ctx,
+		h.client.B().Hset().Key(h.scheduleActionsKey()).FieldValue().FieldValue(storedKey, string(data)).Build(),
+		h.client.B().Zadd().Key(h.scheduleKey).ScoreMember().ScoreMember(float64(at.UnixMilli()), storedKey).Build(),
+	) {
+		if err := resp.Error(); err != nil {
+			writeCommandError(w, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ScheduledJob{Key: key, Action: action.Action, Destination: req.Destination, At: time.UnixMilli(at.UnixMilli()).UTC()})
+}
+
+// HandleGetSchedule returns a key's pending job. A job being run reports the
+// time it will be retried if its sweeper doesn't finish it.
+func (h *Handlers) HandleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	storedKey := namespacedKey(r, key)
+	resps := h.client.DoMulti(ctx,
+		h.client.B().Zscore().Key(h.scheduleKey).Member(storedKey).Build(),
+		h.client.B().Hget().Key(h.scheduleActionsKey()).Field(storedKey).Build(),
+	)
+	score, err := resps[0].AsFloat64()
+	if valkey.IsValkeyNil(err) {
+		writeError(w, http.StatusNotFound, "key has no scheduled job")
+		return
+	}
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	action, err := h.scheduledAction(resps[1])
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(ScheduledJob{
+		Key:         key,
+		Action:      action.Action,
+		Destination: stripNamespace(r, action.Destination),
+		At:          time.UnixMilli(int64(score)).UTC(),
+	})
+}
+
+// HandleCancelSchedule removes a key's pending job.
+func (h *Handlers) HandleCancelSchedule(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	storedKey := namespacedKey(r, key)
+	removed, err := h.client.Do(ctx, h.client.B().Zrem().Key(h.scheduleKey).Member(storedKey).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if removed == 0 {
+		writeError(w, http.StatusNotFound, "key has no scheduled job")
+		return
+	}
+	if err := h.client.Do(ctx, h.client.B().Hdel().Key(h.scheduleActionsKey()).Field(storedKey).Build()).Error(); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled", "key": key})
+}
+
+// scheduledAction decodes an HGET of a key's action, defaulting to delete.
+func (h *Handlers) scheduledAction(resp valkey.ValkeyResult) (scheduledAction, error) {
+	action := scheduledAction{Action: "delete"}
+	data, err := resp.ToString()
+	if valkey.IsValkeyNil(err) {
+		return action, nil
+	}
+	if err != nil {
+		return action, err
+	}
+	if err := json.Unmarshal([]byte(data), &action); err != nil {
+		log.Printf("Invalid scheduled action %q, deleting the key instead: %v", data, err)
+		return scheduledAction{Action: "delete"}, nil
+	}
+	return action, nil
+}
+
+// RunScheduler runs due jobs from the schedule until ctx is cancelled. Any
+// number of instances may run it; each job is claimed by one of them.
+func (h *Handlers) RunScheduler(ctx context.Context, opts SchedulerOptions) {
+	var queue chan webhookDelivery
+	if opts.Callback != "" {
+		queue = make(chan webhookDelivery, webhookQueueSize)
+		defer close(queue)
+		callback := &Webhook{URL: opts.Callback, Secret: opts.Secret}
+		client := &http.Client{Timeout: 10 * time.Second}
+		for i := 0; i < webhookWorkers; i++ {
+			go func() {
+				for d := range queue {
+					d.webhook = callback
+					deliverWebhook(ctx, client, d)
+				}
+			}()
+		}
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		// Keep going while full batches come back, so a backlog drains
+		// without waiting for the next tick
+		for ctx.Err() == nil && h.sweepSchedule(ctx, queue) == scheduleBatch {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepSchedule claims and runs one batch of due jobs, returning how many it
+// claimed.
+func (h *Handlers) sweepSchedule(ctx context.Context, callbacks chan<- webhookDelivery) int {
+	claimed, err := claimScheduledScript.Exec(ctx, h.client, []string{h.scheduleKey}, []string{
+		strconv.Itoa(scheduleBatch),
+		strconv.FormatInt(scheduleLease.Milliseconds(), 10),
+	}).ToArray()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to claim scheduled jobs: %v", err)
+		}
+		return 0
+	}
+	lease, err := claimed[0].AsInt64()
+	if err != nil {
+		log.Printf("Failed to claim scheduled jobs: %v", err)
+		return 0
+	}
+
+	jobs := claimed[1:]
+	for i := 0; i+1 < len(jobs); i += 2 {
+		key, _ := jobs[i].ToString()
+		score, _ := jobs[i+1].ToString()
+		fireAt, _ := strconv.ParseFloat(score, 64)
+
+		event, retry := h.runScheduledJob(ctx, key)
+		if retry {
+			// Left claimed, so it is tried again once the lease runs out
+			continue
+		}
+		event.ScheduledAt = time.UnixMilli(int64(fireAt)).UTC()
+		h.completeScheduledJob(ctx, key, lease)
+
+		if callbacks != nil {
+			event.ID, _ = randomHex(8)
+			body, _ := json.Marshal(event)
+			select {
+			case callbacks <- webhookDelivery{body: body, id: event.ID}:
+			default:
+				log.Printf("Scheduler callback queue full, dropping event for %s", key)
+			}
+		}
+	}
+	return len(jobs) / 2
+}
+
+// runScheduledJob deletes or moves a key. It reports retry for connection
+// errors, which may pass, and a failed result for errors Valkey returned.
+func (h *Handlers) runScheduledJob(ctx context.Context, key string) (ScheduledEvent, bool) {
+	action, err := h.scheduledAction(h.client.Do(ctx, h.client.B().Hget().Key(h.scheduleActionsKey()).Field(key).Build()))
+	if err != nil {
+		log.Printf("Failed to read the scheduled action for %s: %v", key, err)
+		return ScheduledEvent{}, true
+	}
+
+	event := ScheduledEvent{Key: key, Action: action.Action, Destination: action.Destination, FiredAt: time.Now().UTC()}
+	switch action.Action {
+	case "move":
+		err = h.client.Do(ctx, h.client.B().Rename().Key(key).Newkey(action.Destination).Build()).Error()
+		if err == nil {
+			event.Result = "moved"
+			h.invalidate(key, action.Destination)
+		} else if verr, ok := valkey.IsValkeyErr(err); ok && strings.Contains(verr.Error(), "no such key") {
+			event.Result = "missing"
+			err = nil
+		}
+	default:
+		var deleted int64
+		if deleted, err = h.client.Do(ctx, h.client.B().Del().Key(key).Build()).AsInt64(); err == nil {
+			event.Result = "deleted"
+			if deleted == 0 {
+				event.Result = "missing"
+			}
+			h.invalidate(key)
+		}
+	}
+
+	if err != nil {
+		if _, ok := valkey.IsValkeyErr(err); !ok {
+			log.Printf("Failed to run the scheduled %s of %s, retrying later: %v", action.Action, key, err)
+			return ScheduledEvent{}, true
+		}
+		log.Printf("Scheduled %s of %s failed: %v", action.Action, key, err)
+		event.Result = "failed"
+		event.Error = err.Error()
+	}
+	return event, false
+}
+
+// completeScheduledJob removes a job that has run, along with its action
+// unless the key was scheduled again in the meantime.
+func (h *Handlers) completeScheduledJob(ctx context.Context, key string, lease int64) {
+	removed, err := completeScheduledScript.Exec(ctx, h.client, []string{h.scheduleKey}, []string{key, strconv.FormatInt(lease, 10)}).AsInt64()
+	if err != nil {
+		log.Printf("Failed to complete the scheduled job of %s: %v", key, err)
+		return
+	}
+	if removed == 1 {
+		if err := h.client.Do(ctx, h.client.B().Hdel().Key(h.scheduleActionsKey()).Field(key).Build()).Error(); err != nil {
+			log.Printf("Failed to remove the scheduled action of %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// validScriptName matches the names scripts are registered under, taken
+// from their file names without the .lua extension.
+var validScriptName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type ScriptRequest struct {
+	Keys []string          `json:"keys,omitempty"`
+	Args []json.RawMessage `json:"args,omitempty"` // Strings, numbers or booleans
+}
+
+// ScriptRegistry holds the Lua scripts callers are allowed to run. Scripts
+// are run with EVALSHA, falling back to EVAL the first time a node hasn't
+// cached them.
+type ScriptRegistry struct {
+	scripts map[string]*valkey.Lua
+}
+
+// LoadScripts registers every *.lua file in dir. An empty dir registers none.
+func LoadScripts(dir string) (*ScriptRegistry, error) {
+	reg := &ScriptRegistry{scripts: make(map[string]*valkey.Lua)}
+	if dir == "" {
+		return reg, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return nil, fmt.Errorf("list scripts: %w", err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".lua")
+		if !validScriptName.MatchString(name) {
+			return nil, fmt.Errorf("invalid script name %q", name)
+		}
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read script %s: %w", name, err)
+		}
+		reg.scripts[name] = valkey.NewLuaScript(string(body))
+	}
+	return reg, nil
+}
+
+// Names returns the registered script names in sorted order.
+func (sr *ScriptRegistry) Names() []string {
+	names := make([]string, 0, len(sr.scripts))
+	for name := range sr.scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stringArgs converts JSON strings, numbers and booleans to command
+// arguments. Numbers keep their JSON text so large integers stay exact.
+func stringArgs(raw []json.RawMessage) ([]string, error) {
+	args := make([]string, 0, len(raw))
+	for i, arg := range raw {
+		var v interface{}
+		if err := json.Unmarshal(arg, &v); err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case string:
+			args = append(args, v)
+		case float64:
+			args = append(args, strings.TrimSpace(string(arg)))
+		case bool:
+			args = append(args, strconv.FormatBool(v))
+		default:
+			return nil, fmt.Errorf("argument %d must be a string, number or boolean", i)
+		}
+	}
+	return args, nil
+}
+
+func (h *Handlers) HandleListScripts(w http.ResponseWriter, r *http.Request) {
+	names := h.scripts.Names()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scripts": names,
+		"count":   len(names),
+	})
+}
+
+func (h *Handlers) HandleRunScript(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	script, ok := h.scripts.scripts[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "script not found")
+		return
+	}
+
+	var req ScriptRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	args, err := stringArgs(req.Args)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Keys are checked against the token's patterns and namespaced like any
+	// other key; scripts should only touch keys passed in KEYS
+	if !checkKeys(w, r, req.Keys...) {
+		return
+	}
+	keys := make([]string, len(req.Keys))
+	for i, key := range req.Keys {
+		keys[i] = namespacedKey(r, key)
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	result, err := script.Exec(ctx, h.client, keys, args).ToAny()
+	if err != nil && !valkey.IsValkeyNil(err) {
+		if verr, ok := valkey.IsValkeyErr(err); ok {
+			WriteError(w, http.StatusBadRequest, CodeValkeyError, "script error: "+verr.Error())
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"script": name,
+		"result": result,
+	})
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const sessionKeyPrefix = "valkey-rest:session:"
+
+// createdField holds a session's creation time in Unix milliseconds. The
+// empty field name can't clash with session data, which may not use it, and
+// keeps a session with no data from disappearing.
+const createdField = ""
+
+// validSessionID matches the IDs HandleCreateSession hands out.
+var validSessionID = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// updateSessionScript sets and deletes session fields if the session still
+// exists, renewing its TTL.
+//
+// KEYS[1] session; ARGV: ttl in ms, number of fields to set, field/value
+// pairs, then fields to delete. Returns 1 if the session exists.
+var updateSessionScript = valkey.NewLuaScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  return 0
+end
+local n = tonumber(ARGV[2])
+if n > 0 then
+  redis.call('HSET', KEYS[1], unpack(ARGV, 3, 2 + 2 * n))
+end
+if #ARGV > 2 + 2 * n then
+  redis.call('HDEL', KEYS[1], unpack(ARGV, 3 + 2 * n))
+end
+return redis.call('PEXPIRE', KEYS[1], ARGV[1])
+`)
+
+type SessionRequest struct {
+	Data   map[string]string `json:"data,omitempty"`   // Fields to set
+	Delete []string          `json:"delete,omitempty"` // Fields to remove, on update only
+}
+
+type SessionResponse struct {
+	ID        string            `json:"id"`
+	Data      map[string]string `json:"data"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"` // Moves forward on every access outside read-only mode
+}
+
+// sessionKey validates the {id} path value and returns the session's key. An
+// ID that can't exist is reported as a missing session.
+func sessionKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if !validSessionID.MatchString(id) {
+		writeError(w, http.StatusNotFound, "session not found")
+		return "", false
+	}
+	return namespacedKey(r, sessionKeyPrefix+id), true
+}
+
+// validSessionData writes a 400 response and returns false if data uses the
+// reserved empty field name.
+func validSessionData(w http.ResponseWriter, data map[string]string, fields []string) bool {
+	_, reserved := data[createdField]
+	for _, field := range fields {
+		reserved = reserved || field == createdField
+	}
+	if reserved {
+		writeError(w, http.StatusBadRequest, "session fields must have a name")
+		return false
+	}
+	return true
+}
+
+// sessionResponse builds the response for a session hash read with HGETALL,
+// separating the creation time from the data.
+func sessionResponse(id string, fields map[string]string, expires time.Time) SessionResponse {
+	ms, _ := strconv.ParseInt(fields[createdField], 10, 64)
+	delete(fields, createdField)
+	return SessionResponse{
+		ID:        id,
+		Data:      fields,
+		CreatedAt: time.UnixMilli(ms).UTC(),
+		ExpiresAt: expires.UTC(),
+	}
+}
+
+func (h *Handlers) HandleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req SessionRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.Delete) > 0 {
+		writeError(w, http.StatusBadRequest, "delete is only allowed when updating a session")
+		return
+	}
+	if !validSessionData(w, req.Data, nil) {
+		return
+	}
+
+	id, err := randomHex(32)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	now := time.Now()
+	key := namespacedKey(r, sessionKeyPrefix+id)
+	builder := h.client.B().Hset().Key(key).FieldValue().FieldValue(createdField, strconv.FormatInt(now.UnixMilli(), 10))
+	for field, value := range req.Data {
+		builder = builder.FieldValue(field, value)
+	}
+	for _, resp := range h.client.DoMulti(ctx,
+		h.client.B().Multi().Build(),
+		builder.Build(),
+		h.client.B().Pexpire().Key(key).Milliseconds(h.sessionTTL.Milliseconds()).Build(),
+		h.client.B().Exec().Build(),
+	) {
+		if err := resp.Error(); err != nil {
+			writeCommandError(w, err)
+			return
+		}
+	}
+
+	data := req.Data
+	if data == nil {
+		data = map[string]string{}
+	}
+	w.Header().Set("Location", "/sessions/"+id)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(SessionResponse{
+		ID:        id,
+		Data:      data,
+		CreatedAt: time.UnixMilli(now.UnixMilli()).UTC(),
+		ExpiresAt: now.Add(h.sessionTTL).UTC(),
+	})
+}
+
+// HandleGetSession returns a session's data and renews its TTL. In
+// read-only mode the TTL is left as it is.
+func (h *Handlers) HandleGetSession(w http.ResponseWriter, r *http.Request) {
+	key, ok := sessionKey(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	readOnly := ReadOnly(r.Context())
+	ttl := h.client.B().Pexpire().Key(key).Milliseconds(h.sessionTTL.Milliseconds()).Build()
+	if readOnly {
+		ttl = h.client.B().Pttl().Key(key).Build()
+	}
+	resps := h.client.DoMulti(ctx, ttl, h.client.B().Hgetall().Key(key).Build())
+
+	// PEXPIRE answers 1 if it renewed the session; PTTL answers -2 if the
+	// session is gone, and otherwise the milliseconds it has left
+	n, err := resps[0].AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	found, expires := n == 1, time.Now().Add(h.sessionTTL)
+	if readOnly {
+		found, expires = n >= 0, time.Now().Add(time.Duration(n)*time.Millisecond)
+	}
+	fields, err := resps[1].AsStrMap()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if !found || len(fields) == 0 {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(sessionResponse(r.PathValue("id"), fields, expires))
+}
+
+// HandleUpdateSession sets and removes fields of an existing session, and
+// renews its TTL.
+func (h *Handlers) HandleUpdateSession(w http.ResponseWriter, r *http.Request) {
+	key, ok := sessionKey(w, r)
+	if !ok {
+		return
+	}
+
+	var req SessionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.Data) == 0 && len(req.Delete) == 0 {
+		writeError(w, http.StatusBadRequest, "data or delete is required")
+		return
+	}
+	if !validSessionData(w, req.Data, req.Delete) {
+		return
+	}
+
+	args := []string{strconv.FormatInt(h.sessionTTL.Milliseconds(), 10), strconv.Itoa(len(req.Data))}
+	for field, value := range req.Data {
+		args = append(args, field, value)
+	}
+	args = append(args, req.Delete...)
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	updated, err := updateSessionScript.Exec(ctx, h.client, []string{key}, args).AsBool()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if !updated {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	fields, err := h.client.Do(ctx, h.client.B().Hgetall().Key(key).Build()).AsStrMap()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(sessionResponse(r.PathValue("id"), fields, time.Now().Add(h.sessionTTL)))
+}
+
+// HandleTouchSession renews a session's TTL without reading it.
+func (h *Handlers) HandleTouchSession(w http.ResponseWriter, r *http.Request) {
+	key, ok := sessionKey(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	renewed, err := h.client.Do(ctx, h.client.B().Pexpire().Key(key).Milliseconds(h.sessionTTL.Milliseconds()).Build()).AsBool()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if !renewed {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         r.PathValue("id"),
+		"expires_at": time.Now().Add(h.sessionTTL).UTC(),
+	})
+}
+
+func (h *Handlers) HandleDestroySession(w http.ResponseWriter, r *http.Request) {
+	key, ok := sessionKey(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	deleted, err := h.client.Do(ctx, h.client.B().Del().Key(key).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "destroyed", "id": r.PathValue("id")})
+}
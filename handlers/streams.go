@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+type StreamAddRequest struct {
+	ID     string            `json:"id,omitempty"`     // Entry ID, defaults to "*" (auto-generated)
+	Fields map[string]string `json:"fields"`           // Field/value pairs of the entry
+	MaxLen int64             `json:"maxlen,omitempty"` // Approximate cap on stream length
+}
+
+type StreamGroupRequest struct {
+	Group string `json:"group"`
+	ID    string `json:"id,omitempty"` // Start ID for the group, defaults to "$" (new entries only)
+}
+
+type StreamAckRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type StreamEntry struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+// maxStreamBlock caps how long a long-polling read may hold a request open.
+const maxStreamBlock = 30 * time.Second
+
+func toStreamEntries(entries []valkey.XRangeEntry) []StreamEntry {
+	out := make([]StreamEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, StreamEntry{ID: e.ID, Fields: e.FieldValues})
+	}
+	return out
+}
+
+// parseStreamReadParams reads the count and block query parameters shared by
+// XREAD and XREADGROUP endpoints.
+func parseStreamReadParams(r *http.Request) (count int64, block time.Duration, ok bool) {
+	count = 10
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > 1000 {
+			return 0, 0, false
+		}
+		count = n
+	}
+
+	if v := r.URL.Query().Get("block"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms < 0 {
+			return 0, 0, false
+		}
+		block = time.Duration(ms) * time.Millisecond
+		if block > maxStreamBlock {
+			block = maxStreamBlock
+		}
+	}
+	return count, block, true
+}
+
+// extendForBlock makes room in the request's deadlines for a blocking read
+// or another long-running operation.
+func extendForBlock(w http.ResponseWriter, r *http.Request, block time.Duration) (context.Context, context.CancelFunc) {
+	timeout := block + CommandTimeout(r.Context())
+	if block > 0 {
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+func (h *Handlers) HandleStreamAdd(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	var req StreamAddRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.Fields) == 0 {
+		writeError(w, http.StatusBadRequest, "fields are required")
+		return
+	}
+
+	if req.ID == "" {
+		req.ID = "*"
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	var cmd valkey.Completed
+	if req.MaxLen > 0 {
+		builder := h.client.B().Xadd().Key(namespacedKey(r, key)).Maxlen().Almost().Threshold(strconv.FormatInt(req.MaxLen, 10)).Id(req.ID).FieldValue()
+		for f, v := range req.Fields {
+			builder = builder.FieldValue(f, v)
+		}
+		cmd = builder.Build()
+	} else {
+		builder := h.client.B().Xadd().Key(namespacedKey(r, key)).Id(req.ID).FieldValue()
+		for f, v := range req.Fields {
+			builder = builder.FieldValue(f, v)
+		}
+		cmd = builder.Build()
+	}
+
+	id, err := h.client.Do(ctx, cmd).ToString()
+	if err != nil {
+		if verr, ok := valkey.IsValkeyErr(err); ok && strings.Contains(verr.Error(), "ID specified") {
+			writeError(w, http.StatusBadRequest, "invalid entry id")
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "added", "key": key, "id": id})
+}
+
+func (h *Handlers) HandleStreamRange(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	start := r.URL.Query().Get("start")
+	if start == "" {
+		start = "-"
+	}
+	end := r.URL.Query().Get("end")
+	if end == "" {
+		end = "+"
+	}
+
+	count := int64(100)
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, http.StatusBadRequest, "count must be between 1 and 1000")
+			return
+		}
+		count = n
+	}
+
+	if acceptsNDJSON(r) {
+		h.streamStreamRange(w, r, key, start, end, count)
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	entries, err := h.client.Do(ctx, h.client.B().Xrange().Key(namespacedKey(r, key)).Start(start).End(end).Count(count).Build()).AsXRange()
+	if err != nil {
+		if _, ok := valkey.IsValkeyErr(err); ok && !valkey.IsValkeyNil(err) {
+			writeError(w, http.StatusBadRequest, "invalid range")
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":     key,
+		"entries": toStreamEntries(entries),
+		"count":   len(entries),
+	})
+}
+
+// streamStreamRange writes every entry from start to end as a line of
+// NDJSON, reading count entries at a time rather than stopping at the first
+// count.
+func (h *Handlers) streamStreamRange(w http.ResponseWriter, r *http.Request, key, start, end string, count int64) {
+	stream, ok := startNDJSON(w)
+	if !ok {
+		return
+	}
+	for {
+		entries, err := h.xrange(r, namespacedKey(r, key), start, end, count)
+		if err != nil {
+			if _, ok := valkey.IsValkeyErr(err); ok && !valkey.IsValkeyNil(err) {
+				stream.write(NewErrorResponse(http.StatusBadRequest, CodeBadRequest, "invalid range"))
+				return
+			}
+			stream.fail("range failed: ", err)
+			return
+		}
+		for _, entry := range toStreamEntries(entries) {
+			stream.write(entry)
+		}
+		if !stream.flush() || int64(len(entries)) < count {
+			return
+		}
+		// The next batch starts just after the last entry read
+		start = "(" + entries[len(entries)-1].ID
+	}
+}
+
+// xrange reads one batch of a stream with its own command timeout.
+func (h *Handlers) xrange(r *http.Request, storedKey, start, end string, count int64) ([]valkey.XRangeEntry, error) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+	return h.client.Do(ctx, h.client.B().Xrange().Key(storedKey).Start(start).End(end).Count(count).Build()).AsXRange()
+}
+
+// HandleStreamRead returns entries after the given ID, optionally long-polling
+// for up to `block` milliseconds when none are available yet.
+func (h *Handlers) HandleStreamRead(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		id = "$"
+	}
+
+	count, block, ok := parseStreamReadParams(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid count or block parameter")
+		return
+	}
+
+	ctx, cancel := extendForBlock(w, r, block)
+	defer cancel()
+
+	var cmd valkey.Completed
+	if block > 0 {
+		cmd = h.client.B().Xread().Count(count).Block(block.Milliseconds()).Streams().Key(namespacedKey(r, key)).Id(id).Build()
+	} else {
+		cmd = h.client.B().Xread().Count(count).Streams().Key(namespacedKey(r, key)).Id(id).Build()
+	}
+
+	h.writeStreamRead(ctx, w, key, namespacedKey(r, key), cmd)
+}
+
+func (h *Handlers) HandleStreamCreateGroup(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	var req StreamGroupRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Group == "" {
+		writeError(w, http.StatusBadRequest, "group is required")
+		return
+	}
+
+	if req.ID == "" {
+		req.ID = "$"
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	err := h.client.Do(ctx, h.client.B().XgroupCreate().Key(namespacedKey(r, key)).Group(req.Group).Id(req.ID).Mkstream().Build()).Error()
+	if err != nil {
+		if verr, ok := valkey.IsValkeyErr(err); ok && strings.HasPrefix(verr.Error(), "BUSYGROUP") {
+			writeError(w, http.StatusConflict, "group already exists")
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "created", "key": key, "group": req.Group})
+}
+
+// HandleStreamReadGroup reads entries on behalf of a consumer in a group. By
+// default only never-delivered entries (">") are returned; passing an explicit
+// id re-reads the consumer's pending entries.
+func (h *Handlers) HandleStreamReadGroup(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	group := r.PathValue("group")
+	if key == "" || group == "" {
+		writeError(w, http.StatusBadRequest, "key and group are required")
+		return
+	}
+
+	consumer := r.URL.Query().Get("consumer")
+	if consumer == "" {
+		writeError(w, http.StatusBadRequest, "consumer is required")
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		id = ">"
+	}
+
+	count, block, ok := parseStreamReadParams(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid count or block parameter")
+		return
+	}
+
+	ctx, cancel := extendForBlock(w, r, block)
+	defer cancel()
+
+	var cmd valkey.Completed
+	if block > 0 {
+		cmd = h.client.B().Xreadgroup().Group(group, consumer).Count(count).Block(block.Milliseconds()).Streams().Key(namespacedKey(r, key)).Id(id).Build()
+	} else {
+		cmd = h.client.B().Xreadgroup().Group(group, consumer).Count(count).Streams().Key(namespacedKey(r, key)).Id(id).Build()
+	}
+
+	h.writeStreamRead(ctx, w, key, namespacedKey(r, key), cmd)
+}
+
+func (h *Handlers) writeStreamRead(ctx context.Context, w http.ResponseWriter, key, storedKey string, cmd valkey.Completed) {
+	streams, err := h.client.Do(ctx, cmd).AsXRead()
+	if err != nil && !valkey.IsValkeyNil(err) {
+		if verr, ok := valkey.IsValkeyErr(err); ok && strings.HasPrefix(verr.Error(), "NOGROUP") {
+			writeError(w, http.StatusNotFound, "group not found")
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	// A blocking read that timed out returns nil, which is simply no entries
+	entries := toStreamEntries(streams[storedKey])
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":     key,
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+func (h *Handlers) HandleStreamAck(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	group := r.PathValue("group")
+	if key == "" || group == "" {
+		writeError(w, http.StatusBadRequest, "key and group are required")
+		return
+	}
+
+	var req StreamAckRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeError(w, http.StatusBadRequest, "ids are required")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	acked, err := h.client.Do(ctx, h.client.B().Xack().Key(namespacedKey(r, key)).Group(group).Id(req.IDs...).Build()).AsInt64()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "acknowledged",
+		"key":          key,
+		"group":        group,
+		"acknowledged": acked,
+	})
+}
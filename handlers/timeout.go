@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// TimeoutHeader lets a client choose the command timeout of its request, in
+// milliseconds. The server caps it at its configured maximum.
+const TimeoutHeader = "X-Timeout-Ms"
+
+// DefaultCommandTimeout bounds the Valkey commands of a request when no
+// timeout has been set on its context.
+const DefaultCommandTimeout = 5 * time.Second
+
+type commandTimeoutKey struct{}
+
+// WithCommandTimeout returns a copy of ctx whose requests use timeout for
+// their Valkey commands.
+func WithCommandTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, commandTimeoutKey{}, timeout)
+}
+
+// CommandTimeout returns the command timeout set on ctx, or
+// DefaultCommandTimeout.
+func CommandTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(commandTimeoutKey{}).(time.Duration); ok {
+		return timeout
+	}
+	return DefaultCommandTimeout
+}
+
+// commandContext bounds the Valkey commands of a request by its command
+// timeout.
+func commandContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), CommandTimeout(r.Context()))
+}
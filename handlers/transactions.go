@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+)
+
+// maxTransactionCommands caps the size of a single transaction.
+const maxTransactionCommands = 1000
+
+type TransactionRequest struct {
+	Commands [][]json.RawMessage `json:"commands"` // Each command is its name followed by its arguments
+}
+
+// TransactionResult is the outcome of one command in a transaction. Commands
+// that fail at run time don't roll back the others, as in Valkey itself.
+type TransactionResult struct {
+	Result interface{} `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// HandleTransaction runs a list of commands atomically with MULTI/EXEC on a
+// dedicated connection, in a single round trip.
+func (h *Handlers) HandleTransaction(w http.ResponseWriter, r *http.Request) {
+	// Commands are arbitrary, so the same restrictions as /command apply
+	if RequestNamespace(r) != "" {
+		writeError(w, http.StatusBadRequest, "namespaces are not supported for arbitrary commands")
+		return
+	}
+	if p := auth.FromContext(r.Context()); p != nil && len(p.KeyPatterns) > 0 {
+		writeError(w, http.StatusForbidden, "tokens restricted to key patterns cannot run arbitrary commands")
+		return
+	}
+
+	var req TransactionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.Commands) == 0 || len(req.Commands) > maxTransactionCommands {
+		writeError(w, http.StatusBadRequest, "commands must contain between 1 and 1000 commands")
+		return
+	}
+
+	// Every command is checked before any is built
+	argv := make([][]string, len(req.Commands))
+	for i, raw := range req.Commands {
+		args, err := stringArgs(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(args) == 0 || args[0] == "" {
+			writeError(w, http.StatusBadRequest, "each command must start with a command name")
+			return
+		}
+		if !h.commands.Allowed(args) {
+			writeError(w, http.StatusForbidden, "command not allowed: "+strings.ToUpper(args[0]))
+			return
+		}
+		argv[i] = args
+	}
+
+	cmds := make(valkey.Commands, 0, len(argv)+2)
+	cmds = append(cmds, h.client.B().Multi().Build())
+	var slot uint16
+	haveSlot := false
+	for _, args := range argv {
+		cmd := arbitraryCommand(h.client, args)
+		// A transaction runs on one node, so in cluster mode every key must
+		// hash to the same slot. Checked here because mixing slots on a
+		// dedicated connection is a programming error in valkey-go.
+		if h.client.Mode() == valkey.ClientModeCluster && len(args) > 1 {
+			if haveSlot && cmd.Slot() != slot {
+				writeError(w, http.StatusBadRequest, "all keys in a transaction must hash to the same slot")
+				return
+			}
+			slot, haveSlot = cmd.Slot(), true
+		}
+		cmds = append(cmds, cmd)
+	}
+	cmds = append(cmds, h.client.B().Exec().Build())
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	var resps []valkey.ValkeyResult
+	h.clientFor(ctx).Dedicated(func(c valkey.DedicatedClient) error {
+		resps = c.DoMulti(ctx, cmds...)
+		return nil
+	})
+
+	replies, err := resps[len(resps)-1].ToArray()
+	if err != nil {
+		if verr, ok := valkey.IsValkeyErr(err); ok {
+			// EXECABORT: a command was rejected while queueing, report why
+			msg := verr.Error()
+			for _, resp := range resps[1 : len(resps)-1] {
+				if qerr, ok := valkey.IsValkeyErr(resp.Error()); ok {
+					msg = qerr.Error()
+					break
+				}
+			}
+			writeError(w, http.StatusBadRequest, "transaction aborted: "+msg)
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	results := make([]TransactionResult, len(replies))
+	for i, reply := range replies {
+		result, err := reply.ToAny()
+		if err != nil && !valkey.IsValkeyNil(err) {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Result = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	})
+}
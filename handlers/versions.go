@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const versionsKeyPrefix = "valkey-rest:versions:"
+
+// versionsKey returns the stream holding the versions of a stored key. It
+// shares the key's cluster slot so the scripts can update both: a key with a
+// hash tag keeps it, anything else becomes the tag. Keys with a stray "}"
+// and no hash tag can't be tagged, and are refused.
+func versionsKey(storedKey string) (string, bool) {
+	if open := strings.IndexByte(storedKey, '{'); open >= 0 {
+		if end := strings.IndexByte(storedKey[open+1:], '}'); end > 0 {
+			return versionsKeyPrefix + storedKey, true
+		}
+	}
+	if strings.IndexByte(storedKey, '}') >= 0 {
+		return "", false
+	}
+	return versionsKeyPrefix + "{" + storedKey + "}", true
+}
+
+// setVersionedScript stores a value and appends it to the key's versions.
+// The first versioned write also records the value it replaces, so it can be
+// restored.
+//
+// KEYS[1] key, KEYS[2] versions; ARGV: value, ttl in ms (0 for none),
+// versions kept. Returns the ID of the new version.
+var setVersionedScript = valkey.NewLuaScript(`
+if redis.call('EXISTS', KEYS[2]) == 0 then
+  local old = redis.call('GET', KEYS[1])
+  if old then
+    redis.call('XADD', KEYS[2], '*', 'value', old)
+  end
+end
+if ARGV[2] == '0' then
+  redis.call('SET', KEYS[1], ARGV[1])
+else
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+end
+return redis.call('XADD', KEYS[2], 'MAXLEN', ARGV[3], '*', 'value', ARGV[1])
+`)
+
+// restoreVersionScript sets a key back to one of its versions, which is
+// recorded as a new version.
+//
+// KEYS[1] key, KEYS[2] versions; ARGV: version ID, versions kept. Returns
+// the ID of the new version, or false if the version doesn't exist.
+var restoreVersionScript = valkey.NewLuaScript(`
+local entries = redis.call('XRANGE', KEYS[2], ARGV[1], ARGV[1])
+if #entries == 0 then
+  return false
+end
+local fields = entries[1][2]
+for i = 1, #fields, 2 do
+  if fields[i] == 'value' then
+    redis.call('SET', KEYS[1], fields[i + 1])
+    return redis.call('XADD', KEYS[2], 'MAXLEN', ARGV[2], '*', 'value', fields[i + 1])
+  end
+end
+return false
+`)
+
+type KeyVersion struct {
+	Version  string    `json:"version"`
+	Time     time.Time `json:"time"`
+	Value    string    `json:"value"`
+	Encoding string    `json:"encoding,omitempty"` // "base64" when the value isn't valid UTF-8
+}
+
+type KeyVersionsResponse struct {
+	Key      string       `json:"key"`
+	Versions []KeyVersion `json:"versions"` // Newest first
+}
+
+// versionTime returns the time a version was written, from its stream ID.
+func versionTime(id string) time.Time {
+	ms, _, _ := strings.Cut(id, "-")
+	n, _ := strconv.ParseInt(ms, 10, 64)
+	return time.UnixMilli(n).UTC()
+}
+
+// validVersion reports whether id is a complete stream ID, as versions are
+// listed.
+func validVersion(id string) bool {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	_, err1 := strconv.ParseUint(ms, 10, 64)
+	_, err2 := strconv.ParseUint(seq, 10, 64)
+	return err1 == nil && err2 == nil
+}
+
+// setVersioned handles POST /keys/{key} with versioned set, writing the
+// 201 response with the new version.
+func (h *Handlers) setVersioned(w http.ResponseWriter, r *http.Request, key, stored string, ttl time.Duration) {
+	if h.client == nil {
+		writeError(w, http.StatusBadRequest, "versioned writes need the valkey backend")
+		return
+	}
+	storedKey := namespacedKey(r, key)
+	historyKey, ok := versionsKey(storedKey)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "keys with unbalanced braces can't be versioned")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	args := []string{stored, strconv.FormatInt(ttl.Milliseconds(), 10), strconv.Itoa(h.keyVersions)}
+	version, err := setVersionedScript.Exec(ctx, h.client, []string{storedKey, historyKey}, args).ToString()
+	h.invalidate(storedKey)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etagFor(stored))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "created", "key": key, "version": version})
+}
+
+func (h *Handlers) HandleListVersions(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	historyKey, ok := versionsKey(namespacedKey(r, key))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no versions found")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	entries, err := h.client.Do(ctx, h.client.B().Xrevrange().Key(historyKey).End("+").Start("-").Build()).AsXRange()
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if len(entries) == 0 {
+		writeError(w, http.StatusNotFound, "no versions found")
+		return
+	}
+
+	resp := KeyVersionsResponse{Key: key, Versions: make([]KeyVersion, 0, len(entries))}
+	for _, entry := range entries {
+		value, err := h.compressor.Decode(entry.FieldValues["value"])
+		if err != nil {
+			log.Printf("Failed to decode version %s of %s: %v", entry.ID, key, err)
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		version := KeyVersion{Version: entry.ID, Time: versionTime(entry.ID), Value: value}
+		if !utf8.ValidString(value) {
+			version.Value = base64.StdEncoding.EncodeToString([]byte(value))
+			version.Encoding = "base64"
+		}
+		resp.Versions = append(resp.Versions, version)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handlers) HandleRestoreVersion(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	id := r.PathValue("version")
+	if !validVersion(id) {
+		writeError(w, http.StatusBadRequest, "version must be a version ID such as 1700000000000-0")
+		return
+	}
+	storedKey := namespacedKey(r, key)
+	historyKey, ok := versionsKey(storedKey)
+	if !ok {
+		writeError(w, http.StatusNotFound, "version not found")
+		return
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	version, err := restoreVersionScript.Exec(ctx, h.client, []string{storedKey, historyKey}, []string{id, strconv.Itoa(h.keyVersions)}).ToString()
+	if valkey.IsValkeyNil(err) {
+		writeError(w, http.StatusNotFound, "version not found")
+		return
+	}
+	h.invalidate(storedKey)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "restored", "key": key, "restored": id, "version": version})
+}
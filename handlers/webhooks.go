@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	webhookStoreKey     = "valkey-rest:webhooks"
+	webhookRefresh      = 30 * time.Second
+	webhookQueueSize    = 1000
+	webhookWorkers      = 4
+	webhookMaxAttempts  = 4
+	webhookRetryBackoff = time.Second
+)
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events,omitempty"`       // Event names such as "expired" or "set"; empty for all
+	KeyPatterns []string `json:"key_patterns,omitempty"` // Glob patterns for keys; empty for all
+	Secret      string   `json:"secret,omitempty"`       // HMAC key, generated when empty
+}
+
+// Webhook is a registered endpoint for keyspace events. The secret is only
+// returned when the webhook is created.
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events,omitempty"`
+	KeyPatterns []string  `json:"key_patterns,omitempty"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// matches reports whether the webhook wants an event for a key.
+func (wh *Webhook) matches(event, key string) bool {
+	if len(wh.Events) > 0 {
+		found := false
+		for _, e := range wh.Events {
+			if e == event {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(wh.KeyPatterns) == 0 {
+		return true
+	}
+	for _, pattern := range wh.KeyPatterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is the JSON body delivered to webhooks.
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	Key       string    `json:"key"`
+	DB        int       `json:"db"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type webhookDelivery struct {
+	webhook *Webhook
+	body    []byte
+	id      string
+}
+
+// WebhookStore keeps webhooks in a Valkey hash, so every instance sees the
+// same registrations, with an in-memory copy for matching events. The hash
+// holds the signing secrets, so it lives under InternalKeyPrefix where only
+// the admin raw command endpoints can reach it.
+type WebhookStore struct {
+	client valkey.Client
+
+	mu    sync.RWMutex
+	hooks []*Webhook
+}
+
+func NewWebhookStore(client valkey.Client) *WebhookStore {
+	return &WebhookStore{client: client}
+}
+
+func (ws *WebhookStore) Create(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	if req.Secret == "" {
+		if req.Secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+
+	wh := &Webhook{
+		ID:          id,
+		URL:         req.URL,
+		Events:      req.Events,
+		KeyPatterns: req.KeyPatterns,
+		Secret:      req.Secret,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	data, err := json.Marshal(wh)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ws.client.Do(ctx, ws.client.B().Hset().Key(webhookStoreKey).FieldValue().FieldValue(id, string(data)).Build()).Error(); err != nil {
+		return nil, err
+	}
+	return wh, ws.Refresh(ctx)
+}
+
+// List returns every registered webhook, including secrets.
+func (ws *WebhookStore) List(ctx context.Context) ([]*Webhook, error) {
+	entries, err := ws.client.Do(ctx, ws.client.B().Hgetall().Key(webhookStoreKey).Build()).AsStrMap()
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := make([]*Webhook, 0, len(entries))
+	for id, data := range entries {
+		var wh Webhook
+		if err := json.Unmarshal([]byte(data), &wh); err != nil {
+			log.Printf("Skipping invalid webhook %s: %v", id, err)
+			continue
+		}
+		hooks = append(hooks, &wh)
+	}
+	return hooks, nil
+}
+
+// Delete removes a webhook, returning false if it didn't exist.
+func (ws *WebhookStore) Delete(ctx context.Context, id string) (bool, error) {
+	n, err := ws.client.Do(ctx, ws.client.B().Hdel().Key(webhookStoreKey).Field(id).Build()).AsInt64()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, ws.Refresh(ctx)
+}
+
+// Refresh reloads the in-memory copy used for matching events.
+func (ws *WebhookStore) Refresh(ctx context.Context) error {
+	hooks, err := ws.List(ctx)
+	if err != nil {
+		return err
+	}
+	ws.mu.Lock()
+	ws.hooks = hooks
+	ws.mu.Unlock()
+	return nil
+}
+
+// matching returns the webhooks that want an event for a key.
+func (ws *WebhookStore) matching(event, key string) []*Webhook {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	var hooks []*Webhook
+	for _, wh := range ws.hooks {
+		if wh.matches(event, key) {
+			hooks = append(hooks, wh)
+		}
+	}
+	return hooks
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "timestamp.body".
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseKeyevent splits a keyevent channel such as "__keyevent@0__:expired"
+// into its database and event name.
+func parseKeyevent(channel string) (db int, event string, ok bool) {
+	rest, found := strings.CutPrefix(channel, "__keyevent@")
+	if !found {
+		return 0, "", false
+	}
+	dbStr, event, found := strings.Cut(rest, "__:")
+	if !found {
+		return 0, "", false
+	}
+	db, err := strconv.Atoi(dbStr)
+	return db, event, err == nil
+}
+
+// RefreshWebhooks reloads webhook registrations from Valkey now instead of
+// waiting for the next periodic refresh.
+func (h *Handlers) RefreshWebhooks(ctx context.Context) error {
+	return h.webhooks.Refresh(ctx)
+}
+
+// RunWebhooks subscribes to keyevent notifications and delivers matching
+// events until ctx is cancelled. Notifications are emitted per node, so in
+// cluster mode every node is subscribed to.
+func (h *Handlers) RunWebhooks(ctx context.Context) {
+	if err := h.webhooks.Refresh(ctx); err != nil {
+		log.Printf("Failed to load webhooks: %v", err)
+	}
+
+	queue := make(chan webhookDelivery, webhookQueueSize)
+	client := &http.Client{Timeout: 10 * time.Second}
+	for i := 0; i < webhookWorkers; i++ {
+		go func() {
+			for d := range queue {
+				deliverWebhook(ctx, client, d)
+			}
+		}()
+	}
+
+	// RunWebhooks returns once the refresh loop and the subscriptions have
+	// stopped using the client
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(webhookRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := h.webhooks.Refresh(ctx); err != nil {
+					log.Printf("Failed to refresh webhooks: %v", err)
+				}
+			}
+		}
+	}()
+
+	onMessage := func(m valkey.PubSubMessage) {
+		db, event, ok := parseKeyevent(m.Channel)
+		// The server's own keys change on most requests, and their names
+		// carry token IDs and API key hashes
+		if !ok || ReservedKey(m.Message) {
+			return
+		}
+		for _, wh := range h.webhooks.matching(event, m.Message) {
+			id, _ := randomHex(8)
+			body, _ := json.Marshal(WebhookEvent{ID: id, Event: event, Key: m.Message, DB: db, Timestamp: time.Now().UTC()})
+			select {
+			case queue <- webhookDelivery{webhook: wh, body: body, id: id}:
+			default:
+				log.Printf("Webhook queue full, dropping %s event for %s", event, wh.URL)
+			}
+		}
+	}
+
+	subscribe := func(name string, c valkey.Client) {
+		defer wg.Done()
+		for {
+			err := c.Receive(ctx, c.B().Psubscribe().Pattern("__keyevent@*__:*").Build(), onMessage)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Keyspace notification subscription to %s ended, retrying: %v", name, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+
+	if h.client.Mode() == valkey.ClientModeCluster {
+		for addr, node := range h.client.Nodes() {
+			wg.Add(1)
+			go subscribe(addr, node)
+		}
+	} else {
+		wg.Add(1)
+		go subscribe("valkey", h.client)
+	}
+	wg.Wait()
+	close(queue)
+}
+
+// deliverWebhook POSTs an event, retrying with exponential backoff on network
+// errors, 429 and 5xx responses.
+func deliverWebhook(ctx context.Context, client *http.Client, d webhookDelivery) {
+	backoff := webhookRetryBackoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.webhook.URL, bytes.NewReader(d.body))
+		if err != nil {
+			log.Printf("Invalid webhook URL %s: %v", d.webhook.URL, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "valkey-rest-webhooks")
+		req.Header.Set("X-Webhook-Id", d.id)
+		req.Header.Set("X-Webhook-Timestamp", timestamp)
+		req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(d.webhook.Secret, timestamp, d.body))
+
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+			if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+				log.Printf("Webhook %s rejected event %s with %s", d.webhook.URL, d.id, resp.Status)
+				return
+			}
+			err = fmt.Errorf("unexpected status %s", resp.Status)
+		}
+
+		if attempt == webhookMaxAttempts {
+			log.Printf("Giving up on webhook %s for event %s after %d attempts: %v", d.webhook.URL, d.id, attempt, err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (h *Handlers) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "url must be an absolute http or https URL")
+		return
+	}
+	for _, pattern := range req.KeyPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid key pattern")
+			return
+		}
+	}
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	wh, err := h.webhooks.Create(ctx, req)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wh)
+}
+
+func (h *Handlers) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	hooks, err := h.webhooks.List(ctx)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	for _, wh := range hooks {
+		wh.Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhooks": hooks,
+		"count":    len(hooks),
+	})
+}
+
+func (h *Handlers) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	ctx, cancel := commandContext(r)
+	defer cancel()
+
+	deleted, err := h.webhooks.Delete(ctx, id)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "id": id})
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+)
+
+// WSRequest is a single command frame sent by a WebSocket client.
+type WSRequest struct {
+	ID  string   `json:"id,omitempty"`
+	Cmd []string `json:"cmd"`
+}
+
+// WSResponse is sent back for every WSRequest, and for every Pub/Sub message
+// received on channels the connection has subscribed to.
+type WSResponse struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Channel string      `json:"channel,omitempty"`
+	Pattern string      `json:"pattern,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// wsSubscribeCommands run on a dedicated connection per WebSocket, so they
+// are the connection state commands the gateway accepts.
+var wsSubscribeCommands = map[string]bool{
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "UNSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+}
+
+const (
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = (wsPongWait * 9) / 10
+	wsWriteWait  = 10 * time.Second
+	wsMaxMessage = 1 << 20
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// wsConn serialises writes to a WebSocket connection, which only supports
+// one concurrent writer.
+type wsConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsConn) send(resp WSResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(resp)
+}
+
+func (c *wsConn) ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+}
+
+// HandleWebSocket upgrades the connection and executes command frames such as
+// {"id":"1","cmd":["GET","foo"]}. SUBSCRIBE and PSUBSCRIBE are served from a
+// dedicated Valkey connection and their messages are pushed on the same socket.
+func (h *Handlers) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Arbitrary commands can't be rewritten reliably, so they can't be confined
+	// to a namespace
+	if RequestNamespace(r) != "" {
+		writeError(w, http.StatusBadRequest, "namespaces are not supported on the WebSocket gateway")
+		return
+	}
+
+	if p := auth.FromContext(r.Context()); p != nil && len(p.KeyPatterns) > 0 {
+		writeError(w, http.StatusForbidden, "tokens restricted to key patterns cannot use the WebSocket gateway")
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response
+		return
+	}
+	defer conn.Close()
+
+	ws := &wsConn{conn: conn}
+	// Commands outlive the upgrade request but keep its command timeout
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer cancel()
+	// The connection is hijacked, so the request's context only ends when
+	// the server gives up waiting for it to close during shutdown
+	stop := context.AfterFunc(r.Context(), func() {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(time.Second))
+		conn.Close()
+	})
+	defer stop()
+
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	go func() {
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ws.ping(); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// Commands run against the database selected with ?db=
+	client := h.clientFor(ctx)
+
+	var subscriber valkey.DedicatedClient
+	var release func()
+	defer func() {
+		if subscriber != nil {
+			// A subscribed connection can't go back into the pool, so close
+			// it outright; release still frees its slot
+			subscriber.Close()
+			release()
+		}
+	}()
+
+	for {
+		var req WSRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket read error: %v", err)
+			}
+			return
+		}
+
+		if len(req.Cmd) == 0 || req.Cmd[0] == "" {
+			ws.send(WSResponse{ID: req.ID, Type: "error", Error: "cmd is required"})
+			continue
+		}
+
+		// The same policy as /command, other than the subscriptions
+		if !h.commands.allowed(req.Cmd, wsSubscribeCommands) {
+			ws.send(WSResponse{ID: req.ID, Type: "error", Error: "command not allowed: " + strings.ToUpper(req.Cmd[0])})
+			continue
+		}
+
+		if wsSubscribeCommands[strings.ToUpper(req.Cmd[0])] {
+			if subscriber == nil {
+				subscriber, release = newWSSubscriber(client, ws)
+			}
+			// Channels aren't keys, so subscriptions aren't routed by slot
+			execWSCommand(ctx, ws, subscriber, subscriber.B().Arbitrary(req.Cmd...).Build(), req.ID)
+		} else {
+			execWSCommand(ctx, ws, client, arbitraryCommand(client, req.Cmd), req.ID)
+		}
+	}
+}
+
+// newWSSubscriber borrows a dedicated connection whose Pub/Sub messages are
+// forwarded to the WebSocket client. The caller must close it and then call
+// the release func.
+func newWSSubscriber(client valkey.Client, ws *wsConn) (valkey.DedicatedClient, func()) {
+	subscriber, release := client.Dedicate()
+	subscriber.SetPubSubHooks(valkey.PubSubHooks{
+		OnMessage: func(m valkey.PubSubMessage) {
+			ws.send(WSResponse{
+				Type:    "message",
+				Channel: m.Channel,
+				Pattern: m.Pattern,
+				Message: m.Message,
+			})
+		},
+	})
+	return subscriber, release
+}
+
+func execWSCommand(ctx context.Context, ws *wsConn, client valkey.CoreClient, cmd valkey.Completed, id string) {
+	cmdCtx, cancel := context.WithTimeout(ctx, CommandTimeout(ctx))
+	defer cancel()
+
+	result, err := client.Do(cmdCtx, cmd).ToAny()
+	if err != nil && !valkey.IsValkeyNil(err) {
+		msg := "internal server error"
+		if _, ok := valkey.IsValkeyErr(err); ok {
+			msg = err.Error()
+		}
+		ws.send(WSResponse{ID: id, Type: "error", Error: msg})
+		return
+	}
+
+	ws.send(WSResponse{ID: id, Type: "result", Result: result})
+}
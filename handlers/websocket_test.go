@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketCommandPolicy(t *testing.T) {
+	// No client: a command that got past the policy would panic
+	h := &Handlers{commands: NewCommandPolicy("", "FLUSHALL,CONFIG SET,PSUBSCRIBE")}
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWebSocket))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for _, cmd := range [][]string{
+		{"FLUSHALL"},                        // Denied
+		{"config", "set", "maxmemory", "1"}, // Denied subcommand
+		{"SELECT", "1"},                     // Would switch the pooled connection's database
+		{"MULTI"},
+		{"SSUBSCRIBE", "news"}, // Only SUBSCRIBE and PSUBSCRIBE get a dedicated connection
+		{"PSUBSCRIBE", "news.*"},
+	} {
+		if err := conn.WriteJSON(WSRequest{ID: cmd[0], Cmd: cmd}); err != nil {
+			t.Fatal(err)
+		}
+		var resp WSResponse
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.ID != cmd[0] || resp.Type != "error" || !strings.HasPrefix(resp.Error, "command not allowed") {
+			t.Errorf("%q: got %+v, want command not allowed", cmd, resp)
+		}
+	}
+}
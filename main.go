@@ -2,344 +2,199 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"crypto/tls"
+	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/valkey-io/valkey-go"
-)
-
-type Server struct {
-	client    valkey.Client
-	router    *http.ServeMux
-	authToken string
-}
-
-type Config struct {
-	Port           string
-	ValkeyAddress  string
-	ValkeyPassword string
-	AuthToken      string
-	ReadTimeout    time.Duration
-	WriteTimeout   time.Duration
-	IdleTimeout    time.Duration
-}
-
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
-type SetRequest struct {
-	Value      string `json:"value"`
-	Expiration int64  `json:"expiration,omitempty"` // Expiration in seconds
-}
-
-type GetResponse struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
-}
-
-func NewServer(client valkey.Client, authToken string) *Server {
-	s := &Server{
-		client:    client,
-		router:    http.NewServeMux(),
-		authToken: authToken,
-	}
-	s.setupRoutes()
-	return s
-}
-
-// authMiddleware validates the Authorization token
-func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// If no auth token is configured, allow all requests
-		if s.authToken == "" {
-			next(w, r)
-			return
-		}
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
-		// Check Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(ErrorResponse{Error: "authorization token required"})
-			return
-		}
-
-		// Support both "Bearer <token>" and direct token
-		token := authHeader
-		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			token = authHeader[7:]
-		}
-
-		if token != s.authToken {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(ErrorResponse{Error: "invalid authorization token"})
-			return
-		}
-
-		next(w, r)
-	}
-}
-
-func (s *Server) setupRoutes() {
-	// Health check is public (no auth required)
-	s.router.HandleFunc("GET /health", s.handleHealth)
-	
-	// Protected endpoints require authentication
-	s.router.HandleFunc("GET /keys/{key}", s.authMiddleware(s.handleGet))
-	s.router.HandleFunc("POST /keys/{key}", s.authMiddleware(s.handleSet))
-	s.router.HandleFunc("DELETE /keys/{key}", s.authMiddleware(s.handleDelete))
-	s.router.HandleFunc("GET /keys", s.authMiddleware(s.handleList))
-}
-
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
-
-	// Test Valkey connection
-	_, err := s.client.Do(ctx, s.client.B().Ping().Build()).ToString()
-	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Valkey connection failed"})
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
-}
-
-func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
-	key := r.PathValue("key")
-	if key == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "key is required"})
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	result, err := s.client.Do(ctx, s.client.B().Get().Key(key).Build()).ToString()
-	if err != nil {
-		if err == valkey.Nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(ErrorResponse{Error: "key not found"})
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "internal server error"})
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(GetResponse{Key: key, Value: result})
-}
-
-func (s *Server) handleSet(w http.ResponseWriter, r *http.Request) {
-	key := r.PathValue("key")
-	if key == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "key is required"})
-		return
-	}
-
-	var req SetRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "invalid request body"})
-		return
-	}
+	"valkey-rest/bench"
+	"valkey-rest/config"
+	"valkey-rest/server"
+)
 
-	if req.Value == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "value is required"})
+func main() {
+	configPath := flag.String("config", "", "path to a YAML or TOML config file; environment variables override it")
+	runBench := flag.Bool("bench", false, "drive GET/SET load against -bench-target and report latencies instead of serving")
+	benchOpts := bench.Options{}
+	flag.StringVar(&benchOpts.Target, "bench-target", "http://localhost:8080", "base URL of the server to benchmark")
+	flag.StringVar(&benchOpts.Token, "bench-token", os.Getenv("AUTH_TOKEN"), "token with the write role (default $AUTH_TOKEN)")
+	flag.DurationVar(&benchOpts.Duration, "bench-duration", 30*time.Second, "how long to send load")
+	flag.IntVar(&benchOpts.Concurrency, "bench-concurrency", 32, "requests in flight at once")
+	flag.IntVar(&benchOpts.Keys, "bench-keys", 10000, "distinct keys the load is spread over")
+	flag.IntVar(&benchOpts.ValueSize, "bench-value-size", 256, "bytes in each value written")
+	flag.Float64Var(&benchOpts.ReadRatio, "bench-read-ratio", 0.8, "share of requests that are GETs, 0 to 1")
+	flag.StringVar(&benchOpts.KeyPrefix, "bench-prefix", "bench:", "prefix of the keys written")
+	flag.BoolVar(&benchOpts.Cleanup, "bench-cleanup", true, "delete the keys afterwards")
+	flag.Parse()
+
+	if *runBench {
+		benchmark(benchOpts)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	builder := s.client.B().Set().Key(key).Value(req.Value)
-	if req.Expiration > 0 {
-		// Expiration is in seconds
-		builder.Ex(time.Duration(req.Expiration) * time.Second)
-	}
-
-	err := s.client.Do(ctx, builder.Build()).Error()
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "internal server error"})
-		return
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
+	server.SetupLogging(cfg.LogLevel, cfg.LogFormat)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "created", "key": key})
-}
-
-func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
-	key := r.PathValue("key")
-	if key == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "key is required"})
-		return
+	if cfg.OTLPEndpoint != "" {
+		shutdownTracing, err := server.SetupTracing(context.Background(), cfg.ServiceName, cfg.OTLPEndpoint)
+		if err != nil {
+			log.Fatalf("Failed to set up tracing: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				log.Printf("Failed to flush traces: %v", err)
+			}
+		}()
+		log.Printf("OpenTelemetry tracing enabled, exporting to %s", cfg.OTLPEndpoint)
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	result, err := s.client.Do(ctx, s.client.B().Del().Key(key).Build()).AsInt64()
+	srv, err := server.New(*cfg)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "internal server error"})
-		return
-	}
-
-	if result == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "key not found"})
-		return
+		log.Fatalf("Failed to start server: %v", err)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "key": key})
-}
-
-func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
-
-	pattern := r.URL.Query().Get("pattern")
-	if pattern == "" {
-		pattern = "*"
+	defer srv.Close()
+
+	// Webhooks and the scheduler are stopped on shutdown and waited for
+	// before srv.Close closes the Valkey client under them
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var background sync.WaitGroup
+
+	if cfg.WebhooksEnabled {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			srv.RunWebhooks(backgroundCtx)
+		}()
+		log.Println("Keyspace notification webhooks enabled")
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 100 // default limit
-	if limitStr != "" {
-		fmt.Sscanf(limitStr, "%d", &limit)
-		if limit > 1000 || limit < 1 {
-			limit = 100
-		}
+	if cfg.SchedulerEnabled {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			srv.RunScheduler(backgroundCtx)
+		}()
+		log.Printf("Running scheduled key jobs from %s every %s", cfg.ScheduleKey, cfg.SchedulerInterval)
 	}
 
-	cursor := uint64(0)
-	keys := []string{}
-
-	for {
-		result, err := s.client.Do(ctx, s.client.B().Scan().Cursor(cursor).Match(pattern).Count(int64(limit)).Build()).AsScanEntry()
+	useTLS := cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+	var tlsConfig *tls.Config
+	if useTLS {
+		tlsConfig, err = server.TLSConfig(cfg)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(ErrorResponse{Error: "internal server error"})
-			return
+			log.Fatalf("Failed to configure TLS: %v", err)
 		}
-
-		keys = append(keys, result.Elements...)
-		cursor = result.Cursor
-
-		if cursor == 0 || len(keys) >= limit {
-			break
+		if tlsConfig.ClientCAs != nil {
+			log.Println("Mutual TLS enabled - client certificates are required")
 		}
 	}
 
-	if len(keys) > limit {
-		keys = keys[:limit]
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"keys":  keys,
-		"count": len(keys),
-	})
-}
-
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	s.router.ServeHTTP(w, r)
-}
-
-func loadConfig() *Config {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// LISTEN replaces PORT, and admin listeners serve the admin routes that
+	// the others then refuse
+	addrs := config.SplitList(cfg.Listen)
+	if len(addrs) == 0 {
+		addrs = []string{":" + cfg.Port}
 	}
-
-	valkeyAddress := os.Getenv("VALKEY_ADDRESS")
-	if valkeyAddress == "" {
-		valkeyAddress = "localhost:6379"
-	}
-
-	valkeyPassword := os.Getenv("VALKEY_PASSWORD")
-	authToken := os.Getenv("AUTH_TOKEN")
-
-	return &Config{
-		Port:           port,
-		ValkeyAddress:  valkeyAddress,
-		ValkeyPassword: valkeyPassword,
-		AuthToken:      authToken,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		IdleTimeout:    120 * time.Second,
-	}
-}
-
-func main() {
-	config := loadConfig()
-
-	// Initialize Valkey client
-	clientOption := valkey.ClientOption{
-		InitAddress: []string{config.ValkeyAddress},
-	}
-	
-	// Add password if provided
-	if config.ValkeyPassword != "" {
-		clientOption.Password = config.ValkeyPassword
-	}
-
-	client, err := valkey.NewClient(clientOption)
-	if err != nil {
-		log.Fatalf("Failed to create Valkey client: %v", err)
+	var httpServers []*http.Server
+	serve := func(addr string, handler http.Handler, kind string) {
+		lis, err := server.Listen(addr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", addr, err)
+		}
+		httpServer := &http.Server{
+			Handler:      handler,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		}
+		// Unix sockets are only reachable locally, so they skip TLS
+		if useTLS && !server.IsUnixAddress(addr) {
+			httpServer.TLSConfig = tlsConfig
+		}
+		httpServers = append(httpServers, httpServer)
+
+		go func() {
+			var err error
+			if httpServer.TLSConfig != nil {
+				log.Printf("%s server starting on %s (HTTPS)", kind, addr)
+				// Certificates are already loaded into TLSConfig
+				err = httpServer.ServeTLS(lis, "", "")
+			} else {
+				log.Printf("%s server starting on %s", kind, addr)
+				err = httpServer.Serve(lis)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Server failed: %v", err)
+			}
+		}()
 	}
-	defer client.Close()
-
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if _, err := client.Do(ctx, client.B().Ping().Build()).ToString(); err != nil {
-		log.Fatalf("Failed to connect to Valkey: %v", err)
+	for _, addr := range addrs {
+		serve(addr, srv.Handler(), "API")
 	}
-
-	log.Printf("Connected to Valkey at %s", config.ValkeyAddress)
-	if config.ValkeyPassword != "" {
-		log.Println("Valkey password authentication enabled")
+	for _, addr := range config.SplitList(cfg.AdminListen) {
+		serve(addr, srv.AdminHandler(), "Admin API")
 	}
-	if config.AuthToken != "" {
-		log.Println("Token authentication enabled")
-	} else {
-		log.Println("Warning: No AUTH_TOKEN configured - API is unsecured")
+	// The debug listener has no authentication and serves plain HTTP, with
+	// no write timeout so CPU profiles and traces can run for longer
+	for _, addr := range config.SplitList(cfg.DebugListen) {
+		lis, err := server.Listen(addr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", addr, err)
+		}
+		debugServer := &http.Server{Handler: server.DebugHandler(), ReadTimeout: cfg.ReadTimeout}
+		httpServers = append(httpServers, debugServer)
+		go func() {
+			log.Printf("Debug server starting on %s - keep it off public networks", addr)
+			if err := debugServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Debug server failed: %v", err)
+			}
+		}()
 	}
 
-	// Create server
-	server := NewServer(client, config.AuthToken)
-
-	httpServer := &http.Server{
-		Addr:         ":" + config.Port,
-		Handler:      server,
-		ReadTimeout:  config.ReadTimeout,
-		WriteTimeout: config.WriteTimeout,
-		IdleTimeout:  config.IdleTimeout,
+	// The gRPC API listens on its own port and is off unless GRPC_PORT is set
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		var opts []grpc.ServerOption
+		if useTLS {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		grpcServer = server.NewGRPCServer(srv, opts...)
+		lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		go func() {
+			log.Printf("gRPC server starting on port %s", cfg.GRPCPort)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
 	}
 
-	// Graceful shutdown
+	// SIGHUP reloads tokens, rate limits, the log level and webhooks
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 	go func() {
-		log.Printf("Server starting on port %s", config.Port)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed: %v", err)
+		for range reload {
+			log.Println("Reloading configuration...")
+			if err := srv.ReloadConfig(); err != nil {
+				log.Printf("Failed to reload configuration, keeping the current one:\n%v", err)
+			}
 		}
 	}()
 
@@ -348,14 +203,82 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Fail /readyz first and give load balancers time to notice before the
+	// listeners close
 	log.Println("Shutting down server...")
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	srv.StartDraining()
+	time.Sleep(cfg.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
-	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	var stopped sync.WaitGroup
+	// Background jobs finish the work in hand while requests drain
+	stopBackground()
+	stopped.Add(1)
+	go func() {
+		defer stopped.Done()
+		done := make(chan struct{})
+		go func() {
+			background.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			log.Printf("Shutdown timeout passed, closing Valkey under the webhooks and scheduler")
+		}
+	}()
+	if grpcServer != nil {
+		stopped.Add(1)
+		go func() {
+			defer stopped.Done()
+			// Subscribe streams never finish on their own, so don't wait past
+			// the shutdown deadline for them
+			done := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				grpcServer.Stop()
+			}
+		}()
 	}
+	// Shutdown stops accepting connections and closes idle ones, but doesn't
+	// wait for hijacked WebSocket connections, which Drain does
+	for _, httpServer := range httpServers {
+		stopped.Add(1)
+		go func() {
+			defer stopped.Done()
+			if err := httpServer.Shutdown(ctx); err != nil {
+				httpServer.Close()
+			}
+		}()
+	}
+	if inFlight := srv.InFlight(); inFlight > 0 {
+		log.Printf("Waiting up to %s for %d requests in flight", cfg.ShutdownTimeout, inFlight)
+	}
+	if err := srv.Drain(ctx); err != nil {
+		log.Printf("Shutdown timeout passed, cancelled the requests still in flight")
+	}
+	stopped.Wait()
 
 	log.Println("Server exited")
 }
 
+// benchmark runs the load test and prints its report, stopping early on
+// SIGINT or SIGTERM.
+func benchmark(opts bench.Options) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Benchmarking %s for %s: %d workers, %d keys of %d bytes, %.0f%% reads", opts.Target, opts.Duration, opts.Concurrency, opts.Keys, opts.ValueSize, opts.ReadRatio*100)
+	result, err := bench.Run(ctx, opts)
+	if err != nil {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+	result.Report(os.Stdout)
+}
@@ -0,0 +1,363 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+const (
+	// auditBuffer is how many recent entries the stdout and file sinks keep
+	// in memory for GET /admin/audit.
+	auditBuffer       = 1000
+	defaultAuditCount = 100
+	maxAuditCount     = 1000
+	// auditScanLimit bounds how many stream entries a filtered query reads.
+	auditScanLimit = 10000
+	auditScanBatch = 500
+)
+
+// AuditEntry records one mutating or admin call. Entries written by an
+// instance form a chain: Hash covers the entry including PrevHash, the hash
+// of the instance's previous entry, so edited or removed entries show up.
+type AuditEntry struct {
+	ID        string    `json:"id,omitempty"` // Stream entry ID, stream sink only
+	Time      time.Time `json:"time"`
+	Instance  string    `json:"instance"`
+	Seq       uint64    `json:"seq"`
+	Subject   string    `json:"subject"`
+	Namespace string    `json:"namespace,omitempty"`
+	RemoteIP  string    `json:"remote_ip,omitempty"`
+	Method    string    `json:"method"`         // HTTP method, or GRPC
+	Route     string    `json:"route"`          // Route pattern or full gRPC method
+	Path      string    `json:"path,omitempty"` // Request URI, HTTP only
+	Key       string    `json:"key,omitempty"`
+	Status    int       `json:"status,omitempty"` // HTTP status
+	Code      string    `json:"code,omitempty"`   // gRPC status code
+	Outcome   string    `json:"outcome"`          // success, denied or failure
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// AuditResponse is returned by GET /admin/audit, newest entry first.
+// Verified is false when an entry's hash doesn't match its contents or the
+// entry before it in the same instance's chain.
+type AuditResponse struct {
+	Entries  []AuditEntry `json:"entries"`
+	Verified bool         `json:"verified"`
+	Problems []string     `json:"problems,omitempty"`
+}
+
+// Auditor writes audit entries to the configured sink. A nil Auditor records
+// nothing.
+type Auditor struct {
+	client   valkey.Client // Set for the stream sink
+	stream   string
+	maxLen   int64
+	out      io.Writer // Set for the stdout and file sinks
+	file     *os.File
+	secret   []byte
+	instance string
+
+	mu     sync.Mutex // Orders the chain and guards recent
+	seq    uint64
+	prev   string
+	recent []AuditEntry // Ring of the last auditBuffer entries
+	next   int
+}
+
+// newAuditor opens the sink selected by sink: "stdout", "file" (appending
+// to path) or "stream" (the Valkey stream key, capped at about maxLen
+// entries). It returns nil when sink is empty.
+func newAuditor(client valkey.Client, sink, path, stream string, maxLen int64, secret string) (*Auditor, error) {
+	if sink == "" {
+		return nil, nil
+	}
+
+	a := &Auditor{secret: []byte(secret), instance: auditInstance()}
+	switch sink {
+	case "stdout":
+		a.out = os.Stdout
+	case "file":
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open audit log: %w", err)
+		}
+		a.out, a.file = f, f
+	case "stream":
+		if client == nil {
+			return nil, fmt.Errorf("AUDIT_LOG=stream requires the valkey backend")
+		}
+		a.client, a.stream, a.maxLen = client, stream, maxLen
+	default:
+		return nil, fmt.Errorf("unknown AUDIT_LOG %q, expected stdout, file or stream", sink)
+	}
+	return a, nil
+}
+
+// auditInstance names this process in the chain, so entries from several
+// instances sharing a stream can be told apart.
+func auditInstance() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// Close closes the audit file, if any.
+func (a *Auditor) Close() {
+	if a != nil && a.file != nil {
+		a.file.Close()
+	}
+}
+
+// sum hashes an entry, keyed with the audit secret when one is set.
+func (a *Auditor) sum(e AuditEntry) string {
+	e.ID, e.Hash = "", ""
+	data, _ := json.Marshal(e)
+
+	var h hash.Hash
+	if len(a.secret) > 0 {
+		h = hmac.New(sha256.New, a.secret)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Record chains e onto the previous entry and writes it. Failures are logged
+// rather than returned, since the call has already been answered.
+//
+// Only the chaining holds the lock; a slow sink doesn't hold up other
+// requests, though concurrent entries may then be written slightly out of
+// order. Verify follows Seq rather than the order entries are read back in.
+func (a *Auditor) Record(e AuditEntry) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.seq++
+	e.Time = e.Time.UTC()
+	e.Instance, e.Seq, e.PrevHash = a.instance, a.seq, a.prev
+	e.Hash = a.sum(e)
+	a.prev = e.Hash
+	a.mu.Unlock()
+
+	if err := a.write(&e); err != nil {
+		auditWriteErrorsTotal.Inc()
+		log.Printf("Failed to write audit entry %d: %v", e.Seq, err)
+		return
+	}
+
+	if a.client == nil {
+		a.mu.Lock()
+		if len(a.recent) < auditBuffer {
+			a.recent = append(a.recent, e)
+		} else {
+			a.recent[a.next] = e
+		}
+		a.next = (a.next + 1) % auditBuffer
+		a.mu.Unlock()
+	}
+}
+
+func (a *Auditor) write(e *AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if a.client == nil {
+		_, err := a.out.Write(append(data, '\n'))
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cmd := a.client.B().Xadd().Key(a.stream)
+	if a.maxLen > 0 {
+		return a.client.Do(ctx, cmd.Maxlen().Almost().Threshold(strconv.FormatInt(a.maxLen, 10)).Id("*").FieldValue().FieldValue("entry", string(data)).Build()).Error()
+	}
+	return a.client.Do(ctx, cmd.Id("*").FieldValue().FieldValue("entry", string(data)).Build()).Error()
+}
+
+// Query returns up to count of the most recent entries matching filter,
+// newest first. The stream sink covers every instance writing to it; the
+// other sinks only the last auditBuffer entries of this instance.
+func (a *Auditor) Query(ctx context.Context, count int, filter func(*AuditEntry) bool) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	if a.client == nil {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for i := 1; i <= len(a.recent) && len(entries) < count; i++ {
+			e := a.recent[(a.next-i+len(a.recent))%len(a.recent)]
+			if filter(&e) {
+				entries = append(entries, e)
+			}
+		}
+		return entries, nil
+	}
+
+	end := "+"
+	for scanned := 0; scanned < auditScanLimit && len(entries) < count; {
+		page, err := a.client.Do(ctx, a.client.B().Xrevrange().Key(a.stream).End(end).Start("-").Count(auditScanBatch).Build()).AsXRange()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page {
+			var e AuditEntry
+			if err := json.Unmarshal([]byte(item.FieldValues["entry"]), &e); err != nil {
+				continue
+			}
+			e.ID = item.ID
+			if filter(&e) {
+				entries = append(entries, e)
+				if len(entries) == count {
+					break
+				}
+			}
+		}
+		if len(page) < auditScanBatch {
+			break
+		}
+		scanned += len(page)
+		end = "(" + page[len(page)-1].ID
+	}
+	return entries, nil
+}
+
+// Verify checks each entry's hash, and that it links to the entry before it
+// in its instance's chain when that entry is among entries too.
+func (a *Auditor) Verify(entries []AuditEntry) []string {
+	type link struct {
+		instance string
+		seq      uint64
+	}
+	hashes := make(map[link]string, len(entries))
+	for _, e := range entries {
+		hashes[link{e.Instance, e.Seq}] = e.Hash
+	}
+
+	var problems []string
+	for _, e := range entries {
+		if a.sum(e) != e.Hash {
+			problems = append(problems, fmt.Sprintf("entry %d of %s does not match its hash", e.Seq, e.Instance))
+			continue
+		}
+		if prev, ok := hashes[link{e.Instance, e.Seq - 1}]; ok && prev != e.PrevHash {
+			problems = append(problems, fmt.Sprintf("entry %d of %s does not follow entry %d", e.Seq, e.Instance, e.Seq-1))
+		}
+	}
+	return problems
+}
+
+// auditOutcome classifies a response status for an audit entry.
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "denied"
+	case status >= http.StatusBadRequest:
+		return "failure"
+	}
+	return "success"
+}
+
+// auditedRoute reports whether calls to a route are audited: everything but
+// reads, and every admin route.
+func auditedRoute(pattern string, role auth.Role) bool {
+	if role == auth.RoleAdmin {
+		return true
+	}
+	method, _, _ := strings.Cut(pattern, " ")
+	return method != http.MethodGet && method != http.MethodHead
+}
+
+// auditMiddleware records a call once it has been answered. It wraps
+// authentication, so rejected calls are recorded too.
+func (s *Server) auditMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newStatusRecorder(w)
+		next(rec, r)
+
+		subject := "anonymous"
+		if info := requestInfoFrom(r.Context()); info != nil {
+			subject = info.subject
+		}
+		s.audit.Record(AuditEntry{
+			Time:      start,
+			Subject:   subject,
+			Namespace: handlers.RequestNamespace(r),
+			RemoteIP:  remoteIP(r),
+			Method:    r.Method,
+			Route:     r.Pattern,
+			Path:      r.URL.RequestURI(),
+			Key:       r.PathValue("key"),
+			Status:    rec.status,
+			Outcome:   auditOutcome(rec.status),
+		})
+	}
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	count := defaultAuditCount
+	if v := query.Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditCount {
+			handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, fmt.Sprintf("count must be between 1 and %d", maxAuditCount))
+			return
+		}
+		count = n
+	}
+
+	var since time.Time
+	if v := query.Get("since"); v != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, "since must be an RFC 3339 time")
+			return
+		}
+	}
+
+	subject, key := query.Get("subject"), query.Get("key")
+	filter := func(e *AuditEntry) bool {
+		return (subject == "" || e.Subject == subject) &&
+			(key == "" || e.Key == key) &&
+			!e.Time.Before(since)
+	}
+
+	ctx, cancel := commandContext(r.Context())
+	defer cancel()
+
+	entries, err := s.audit.Query(ctx, count, filter)
+	if err != nil {
+		log.Printf("Failed to read audit log: %v", err)
+		handlers.WriteCommandError(w, err)
+		return
+	}
+
+	problems := s.audit.Verify(entries)
+	json.NewEncoder(w).Encode(AuditResponse{Entries: entries, Verified: len(problems) == 0, Problems: problems})
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockingWriter stalls every write until release is closed.
+type blockingWriter struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.entered <- struct{}{}
+	<-w.release
+	return len(p), nil
+}
+
+func TestAuditRecordDoesNotHoldLockWhileWriting(t *testing.T) {
+	out := &blockingWriter{entered: make(chan struct{}, 2), release: make(chan struct{})}
+	a := &Auditor{out: out, instance: "test"}
+
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			a.Record(AuditEntry{Time: time.Now(), Method: "DELETE"})
+			done <- struct{}{}
+		}()
+	}
+	// Both writes are in progress at once, so neither holds the lock
+	for i := 0; i < 2; i++ {
+		select {
+		case <-out.entered:
+		case <-time.After(time.Second):
+			t.Fatal("a slow write blocked the next entry")
+		}
+	}
+	close(out.release)
+	<-done
+	<-done
+
+	entries, err := a.Query(context.Background(), 10, func(*AuditEntry) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if problems := a.Verify(entries); len(problems) > 0 {
+		t.Errorf("chain broken: %q", problems)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+// errReservedKey is reported for keys under handlers.InternalKeyPrefix.
+var errReservedKey = errors.New("key is reserved for internal use")
+
+// bearerToken extracts the token from the Authorization header, supporting
+// both "Bearer <token>" and the bare token.
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		return authHeader[7:]
+	}
+	return authHeader
+}
+
+// authEnabled reports whether any kind of credential is configured. API keys
+// alone don't count, since creating one already requires an admin token.
+func (s *Server) authEnabled() bool {
+	return s.tokens.Load().Enabled() || s.jwt != nil
+}
+
+// lookupPrincipal resolves a bearer token, trying static tokens first, then
+// JWTs and finally API keys. It returns nil for unknown tokens; errors are
+// only returned when the API key store can't be reached.
+func (s *Server) lookupPrincipal(ctx context.Context, token string) (*auth.Principal, error) {
+	if p := s.tokens.Load().Lookup(token); p != nil {
+		return p, nil
+	}
+	if s.jwt != nil && auth.LooksLikeJWT(token) {
+		if p, err := s.jwt.Verify(ctx, token); err == nil {
+			return p, nil
+		}
+	}
+	if s.apiKeys != nil && strings.HasPrefix(token, auth.APIKeyPrefix) {
+		return s.apiKeys.Lookup(ctx, token)
+	}
+	return nil, nil
+}
+
+// authMiddleware validates the Authorization token, which may be a static
+// token, a JWT when JWKS validation is configured, or an API key managed
+// through the admin endpoints, and checks that its role
+// is at least the one required by the route. Tokens restricted to key
+// patterns may only reach routes whose {key} matches one of them, and none
+// of the routes in keylessRoute.
+func (s *Server) authMiddleware(required auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// If no auth tokens are configured, allow all requests
+		if !s.authEnabled() {
+			next(w, r)
+			return
+		}
+
+		token := bearerToken(r)
+		if token == "" {
+			handlers.WriteError(w, http.StatusUnauthorized, handlers.CodeUnauthorized, "authorization token required")
+			return
+		}
+
+		principal, err := s.lookupPrincipal(r.Context(), token)
+		if err != nil {
+			handlers.WriteCommandError(w, err)
+			return
+		}
+		if principal == nil {
+			handlers.WriteError(w, http.StatusUnauthorized, handlers.CodeUnauthorized, "invalid authorization token")
+			return
+		}
+
+		setRequestSubject(r, principal.Name)
+
+		if !s.allowPrincipal(w, r, principal) {
+			return
+		}
+
+		if principal.Role < required {
+			handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, fmt.Sprintf("%s role required", required))
+			return
+		}
+		if !allowNamespace(w, r, principal) {
+			return
+		}
+
+		if !concurrencyExempt(r.URL.Path) {
+			release, ok := s.concurrency.AcquirePrincipal(r.Context(), principal.ID)
+			if !ok {
+				s.writeSaturated(w)
+				return
+			}
+			defer release()
+		}
+
+		if len(principal.KeyPatterns) > 0 && keylessRoute(r.Pattern) {
+			handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, "tokens restricted to key patterns cannot use this endpoint")
+			return
+		}
+		if key := r.PathValue("key"); key != "" && !principal.CanAccessKey(handlers.PrefixNamespace(handlers.RequestNamespace(r), key)) {
+			handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, "access to key denied")
+			return
+		}
+
+		next(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	}
+}
+
+// keylessRoutes keep client data under the server's own keys, named after
+// a lock, session, leaderboard or bucket rather than a {key}. Key patterns
+// can't name those keys, so they can't confine these routes.
+var keylessRoutes = []string{"/locks/", "/sessions", "/leaderboards/", "/ratelimit/"}
+
+// keylessRoute reports whether pattern is one of keylessRoutes.
+func keylessRoute(pattern string) bool {
+	_, path, _ := strings.Cut(pattern, " ")
+	for _, prefix := range keylessRoutes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reservedKeyMiddleware refuses a route whose {key} is one of the server's
+// own keys. It applies to every role, and with authentication off.
+func reservedKeyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if handlers.ReservedKey(r.PathValue("key")) {
+			handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, errReservedKey.Error())
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"valkey-rest/auth"
+	"valkey-rest/config"
+	"valkey-rest/store"
+)
+
+const (
+	testAdminToken = "admin-secret"
+	testWriteToken = "write-secret"
+	testReadToken  = "read-secret"
+)
+
+// newTestServer returns a server on the in-memory backend with an admin, a
+// write and a read token.
+func newTestServer(t *testing.T, tokens ...auth.TokenConfig) *Server {
+	t.Helper()
+	cfg := *config.Default()
+	cfg.Backend = "memory"
+	cfg.Tokens = append([]auth.TokenConfig{
+		{Name: "admin", Token: testAdminToken, Role: "admin"},
+		{Name: "writer", Token: testWriteToken, Role: "write"},
+		{Name: "reader", Token: testReadToken, Role: "read"},
+	}, tokens...)
+	s, err := NewWithStore(store.NewMemory(), cfg)
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// do sends a request to s and returns the recorded response.
+func do(s *Server, method, path, token, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReservedKeysRejected(t *testing.T) {
+	s := newTestServer(t)
+	record := `{"value":"{\"id\":\"x\",\"role\":\"admin\"}"}`
+
+	for _, tc := range []struct {
+		name, method, path, token string
+	}{
+		{"write API key hash record", http.MethodPost, "/keys/valkey-rest:apikeys:hash:abc", testWriteToken},
+		{"write API key id record", http.MethodPost, "/keys/valkey-rest:apikeys:id:x", testWriteToken},
+		{"admin writes are refused too", http.MethodPost, "/keys/valkey-rest:apikeys:id:x", testAdminToken},
+		{"read", http.MethodGet, "/keys/valkey-rest:webhooks", testReadToken},
+		{"delete audit stream", http.MethodDelete, "/keys/valkey-rest:audit", testWriteToken},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body := ""
+			if tc.method == http.MethodPost {
+				body = record
+			}
+			if rec := do(s, tc.method, tc.path, tc.token, body); rec.Code != http.StatusForbidden {
+				t.Errorf("%s %s = %d, want 403: %s", tc.method, tc.path, rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestReservedNamespaceRejected(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := do(s, http.MethodPost, "/ns/valkey-rest/keys/apikeys:hash:abc", testWriteToken, `{"value":"x"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("write through the valkey-rest namespace = %d, want 400: %s", rec.Code, rec.Body)
+	}
+	rec = do(s, http.MethodGet, "/keys/apikeys:hash:abc", testWriteToken, "", "X-Namespace", "valkey-rest")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("read through the valkey-rest namespace = %d, want 400: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestReservedKeysHiddenFromListing(t *testing.T) {
+	st := store.NewMemory()
+	cfg := *config.Default()
+	cfg.Backend = "memory"
+	cfg.Tokens = []auth.TokenConfig{{Name: "reader", Token: testReadToken, Role: "read"}}
+	s, err := NewWithStore(st, cfg)
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	for _, key := range []string{"user:1", "valkey-rest:apikeys:id:x"} {
+		if err := st.Set(ctx, key, "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := do(s, http.MethodGet, "/keys?pattern=*", testReadToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /keys = %d: %s", rec.Code, rec.Body)
+	}
+	var page struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Keys) != 1 || page.Keys[0] != "user:1" {
+		t.Errorf("keys = %q, want only user:1", page.Keys)
+	}
+}
+
+func TestKeyPatternsIncludeNamespace(t *testing.T) {
+	s := newTestServer(t,
+		auth.TokenConfig{Name: "orders", Token: "orders-secret", Role: "write", KeyPatterns: []string{"orders:*"}},
+		auth.TokenConfig{Name: "acme-orders", Token: "acme-secret", Role: "write", KeyPatterns: []string{"acme:orders:*"}},
+	)
+
+	for _, tc := range []struct {
+		name, path, token string
+		want              int
+	}{
+		{"own keys", "/keys/orders:1", "orders-secret", http.StatusCreated},
+		{"namespace escapes the pattern", "/ns/acme/keys/orders:1", "orders-secret", http.StatusForbidden},
+		{"pattern names the namespace", "/ns/acme/keys/orders:1", "acme-secret", http.StatusCreated},
+		{"outside the namespace", "/keys/orders:1", "acme-secret", http.StatusForbidden},
+		{"other namespace", "/ns/other/keys/orders:1", "acme-secret", http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := do(s, http.MethodPost, tc.path, tc.token, `{"value":"x"}`); rec.Code != tc.want {
+				t.Errorf("POST %s = %d, want %d: %s", tc.path, rec.Code, tc.want, rec.Body)
+			}
+		})
+	}
+
+	// Listings are narrowed and filtered the same way
+	rec := do(s, http.MethodGet, "/ns/acme/keys?pattern=*", "acme-secret", "")
+	var page struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Keys) != 1 || page.Keys[0] != "orders:1" {
+		t.Errorf("keys in acme = %q, want only orders:1", page.Keys)
+	}
+}
+
+func TestTokenNamespaces(t *testing.T) {
+	s := newTestServer(t, auth.TokenConfig{Name: "tenant", Token: "tenant-secret", Role: "write", Namespaces: []string{"acme", "acme-staging"}})
+
+	for _, tc := range []struct {
+		name, path string
+		header     []string
+		want       int
+	}{
+		{"bound namespace", "/ns/acme/keys/k", nil, http.StatusCreated},
+		{"bound namespace by header", "/keys/k", []string{"X-Namespace", "acme-staging"}, http.StatusCreated},
+		{"other namespace", "/ns/globex/keys/k", nil, http.StatusForbidden},
+		{"no namespace", "/keys/acme:k", nil, http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := do(s, http.MethodPost, tc.path, "tenant-secret", `{"value":"x"}`, tc.header...); rec.Code != tc.want {
+				t.Errorf("POST %s = %d, want %d: %s", tc.path, rec.Code, tc.want, rec.Body)
+			}
+		})
+	}
+
+	// Unbound tokens may use any namespace
+	if rec := do(s, http.MethodPost, "/ns/globex/keys/k", testWriteToken, `{"value":"x"}`); rec.Code != http.StatusCreated {
+		t.Errorf("unbound token = %d, want 201: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestKeyPatternsRefuseKeylessRoutes(t *testing.T) {
+	s := newTestServer(t, auth.TokenConfig{Name: "orders", Token: "orders-secret", Role: "write", KeyPatterns: []string{"orders:*"}})
+
+	for _, tc := range []struct {
+		pattern, path string
+	}{
+		{"POST /locks/{name}", "/locks/orders:job"},
+		{"GET /sessions/{id}", "/sessions/0123"},
+		{"POST /sessions", "/sessions"},
+		{"POST /leaderboards/{name}/scores", "/leaderboards/orders/scores"},
+		{"POST /ratelimit/{bucket}", "/ratelimit/orders"},
+	} {
+		t.Run(tc.pattern, func(t *testing.T) {
+			for _, token := range []string{"orders-secret", testWriteToken} {
+				var ran bool
+				handler := s.authMiddleware(auth.RoleWrite, func(w http.ResponseWriter, r *http.Request) { ran = true })
+				req := httptest.NewRequest(strings.Fields(tc.pattern)[0], tc.path, nil)
+				req.Pattern = tc.pattern
+				req.Header.Set("Authorization", "Bearer "+token)
+				rec := httptest.NewRecorder()
+				handler(rec, req)
+
+				// Only the unrestricted token gets through
+				if restricted := token == "orders-secret"; ran == restricted || restricted && rec.Code != http.StatusForbidden {
+					t.Errorf("token %s: ran = %v, status %d: %s", token, ran, rec.Code, rec.Body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"valkey-rest/handlers"
+)
+
+// errCircuitOpen is reported while the circuit breaker rejects requests.
+var errCircuitOpen = errors.New("Valkey is unavailable, circuit breaker open")
+
+// CircuitBreaker stops sending requests to Valkey after a run of consecutive
+// connection failures, so an outage fails fast instead of every request
+// waiting out its timeout. While open, a background PING checks Valkey every
+// probe interval and closes the circuit once it answers. A nil breaker is
+// always closed.
+type CircuitBreaker struct {
+	threshold int
+	interval  time.Duration
+	ping      func(ctx context.Context) error
+
+	open     atomic.Bool
+	mu       sync.Mutex
+	failures int
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCircuitBreaker returns a breaker that opens after threshold consecutive
+// failures and probes with ping, or nil if threshold is zero.
+func NewCircuitBreaker(threshold int, interval time.Duration, ping func(ctx context.Context) error) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		interval:  interval,
+		ping:      ping,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Open reports whether requests are currently being rejected.
+func (b *CircuitBreaker) Open() bool {
+	return b != nil && b.open.Load()
+}
+
+// RetryAfter is how long clients are told to wait while the circuit is open.
+func (b *CircuitBreaker) RetryAfter() time.Duration {
+	return b.interval
+}
+
+// Record counts the outcome of a Valkey command. Only connection failures
+// and timeouts count against Valkey: error replies and key misses show it is
+// answering, and cancellations come from clients going away.
+func (b *CircuitBreaker) Record(err error) {
+	if b == nil || b.open.Load() {
+		return
+	}
+	failed := err != nil && !valkey.IsValkeyNil(err) && !errors.Is(err, context.Canceled)
+	if _, ok := valkey.IsValkeyErr(err); ok {
+		failed = false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold && !b.open.Load() {
+		b.open.Store(true)
+		circuitBreakerOpen.Set(1)
+		log.Printf("Circuit breaker opened after %d consecutive Valkey failures: %v", b.failures, err)
+		go b.probe()
+	}
+}
+
+// probe pings Valkey until it answers, then closes the circuit.
+func (b *CircuitBreaker) probe() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.interval)
+		err := b.ping(ctx)
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		b.mu.Lock()
+		b.failures = 0
+		b.open.Store(false)
+		b.mu.Unlock()
+		circuitBreakerOpen.Set(0)
+		log.Println("Circuit breaker closed, Valkey is reachable again")
+		return
+	}
+}
+
+// Close stops the background probe.
+func (b *CircuitBreaker) Close() {
+	if b != nil {
+		b.stopOnce.Do(func() { close(b.stop) })
+	}
+}
+
+// breakerExempt are the paths still served while the circuit is open. They
+// don't need Valkey or report on it themselves.
+var breakerExempt = map[string]bool{
+	"/health":       true,
+	"/livez":        true,
+	"/readyz":       true,
+	"/metrics":      true,
+	"/openapi.json": true,
+	"/docs":         true,
+}
+
+// circuitBreakerMiddleware rejects requests with 503 while the circuit is
+// open, before rate limiting or authentication try to reach Valkey.
+func (s *Server) circuitBreakerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.breaker.Open() && !breakerExempt[r.URL.Path] {
+			// Retry-After is in whole seconds, rounded up
+			retry := int64((s.breaker.RetryAfter() + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.FormatInt(retry, 10))
+			handlers.WriteError(w, http.StatusServiceUnavailable, handlers.CodeValkeyUnavailable, errCircuitOpen.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// grpcCircuitOpen returns an Unavailable status while the circuit is open.
+func (s *Server) grpcCircuitOpen() error {
+	if s.breaker.Open() {
+		return status.Error(codes.Unavailable, errCircuitOpen.Error())
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/config"
+)
+
+// newValkeyClient connects to the Valkey deployment described by cfg and
+// checks the connection with a PING.
+func newValkeyClient(cfg *config.Config) (valkey.Client, error) {
+	// Cluster mode is detected automatically; any listed node can seed the topology
+	clientOption := valkey.ClientOption{
+		InitAddress: config.SplitList(cfg.ValkeyAddress),
+		ShuffleInit: true,
+		SelectDB:    int(cfg.ValkeyDB),
+		DialCtxFn:   countingDial,
+	}
+
+	// valkey-go retries read-only commands after connection errors; bound
+	// that by VALKEY_RETRIES, which is off by default
+	if cfg.CommandRetries > 0 {
+		clientOption.RetryDelay = retryDelay(cfg.CommandRetries, cfg.CommandRetryBackoff)
+	} else {
+		clientOption.DisableRetry = true
+	}
+
+	// Add password if provided
+	if cfg.ValkeyPassword != "" {
+		clientOption.Password = cfg.ValkeyPassword
+	}
+
+	if cfg.ValkeyTLS {
+		tlsConfig, err := valkeyTLSConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("configure Valkey TLS: %w", err)
+		}
+		clientOption.TLSConfig = tlsConfig
+	}
+
+	// Read-only commands (GET, SCAN, TTL, ...) go to replicas, writes stay on
+	// the primary. Cluster replicas are discovered automatically; a standalone
+	// primary needs its replicas listed explicitly.
+	if cfg.ReadFromReplicas {
+		clientOption.SendToReplicas = func(cmd valkey.Completed) bool {
+			return cmd.IsReadOnly()
+		}
+		clientOption.Standalone.ReplicaAddress = config.SplitList(cfg.ReplicaAddresses)
+	}
+
+	// With Sentinel the client connects to the sentinels, asks them for the
+	// current primary and follows it across failovers
+	if cfg.SentinelMaster != "" {
+		sentinels := config.SplitList(cfg.SentinelAddresses)
+		if len(sentinels) == 0 {
+			return nil, errors.New("VALKEY_SENTINEL_ADDRESSES is required when VALKEY_SENTINEL_MASTER is set")
+		}
+		clientOption.InitAddress = sentinels
+		clientOption.Sentinel = valkey.SentinelOption{
+			MasterSet: cfg.SentinelMaster,
+			Password:  cfg.SentinelPassword,
+			TLSConfig: clientOption.TLSConfig,
+		}
+	}
+
+	client, err := valkey.NewClient(clientOption)
+	if err != nil {
+		return nil, fmt.Errorf("create Valkey client: %w", err)
+	}
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Do(ctx, client.B().Ping().Build()).ToString(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to Valkey: %w", err)
+	}
+
+	if cfg.SentinelMaster != "" {
+		log.Printf("Connected to Valkey primary %q via sentinels at %s, database %d", cfg.SentinelMaster, cfg.SentinelAddresses, cfg.ValkeyDB)
+	} else {
+		log.Printf("Connected to Valkey at %s (%s mode), database %d", cfg.ValkeyAddress, client.Mode(), cfg.ValkeyDB)
+	}
+	if cfg.ValkeyPassword != "" {
+		log.Println("Valkey password authentication enabled")
+	}
+	if cfg.ReadFromReplicas {
+		if client.Mode() == valkey.ClientModeCluster || cfg.ReplicaAddresses != "" {
+			log.Println("Read-only commands are routed to replicas")
+		} else {
+			log.Println("Warning: VALKEY_READ_FROM_REPLICAS is set but no replicas are configured - all commands go to the primary")
+		}
+	}
+	if cfg.CommandRetries > 0 {
+		log.Printf("Read-only commands are retried up to %d times after connection errors", cfg.CommandRetries)
+	}
+	if cfg.ValkeyTLS {
+		log.Println("Valkey TLS enabled")
+		if cfg.ValkeyTLSInsecure {
+			log.Println("Warning: Valkey TLS certificate verification is disabled")
+		}
+	}
+	return client, nil
+}
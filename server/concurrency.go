@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+// errSaturated is reported when a request can't get a slot in time.
+var errSaturated = errors.New("server is at capacity, try again later")
+
+// semaphore limits how many requests run at once. Up to maxWaiting more may
+// wait for a slot; anything beyond that is refused straight away, so a spike
+// can't pile up unbounded work behind a full server.
+type semaphore struct {
+	slots      chan struct{}
+	waiting    atomic.Int64
+	maxWaiting int64
+}
+
+func newSemaphore(size int, maxWaiting int) *semaphore {
+	return &semaphore{slots: make(chan struct{}, size), maxWaiting: int64(maxWaiting)}
+}
+
+// acquire takes a slot, waiting up to timeout if the queue has room. It
+// reports false if no slot was free in time or ctx ended first.
+func (s *semaphore) acquire(ctx context.Context, timeout time.Duration) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if s.waiting.Add(1) > s.maxWaiting {
+		s.waiting.Add(-1)
+		return false
+	}
+	defer s.waiting.Add(-1)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *semaphore) release() {
+	<-s.slots
+}
+
+// ConcurrencyLimiter bounds the requests in flight, across the server and
+// for each token. A nil limiter admits everything.
+type ConcurrencyLimiter struct {
+	global       *semaphore // nil without a global limit
+	perPrincipal int
+	maxWaiting   int
+	timeout      time.Duration
+
+	mu         sync.Mutex
+	principals map[string]*principalSlots
+}
+
+// principalSlots is a principal's semaphore and the number of requests
+// holding or waiting for it, so idle principals can be forgotten.
+type principalSlots struct {
+	sem   *semaphore
+	users int
+}
+
+// NewConcurrencyLimiter returns a limiter allowing global requests at once
+// and perPrincipal for each token, with up to maxWaiting requests waiting
+// at most timeout for each. It returns nil if both limits are zero.
+func NewConcurrencyLimiter(global, perPrincipal, maxWaiting int, timeout time.Duration) *ConcurrencyLimiter {
+	if global <= 0 && perPrincipal <= 0 {
+		return nil
+	}
+	l := &ConcurrencyLimiter{
+		perPrincipal: perPrincipal,
+		maxWaiting:   maxWaiting,
+		timeout:      timeout,
+		principals:   make(map[string]*principalSlots),
+	}
+	if global > 0 {
+		l.global = newSemaphore(global, maxWaiting)
+	}
+	return l
+}
+
+// Acquire takes a global slot and returns the function releasing it, or
+// false if the server is saturated.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) (func(), bool) {
+	if l == nil || l.global == nil {
+		return func() {}, true
+	}
+	if !l.global.acquire(ctx, l.timeout) {
+		concurrencyRejectedTotal.WithLabelValues("global").Inc()
+		return nil, false
+	}
+	inFlightRequests.Inc()
+	return func() {
+		inFlightRequests.Dec()
+		l.global.release()
+	}, true
+}
+
+// AcquirePrincipal takes one of the principal's slots and returns the
+// function releasing it, or false if the principal has too many requests
+// in flight.
+func (l *ConcurrencyLimiter) AcquirePrincipal(ctx context.Context, id string) (func(), bool) {
+	if l == nil || l.perPrincipal <= 0 {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	slots := l.principals[id]
+	if slots == nil {
+		slots = &principalSlots{sem: newSemaphore(l.perPrincipal, l.maxWaiting)}
+		l.principals[id] = slots
+	}
+	slots.users++
+	l.mu.Unlock()
+
+	done := func() {
+		l.mu.Lock()
+		if slots.users--; slots.users == 0 {
+			delete(l.principals, id)
+		}
+		l.mu.Unlock()
+	}
+
+	if !slots.sem.acquire(ctx, l.timeout) {
+		done()
+		concurrencyRejectedTotal.WithLabelValues("token").Inc()
+		return nil, false
+	}
+	return func() {
+		slots.sem.release()
+		done()
+	}, true
+}
+
+// RetryAfter is how long refused clients are told to wait.
+func (l *ConcurrencyLimiter) RetryAfter() time.Duration {
+	return max(l.timeout, time.Second)
+}
+
+// concurrencyExempt reports whether a path is left out of the limits:
+// probes and metrics, which must answer under load, and streaming routes,
+// which would hold a slot for as long as the client stays connected.
+func concurrencyExempt(path string) bool {
+	return breakerExempt[path] || path == "/ws" || strings.HasPrefix(path, "/subscribe/")
+}
+
+// writeSaturated answers 503 with a Retry-After header.
+func (s *Server) writeSaturated(w http.ResponseWriter) {
+	// Retry-After is in whole seconds, rounded up
+	retry := int64((s.concurrency.RetryAfter() + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(retry, 10))
+	handlers.WriteError(w, http.StatusServiceUnavailable, handlers.CodeOverloaded, errSaturated.Error())
+}
+
+// concurrencyMiddleware applies the global limit. Per-token limits are
+// applied by authMiddleware once the token is known.
+func (s *Server) concurrencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if concurrencyExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		release, ok := s.concurrency.Acquire(r.Context())
+		if !ok {
+			s.writeSaturated(w)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// grpcAcquire is the gRPC counterpart of concurrencyMiddleware and the
+// per-token limit, returning an Unavailable status when either is saturated.
+func (s *Server) grpcAcquire(ctx context.Context) (func(), error) {
+	release, ok := s.concurrency.Acquire(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unavailable, errSaturated.Error())
+	}
+	if p := auth.FromContext(ctx); p != nil {
+		releasePrincipal, ok := s.concurrency.AcquirePrincipal(ctx, p.ID)
+		if !ok {
+			release()
+			return nil, status.Error(codes.Unavailable, errSaturated.Error())
+		}
+		return func() {
+			releasePrincipal()
+			release()
+		}, nil
+	}
+	return release, nil
+}
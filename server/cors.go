@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"valkey-rest/config"
+	"valkey-rest/handlers"
+)
+
+// corsExposedHeaders are the response headers browsers may show to scripts,
+// besides the ones that are always visible.
+const corsExposedHeaders = "ETag, Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, Content-Disposition, Idempotent-Replayed"
+
+// corsPolicy decides which browser origins may call the API.
+type corsPolicy struct {
+	origins     []string // Exact origins, "*", or "https://*.example.com" patterns
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+// newCORSPolicy returns the policy described by cfg, or nil when no origins
+// are allowed.
+func newCORSPolicy(cfg config.Config) *corsPolicy {
+	origins := config.SplitList(cfg.CORSAllowedOrigins)
+	if len(origins) == 0 {
+		return nil
+	}
+	return &corsPolicy{
+		origins:     origins,
+		methods:     strings.Join(config.SplitList(cfg.CORSAllowedMethods), ", "),
+		headers:     strings.Join(config.SplitList(cfg.CORSAllowedHeaders), ", "),
+		credentials: cfg.CORSAllowCredentials,
+		maxAge:      strconv.Itoa(int(cfg.CORSMaxAge.Seconds())),
+	}
+}
+
+// allowed reports whether origin may call the API.
+func (p *corsPolicy) allowed(origin string) bool {
+	for _, allowed := range p.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// A leading wildcard matches any subdomain, but not the domain itself
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(strings.ToLower(origin), "."+strings.ToLower(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware answers preflight requests and adds CORS headers to
+// responses for allowed origins. Preflights are answered before rate
+// limiting and authentication, since browsers send them without credentials.
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	if s.cors == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !s.cors.allowed(origin) {
+			if preflight {
+				handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, "origin not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The origin is echoed rather than "*" so responses can carry
+		// credentials and caches keep them apart by the Vary header
+		h.Set("Access-Control-Allow-Origin", origin)
+		if s.cors.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", s.cors.methods)
+			h.Set("Access-Control-Allow-Headers", s.cors.headers)
+			h.Set("Access-Control-Max-Age", s.cors.maxAge)
+			h.Del("Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/config"
+	"valkey-rest/handlers"
+	"valkey-rest/store"
+)
+
+// DBParam is the query parameter selecting the logical database of a request.
+const DBParam = "db"
+
+// dbClient sends commands to the client for the database selected in their
+// context with store.WithDB, and to the configured database otherwise.
+// Connections dedicated without a context, used for Pub/Sub, always use the
+// configured database; Pub/Sub channels don't belong to a database.
+type dbClient struct {
+	valkey.Client
+	dbs map[int64]valkey.Client
+}
+
+// ForContext returns the client for the database selected in ctx.
+func (c dbClient) ForContext(ctx context.Context) valkey.Client {
+	if db, ok := store.DBFromContext(ctx); ok {
+		if client, ok := c.dbs[db]; ok {
+			return client
+		}
+	}
+	return c.Client
+}
+
+func (c dbClient) Do(ctx context.Context, cmd valkey.Completed) valkey.ValkeyResult {
+	return c.ForContext(ctx).Do(ctx, cmd)
+}
+
+func (c dbClient) DoMulti(ctx context.Context, multi ...valkey.Completed) []valkey.ValkeyResult {
+	return c.ForContext(ctx).DoMulti(ctx, multi...)
+}
+
+func (c dbClient) DoCache(ctx context.Context, cmd valkey.Cacheable, ttl time.Duration) valkey.ValkeyResult {
+	return c.ForContext(ctx).DoCache(ctx, cmd, ttl)
+}
+
+func (c dbClient) DoMultiCache(ctx context.Context, multi ...valkey.CacheableTTL) []valkey.ValkeyResult {
+	return c.ForContext(ctx).DoMultiCache(ctx, multi...)
+}
+
+func (c dbClient) DoStream(ctx context.Context, cmd valkey.Completed) valkey.ValkeyResultStream {
+	return c.ForContext(ctx).DoStream(ctx, cmd)
+}
+
+func (c dbClient) DoMultiStream(ctx context.Context, multi ...valkey.Completed) valkey.MultiValkeyResultStream {
+	return c.ForContext(ctx).DoMultiStream(ctx, multi...)
+}
+
+func (c dbClient) Close() {
+	for _, client := range c.dbs {
+		client.Close()
+	}
+	c.Client.Close()
+}
+
+// HasDB reports whether requests may use db, which handlers check for
+// databases named in a request body, such as the target of COPY.
+func (c dbClient) HasDB(db int64) bool {
+	_, ok := c.dbs[db]
+	return ok
+}
+
+// HasDB passes the allow-list through the instrumentation.
+func (c instrumentedClient) HasDB(db int64) bool {
+	checker, ok := c.Client.(interface{ HasDB(int64) bool })
+	return ok && checker.HasDB(db)
+}
+
+// ForContext passes the database selection through the instrumentation.
+func (c instrumentedClient) ForContext(ctx context.Context) valkey.Client {
+	if router, ok := c.Client.(interface {
+		ForContext(context.Context) valkey.Client
+	}); ok {
+		return instrumentedClient{Client: router.ForContext(ctx), breaker: c.breaker}
+	}
+	return c
+}
+
+// connectAllowedDBs connects a client for every database in
+// VALKEY_DB_ALLOW other than VALKEY_DB, and returns a client routing between
+// them and client. client is closed if any connection fails.
+func connectAllowedDBs(client valkey.Client, cfg config.Config) (valkey.Client, error) {
+	dbs := make(map[int64]valkey.Client)
+	for _, item := range config.SplitList(cfg.ValkeyDBAllow) {
+		db, err := strconv.ParseInt(item, 10, 64)
+		if err != nil || db == cfg.ValkeyDB || dbs[db] != nil {
+			continue
+		}
+		if client.Mode() == valkey.ClientModeCluster {
+			client.Close()
+			return nil, errors.New("VALKEY_DB_ALLOW is not supported in cluster mode")
+		}
+
+		dbCfg := cfg
+		dbCfg.ValkeyDB = db
+		dbs[db], err = newValkeyClient(&dbCfg)
+		if err != nil {
+			dbClient{Client: client, dbs: dbs}.Close()
+			return nil, fmt.Errorf("database %d: %w", db, err)
+		}
+	}
+	if len(dbs) == 0 {
+		return client, nil
+	}
+	return dbClient{Client: client, dbs: dbs}, nil
+}
+
+// dbRoute reports whether a route honours ?db=. Routes keeping the proxy's
+// own state, such as API keys and webhooks, always use the configured
+// database.
+func dbRoute(pattern string) bool {
+	_, path, _ := strings.Cut(pattern, " ")
+	for _, prefix := range []string{"/admin/apikeys", "/admin/webhooks", "/admin/audit", "/admin/reload", "/admin/read-only"} {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	// The schedule is swept in the configured database only
+	return !strings.HasSuffix(path, "/schedule")
+}
+
+// dbMiddleware runs a route against the database selected with ?db=, which
+// must be the configured one or in VALKEY_DB_ALLOW. It runs after
+// authentication and idempotency checks, whose keys stay in the configured
+// database.
+func (s *Server) dbMiddleware(supported bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query().Get(DBParam)
+		if v == "" {
+			next(w, r)
+			return
+		}
+
+		db, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || db < 0 {
+			handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, "db must be a non-negative integer")
+			return
+		}
+		if db == s.db {
+			next(w, r)
+			return
+		}
+		if !supported {
+			handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, "db can't be selected for this endpoint")
+			return
+		}
+		if !s.allowedDBs[db] {
+			handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, fmt.Sprintf("database %d is not allowed", db))
+			return
+		}
+		next(w, r.WithContext(store.WithDB(r.Context(), db)))
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters served as JSON at /debug/vars on the debug listener, next to the
+// runtime's memstats and cmdline.
+var (
+	// commandStats maps each Valkey command to its calls, errors and total
+	// and slowest latency in microseconds
+	commandStats   = expvar.NewMap("valkey_commands")
+	commandStatsMu sync.Mutex
+
+	// poolStats counts the connections valkey-go opens, including those of
+	// its blocking and dedicated pools
+	poolStats = expvar.NewMap("valkey_pool")
+)
+
+// commandStat returns the counters of a command, creating them on first use.
+func commandStat(name string) *expvar.Map {
+	if v, ok := commandStats.Get(name).(*expvar.Map); ok {
+		return v
+	}
+	commandStatsMu.Lock()
+	defer commandStatsMu.Unlock()
+	if v, ok := commandStats.Get(name).(*expvar.Map); ok {
+		return v
+	}
+	stat := new(expvar.Map).Init()
+	stat.Set("max_us", new(maxInt))
+	commandStats.Set(name, stat)
+	return stat
+}
+
+// recordCommand adds a finished command to its counters.
+func recordCommand(name string, latency time.Duration, failed bool) {
+	stat := commandStat(name)
+	us := latency.Microseconds()
+	stat.Add("calls", 1)
+	stat.Add("latency_us", us)
+	stat.Get("max_us").(*maxInt).observe(us)
+	if failed {
+		stat.Add("errors", 1)
+	}
+}
+
+// maxInt is an expvar.Var holding the largest value observed.
+type maxInt struct {
+	v atomic.Int64
+}
+
+func (m *maxInt) observe(v int64) {
+	for {
+		current := m.v.Load()
+		if v <= current || m.v.CompareAndSwap(current, v) {
+			return
+		}
+	}
+}
+
+func (m *maxInt) String() string {
+	return strconv.FormatInt(m.v.Load(), 10)
+}
+
+// countingDial is valkey-go's default dialer, counting the connections it
+// opens and how many are still open.
+func countingDial(ctx context.Context, addr string, dialer *net.Dialer, tlsConfig *tls.Config) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		poolStats.Add("dial_errors", 1)
+		return nil, err
+	}
+	poolStats.Add("connections_dialed", 1)
+	poolStats.Add("connections_open", 1)
+	return &countedConn{Conn: conn}, nil
+}
+
+// countedConn takes itself off the open connections when closed.
+type countedConn struct {
+	net.Conn
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { poolStats.Add("connections_open", -1) })
+	return c.Conn.Close()
+}
+
+// DebugHandler serves net/http/pprof under /debug/pprof/ and expvar at
+// /debug/vars. It has no authentication, so only serve it on a listener
+// that operators alone can reach.
+func DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
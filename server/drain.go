@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// drainPollInterval is how often Drain checks whether requests are done.
+	drainPollInterval = 50 * time.Millisecond
+	// drainGrace is how long cancelled requests get to return.
+	drainGrace = time.Second
+)
+
+// errShuttingDown is the readiness check reported once shutdown has begun.
+var errShuttingDown = errors.New("server is shutting down")
+
+// drainState tracks the requests in flight so shutdown can wait for them.
+type drainState struct {
+	draining atomic.Bool
+	inFlight atomic.Int64
+	// stop is cancelled when shutdown stops waiting, ending the requests
+	// still running along with their SSE and WebSocket streams
+	stop   context.Context
+	cancel context.CancelFunc
+}
+
+// drainMiddleware counts requests in flight and ties each to the drain
+// state, so Drain can wait for them and cancel the ones that outlast it.
+func (s *Server) drainMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.drain.inFlight.Add(1)
+		defer s.drain.inFlight.Add(-1)
+
+		// Requests arriving on keep-alive connections during shutdown are
+		// still served, but the client is told to reconnect elsewhere
+		if s.drain.draining.Load() {
+			w.Header().Set("Connection", "close")
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(s.drain.stop, cancel)
+		defer stop()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// StartDraining marks the server as shutting down: /readyz fails from now
+// on, so load balancers stop sending it new requests, while requests keep
+// being served until the listeners are closed.
+func (s *Server) StartDraining() {
+	s.drain.draining.Store(true)
+}
+
+// InFlight returns the number of HTTP requests being served, including open
+// SSE and WebSocket streams.
+func (s *Server) InFlight() int64 {
+	return s.drain.inFlight.Load()
+}
+
+// Drain waits for the requests in flight to finish. If ctx ends first, the
+// requests still running are cancelled, which also closes SSE and WebSocket
+// streams, and given drainGrace to return before ctx's error is returned.
+// Close can follow without pulling the Valkey client from under a request.
+func (s *Server) Drain(ctx context.Context) error {
+	s.StartDraining()
+	if s.waitInFlight(ctx) {
+		return nil
+	}
+
+	s.drain.cancel()
+	grace, cancel := context.WithTimeout(context.Background(), drainGrace)
+	defer cancel()
+	s.waitInFlight(grace)
+	return ctx.Err()
+}
+
+// waitInFlight waits until no requests are in flight, reporting false if
+// ctx ended first.
+func (s *Server) waitInFlight(ctx context.Context) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for s.drain.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,658 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+	"valkey-rest/store"
+	pb "valkey-rest/valkeyrestpb"
+)
+
+// grpcMethodRoles is the role each RPC requires, matching the equivalent
+// REST route. Methods missing from the map require admin.
+var grpcMethodRoles = map[string]auth.Role{
+	pb.KV_Get_FullMethodName:           auth.RoleRead,
+	pb.KV_Set_FullMethodName:           auth.RoleWrite,
+	pb.KV_Delete_FullMethodName:        auth.RoleWrite,
+	pb.KV_Exists_FullMethodName:        auth.RoleRead,
+	pb.KV_List_FullMethodName:          auth.RoleRead,
+	pb.Hash_Get_FullMethodName:         auth.RoleRead,
+	pb.Hash_GetAll_FullMethodName:      auth.RoleRead,
+	pb.Hash_Set_FullMethodName:         auth.RoleWrite,
+	pb.Hash_Delete_FullMethodName:      auth.RoleWrite,
+	pb.List_Push_FullMethodName:        auth.RoleWrite,
+	pb.List_Pop_FullMethodName:         auth.RoleWrite,
+	pb.List_Range_FullMethodName:       auth.RoleRead,
+	pb.List_Length_FullMethodName:      auth.RoleRead,
+	pb.PubSub_Publish_FullMethodName:   auth.RoleWrite,
+	pb.PubSub_Subscribe_FullMethodName: auth.RoleRead,
+}
+
+type grpcNamespaceKey struct{}
+
+// NewGRPCServer builds the gRPC server. It shares the Valkey client,
+// credentials and rate limits of the REST server; the bearer token travels in
+// the "authorization" metadata entry and the namespace in "x-namespace".
+func NewGRPCServer(s *Server, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.UnaryInterceptor(s.grpcUnaryInterceptor),
+		grpc.StreamInterceptor(s.grpcStreamInterceptor),
+	)
+	gs := grpc.NewServer(opts...)
+	pb.RegisterKVServer(gs, &grpcKV{s: s})
+	pb.RegisterHashServer(gs, &grpcHash{s: s})
+	pb.RegisterListServer(gs, &grpcList{s: s})
+	pb.RegisterPubSubServer(gs, &grpcPubSub{s: s})
+	return gs
+}
+
+func (s *Server) grpcUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	subject := "anonymous"
+	err := s.grpcAllowAddr(ctx)
+	if err == nil {
+		err = s.grpcCircuitOpen()
+	}
+	if err == nil {
+		ctx, subject, err = s.grpcAuthorize(s.grpcCommandTimeout(ctx), info.FullMethod, req)
+	}
+	if err == nil {
+		err = grpcReservedKey(req)
+	}
+	if err == nil {
+		err = s.grpcReadOnly(info.FullMethod)
+	}
+	var release func()
+	if err == nil {
+		release, err = s.grpcAcquire(ctx)
+	}
+	var resp any
+	if err == nil {
+		resp, err = handler(ctx, req)
+		release()
+		s.grpcRecordUsage(ctx, info.FullMethod, req, resp)
+	}
+	logGRPC(ctx, info.FullMethod, subject, start, err)
+	if role, ok := grpcMethodRoles[info.FullMethod]; s.audit != nil && (!ok || role > auth.RoleRead) {
+		s.audit.Record(grpcAuditEntry(ctx, info.FullMethod, subject, req, start, err))
+	}
+	return resp, err
+}
+
+// grpcAuditEntry describes a unary call for the audit log.
+func grpcAuditEntry(ctx context.Context, method, subject string, req any, start time.Time, err error) AuditEntry {
+	e := AuditEntry{
+		Time:      start,
+		Subject:   subject,
+		Namespace: grpcNamespace(ctx),
+		Method:    "GRPC",
+		Route:     method,
+		Code:      status.Code(err).String(),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		e.RemoteIP, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+	if r, ok := req.(interface{ GetKey() string }); ok {
+		e.Key = r.GetKey()
+	}
+	switch status.Code(err) {
+	case codes.OK:
+		e.Outcome = "success"
+	case codes.Unauthenticated, codes.PermissionDenied:
+		e.Outcome = "denied"
+	default:
+		e.Outcome = "failure"
+	}
+	return e
+}
+
+// grpcStreamInterceptor authorizes streaming calls before the first message
+// is read, so key patterns are not checked; the only streaming RPC takes a
+// channel, which REST doesn't check either.
+func (s *Server) grpcStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, subject := ss.Context(), "anonymous"
+	err := s.grpcAllowAddr(ctx)
+	if err == nil {
+		err = s.grpcCircuitOpen()
+	}
+	if err == nil {
+		ctx, subject, err = s.grpcAuthorize(s.grpcCommandTimeout(ctx), info.FullMethod, nil)
+	}
+	if err == nil {
+		err = handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+		s.grpcRecordUsage(ctx, info.FullMethod, nil, nil)
+	}
+	logGRPC(ctx, info.FullMethod, subject, start, err)
+	return err
+}
+
+// grpcServerStream replaces the context of a stream with the authorized one.
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *grpcServerStream) Context() context.Context {
+	return ss.ctx
+}
+
+// logGRPC emits one log line per call, in the same shape as REST requests.
+func logGRPC(ctx context.Context, method, subject string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.Canceled:
+	case codes.Internal, codes.Unknown, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+
+	slog.LogAttrs(ctx, level, "grpc request",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("subject", subject),
+		slog.String("namespace", grpcNamespace(ctx)),
+	)
+}
+
+// grpcAuthorize is the gRPC counterpart of namespaceMiddleware and
+// authMiddleware. It returns the context handlers should run with and the
+// subject to log.
+func (s *Server) grpcAuthorize(ctx context.Context, method string, req any) (context.Context, string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get(strings.ToLower(handlers.NamespaceHeader)); len(values) > 0 {
+		if !handlers.ValidNamespace(values[0]) {
+			return ctx, "anonymous", status.Error(codes.InvalidArgument, "invalid namespace")
+		}
+		ctx = context.WithValue(ctx, grpcNamespaceKey{}, values[0])
+	}
+
+	if !s.authEnabled() {
+		return ctx, "anonymous", nil
+	}
+
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token = strings.TrimPrefix(values[0], "Bearer ")
+	}
+	if token == "" {
+		return ctx, "anonymous", status.Error(codes.Unauthenticated, "authorization token required")
+	}
+
+	principal, err := s.lookupPrincipal(ctx, token)
+	if err != nil {
+		return ctx, "anonymous", status.Error(codes.Internal, "internal server error")
+	}
+	if principal == nil {
+		return ctx, "anonymous", status.Error(codes.Unauthenticated, "invalid authorization token")
+	}
+
+	if err := s.grpcAllowPrincipal(ctx, principal); err != nil {
+		return ctx, principal.Name, err
+	}
+	if err := s.grpcAllowUsage(ctx, principal); err != nil {
+		return ctx, principal.Name, err
+	}
+
+	required, ok := grpcMethodRoles[method]
+	if !ok {
+		required = auth.RoleAdmin
+	}
+	if principal.Role < required {
+		return ctx, principal.Name, status.Errorf(codes.PermissionDenied, "%s role required", required)
+	}
+	if !principal.CanUseNamespace(grpcNamespace(ctx)) {
+		return ctx, principal.Name, status.Error(codes.PermissionDenied, errNamespaceDenied)
+	}
+
+	if r, ok := req.(interface{ GetKey() string }); ok {
+		if key := r.GetKey(); key != "" && !principal.CanAccessKey(grpcKey(ctx, key)) {
+			return ctx, principal.Name, status.Error(codes.PermissionDenied, "access to key denied")
+		}
+	}
+
+	return auth.NewContext(ctx, principal), principal.Name, nil
+}
+
+// grpcReservedKey is the gRPC counterpart of reservedKeyMiddleware.
+func grpcReservedKey(req any) error {
+	if r, ok := req.(interface{ GetKey() string }); ok && handlers.ReservedKey(r.GetKey()) {
+		return status.Error(codes.PermissionDenied, errReservedKey.Error())
+	}
+	return nil
+}
+
+// grpcAllowPrincipal applies the same per-token limits as allowPrincipal,
+// failing open when Valkey can't be reached.
+func (s *Server) grpcAllowPrincipal(ctx context.Context, p *auth.Principal) error {
+	limits := s.limiter.limits.Load()
+	limit, period := limits.perToken, limits.period
+	if p.RateLimit > 0 {
+		limit, period = p.RateLimit, time.Minute
+	}
+	if limit <= 0 {
+		return nil
+	}
+
+	result, err := s.limiter.Take(ctx, "principal:"+p.ID, limit, 1, period)
+	if err != nil {
+		log.Printf("Rate limit check failed, allowing request: %v", err)
+		return nil
+	}
+	if !result.Allowed {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
+
+// grpcNamespace returns the tenant namespace of a call, or "" if none.
+func grpcNamespace(ctx context.Context) string {
+	ns, _ := ctx.Value(grpcNamespaceKey{}).(string)
+	return ns
+}
+
+// grpcKey maps a client-visible key to the key stored in Valkey.
+func grpcKey(ctx context.Context, key string) string {
+	return handlers.PrefixNamespace(grpcNamespace(ctx), key)
+}
+
+// grpcCommandError mirrors writeCommandError: errors returned by Valkey are
+// the caller's fault, anything else is internal.
+func grpcCommandError(err error) error {
+	if verr, ok := valkey.IsValkeyErr(err); ok {
+		return status.Error(codes.InvalidArgument, verr.Error())
+	}
+	return status.Error(codes.Internal, "internal server error")
+}
+
+var errGRPCKeyRequired = status.Error(codes.InvalidArgument, "key is required")
+
+// grpcKV serves plain string keys with the same value compression as /keys.
+type grpcKV struct {
+	pb.UnimplementedKVServer
+	s *Server
+}
+
+func (g *grpcKV) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	result, err := g.s.store.Get(ctx, grpcKey(ctx, req.Key))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, status.Error(codes.NotFound, "key not found")
+		}
+		return nil, grpcCommandError(err)
+	}
+
+	result, err = g.s.compressor.Decode(result)
+	if err != nil {
+		log.Printf("Failed to decode value of %s: %v", req.Key, err)
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &pb.GetResponse{Key: req.Key, Value: []byte(result)}, nil
+}
+
+func (g *grpcKV) Set(ctx context.Context, req *pb.SetRequest) (*pb.SetResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+	if len(req.Value) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "value is required")
+	}
+	if req.Expiration < 0 {
+		return nil, status.Error(codes.InvalidArgument, "expiration must be a non-negative integer")
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	stored := g.s.compressor.Encode(string(req.Value))
+	if err := g.s.store.Set(ctx, grpcKey(ctx, req.Key), stored, time.Duration(req.Expiration)*time.Second); err != nil {
+		return nil, grpcCommandError(err)
+	}
+	return &pb.SetResponse{}, nil
+}
+
+func (g *grpcKV) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	deleted, err := g.s.store.Del(ctx, grpcKey(ctx, req.Key))
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+	if !deleted {
+		return nil, status.Error(codes.NotFound, "key not found")
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+func (g *grpcKV) Exists(ctx context.Context, req *pb.ExistsRequest) (*pb.ExistsResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	exists, err := g.s.store.Exists(ctx, grpcKey(ctx, req.Key))
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+	return &pb.ExistsResponse{Exists: exists}, nil
+}
+
+// List returns one SCAN page, with the same defaults and cursor format as
+// GET /keys.
+func (g *grpcKV) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	pattern := req.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	limit := int(req.Limit)
+	if limit > 1000 || limit < 1 {
+		limit = 100
+	}
+	cursor := req.Cursor
+	if cursor == "" {
+		cursor = "0"
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	principal := auth.FromContext(ctx)
+	pattern = grpcKey(ctx, pattern)
+	if principal != nil {
+		pattern = principal.ScanPattern(pattern)
+	}
+	keys, next, err := g.s.store.Scan(ctx, pattern, cursor, limit)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+		}
+		return nil, grpcCommandError(err)
+	}
+
+	visible := make([]string, 0, len(keys))
+	for _, storedKey := range keys {
+		key := handlers.TrimNamespace(grpcNamespace(ctx), storedKey)
+		if !handlers.ReservedKey(key) && (principal == nil || principal.CanAccessKey(storedKey)) {
+			visible = append(visible, key)
+		}
+	}
+	return &pb.ListResponse{Keys: visible, Cursor: next}, nil
+}
+
+type grpcHash struct {
+	pb.UnimplementedHashServer
+	s *Server
+}
+
+func (g *grpcHash) Get(ctx context.Context, req *pb.HashGetRequest) (*pb.HashGetResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+	if req.Field == "" {
+		return nil, status.Error(codes.InvalidArgument, "field is required")
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	value, err := g.s.client.Do(ctx, g.s.client.B().Hget().Key(grpcKey(ctx, req.Key)).Field(req.Field).Build()).ToString()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			return nil, status.Error(codes.NotFound, "field not found")
+		}
+		return nil, grpcCommandError(err)
+	}
+	return &pb.HashGetResponse{Value: []byte(value)}, nil
+}
+
+func (g *grpcHash) GetAll(ctx context.Context, req *pb.HashGetAllRequest) (*pb.HashGetAllResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	values, err := g.s.client.Do(ctx, g.s.client.B().Hgetall().Key(grpcKey(ctx, req.Key)).Build()).AsStrMap()
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+	// HGETALL can't tell a missing key from an empty hash, and Valkey deletes
+	// empty hashes anyway
+	if len(values) == 0 {
+		return nil, status.Error(codes.NotFound, "key not found")
+	}
+
+	fields := make(map[string][]byte, len(values))
+	for field, value := range values {
+		fields[field] = []byte(value)
+	}
+	return &pb.HashGetAllResponse{Fields: fields}, nil
+}
+
+func (g *grpcHash) Set(ctx context.Context, req *pb.HashSetRequest) (*pb.HashSetResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+	if len(req.Fields) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "fields are required")
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	builder := g.s.client.B().Hset().Key(grpcKey(ctx, req.Key)).FieldValue()
+	for field, value := range req.Fields {
+		builder = builder.FieldValue(field, string(value))
+	}
+	added, err := g.s.client.Do(ctx, builder.Build()).AsInt64()
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+	return &pb.HashSetResponse{Added: added}, nil
+}
+
+func (g *grpcHash) Delete(ctx context.Context, req *pb.HashDeleteRequest) (*pb.HashDeleteResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+	if len(req.Fields) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "fields are required")
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	deleted, err := g.s.client.Do(ctx, g.s.client.B().Hdel().Key(grpcKey(ctx, req.Key)).Field(req.Fields...).Build()).AsInt64()
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+	return &pb.HashDeleteResponse{Deleted: deleted}, nil
+}
+
+type grpcList struct {
+	pb.UnimplementedListServer
+	s *Server
+}
+
+func (g *grpcList) Push(ctx context.Context, req *pb.ListPushRequest) (*pb.ListPushResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+	if len(req.Values) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "values are required")
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	values := make([]string, len(req.Values))
+	for i, v := range req.Values {
+		values[i] = string(v)
+	}
+
+	key := grpcKey(ctx, req.Key)
+	cmd := g.s.client.B().Rpush().Key(key).Element(values...).Build()
+	if req.Left {
+		cmd = g.s.client.B().Lpush().Key(key).Element(values...).Build()
+	}
+	length, err := g.s.client.Do(ctx, cmd).AsInt64()
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+	return &pb.ListPushResponse{Length: length}, nil
+}
+
+func (g *grpcList) Pop(ctx context.Context, req *pb.ListPopRequest) (*pb.ListPopResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	key := grpcKey(ctx, req.Key)
+	cmd := g.s.client.B().Rpop().Key(key).Build()
+	if req.Left {
+		cmd = g.s.client.B().Lpop().Key(key).Build()
+	}
+	value, err := g.s.client.Do(ctx, cmd).ToString()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			return nil, status.Error(codes.NotFound, "list is empty")
+		}
+		return nil, grpcCommandError(err)
+	}
+	return &pb.ListPopResponse{Value: []byte(value)}, nil
+}
+
+func (g *grpcList) Range(ctx context.Context, req *pb.ListRangeRequest) (*pb.ListRangeResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	items, err := g.s.client.Do(ctx, g.s.client.B().Lrange().Key(grpcKey(ctx, req.Key)).Start(req.Start).Stop(req.Stop).Build()).AsStrSlice()
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+
+	values := make([][]byte, len(items))
+	for i, item := range items {
+		values[i] = []byte(item)
+	}
+	return &pb.ListRangeResponse{Values: values}, nil
+}
+
+func (g *grpcList) Length(ctx context.Context, req *pb.ListLengthRequest) (*pb.ListLengthResponse, error) {
+	if req.Key == "" {
+		return nil, errGRPCKeyRequired
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	length, err := g.s.client.Do(ctx, g.s.client.B().Llen().Key(grpcKey(ctx, req.Key)).Build()).AsInt64()
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+	return &pb.ListLengthResponse{Length: length}, nil
+}
+
+type grpcPubSub struct {
+	pb.UnimplementedPubSubServer
+	s *Server
+}
+
+func (g *grpcPubSub) Publish(ctx context.Context, req *pb.PublishRequest) (*pb.PublishResponse, error) {
+	if req.Channel == "" {
+		return nil, status.Error(codes.InvalidArgument, "channel is required")
+	}
+	if req.Message == "" {
+		return nil, status.Error(codes.InvalidArgument, "message is required")
+	}
+
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	receivers, err := g.s.client.Do(ctx, g.s.client.B().Publish().Channel(grpcKey(ctx, req.Channel)).Message(req.Message).Build()).AsInt64()
+	if err != nil {
+		return nil, grpcCommandError(err)
+	}
+	return &pb.PublishResponse{Receivers: receivers}, nil
+}
+
+// Subscribe forwards messages published to a channel until the client cancels
+// the call. Like the SSE endpoint, messages are dropped for slow consumers.
+func (g *grpcPubSub) Subscribe(req *pb.SubscribeRequest, stream pb.PubSub_SubscribeServer) error {
+	if req.Channel == "" {
+		return status.Error(codes.InvalidArgument, "channel is required")
+	}
+
+	dedicated, release := g.s.client.Dedicate()
+	defer release()
+	defer dedicated.Close()
+
+	messages := make(chan valkey.PubSubMessage, 64)
+	wait := dedicated.SetPubSubHooks(valkey.PubSubHooks{
+		OnMessage: func(m valkey.PubSubMessage) {
+			select {
+			case messages <- m:
+			default:
+			}
+		},
+	})
+
+	ctx := stream.Context()
+	if err := dedicated.Do(ctx, dedicated.B().Subscribe().Channel(grpcKey(ctx, req.Channel)).Build()).Error(); err != nil {
+		return grpcCommandError(err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wait:
+			return status.Error(codes.Unavailable, "subscription closed")
+		case m := <-messages:
+			msg := &pb.Message{Channel: handlers.TrimNamespace(grpcNamespace(ctx), m.Channel), Message: m.Message}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"valkey-rest/auth"
+	pb "valkey-rest/valkeyrestpb"
+)
+
+// callUnary runs req through the unary interceptor as token, with any extra
+// metadata pairs, returning the error and whether the handler was reached.
+func callUnary(s *Server, method, token string, req any, md ...string) (error, bool) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(append([]string{"authorization", "Bearer " + token}, md...)...))
+	called := false
+	_, err := s.grpcUnaryInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, any) (any, error) {
+		called = true
+		return nil, nil
+	})
+	return err, called
+}
+
+func TestGRPCReservedKeysRejected(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, tc := range []struct {
+		name, method, token string
+		req                 any
+	}{
+		{"read webhook secrets", pb.Hash_GetAll_FullMethodName, testReadToken, &pb.HashGetAllRequest{Key: "valkey-rest:webhooks"}},
+		{"register a webhook", pb.Hash_Set_FullMethodName, testWriteToken, &pb.HashSetRequest{
+			Key:    "valkey-rest:webhooks",
+			Fields: map[string][]byte{"x": []byte(`{"id":"x","url":"http://169.254.169.254/"}`)},
+		}},
+		{"forge an API key", pb.KV_Set_FullMethodName, testWriteToken, &pb.SetRequest{Key: "valkey-rest:apikeys:id:x"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err, called := callUnary(s, tc.method, tc.token, tc.req)
+			if status.Code(err) != codes.PermissionDenied || called {
+				t.Errorf("%s = %v (handler called: %t), want PermissionDenied", tc.method, err, called)
+			}
+		})
+	}
+
+	if err, called := callUnary(s, pb.Hash_GetAll_FullMethodName, testReadToken, &pb.HashGetAllRequest{Key: "user:1"}); err != nil || !called {
+		t.Errorf("ordinary key = %v (handler called: %t), want it allowed", err, called)
+	}
+}
+
+func TestGRPCTokenNamespaces(t *testing.T) {
+	s := newTestServer(t, auth.TokenConfig{Name: "tenant", Token: "tenant-secret", Role: "write", Namespaces: []string{"acme"}})
+	req := &pb.GetRequest{Key: "k"}
+
+	if err, called := callUnary(s, pb.KV_Get_FullMethodName, "tenant-secret", req, "x-namespace", "acme"); err != nil || !called {
+		t.Errorf("bound namespace = %v (handler called: %t), want it allowed", err, called)
+	}
+	for _, md := range [][]string{{"x-namespace", "globex"}, nil} {
+		if err, called := callUnary(s, pb.KV_Get_FullMethodName, "tenant-secret", req, md...); status.Code(err) != codes.PermissionDenied || called {
+			t.Errorf("namespace %q = %v (handler called: %t), want PermissionDenied", md, err, called)
+		}
+	}
+}
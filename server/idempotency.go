@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+const (
+	// IdempotencyKeyHeader makes a write safe to retry: repeats with the same
+	// key get the first response replayed instead of running again.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader marks replayed responses.
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyKeyPrefix = "valkey-rest:idempotency:"
+	maxIdempotencyKey    = 255
+	// idempotencyLockTTL bounds how long a request holds its key before it
+	// has a response, long enough for an import to finish.
+	idempotencyLockTTL = 6 * time.Minute
+	// maxIdempotentBody is the largest response that is recorded. Bigger
+	// responses release the key, so a retry runs the request again.
+	maxIdempotentBody = 1 << 20
+)
+
+// idempotencyHeaders are the response headers replayed with the body.
+var idempotencyHeaders = []string{"Content-Type", "Content-Disposition", "ETag", "Location"}
+
+// idempotencyRecord is stored under an idempotency key: first marking the
+// request as in progress, then holding its response.
+type idempotencyRecord struct {
+	Fingerprint string            `json:"fingerprint"`
+	Pending     bool              `json:"pending,omitempty"`
+	Status      int               `json:"status,omitempty"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// idempotencyRecorder passes a response through while keeping a copy.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > maxIdempotentBody {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// idempotencyMiddleware honours the Idempotency-Key header. The first request
+// with a key runs and its response is kept for the idempotency TTL; repeats
+// with the same key and the same request get that response back. Keys are
+// scoped to the caller, and reusing one for a different request is an error.
+// Server errors aren't kept, so the request can be retried.
+func (s *Server) idempotencyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		// The body is buffered to fingerprint it, so uploads that are
+		// otherwise streamed, such as imports, are held to the body limit
+		if r.ContentLength > s.maxBodyBytes {
+			handlers.WriteError(w, http.StatusRequestEntityTooLarge, handlers.CodePayloadTooLarge, fmt.Sprintf("request bodies sent with an Idempotency-Key may be at most %d bytes", s.maxBodyBytes))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+		body, ok := handlers.ReadRawBody(w, r)
+		if !ok {
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.New()
+		io.WriteString(sum, r.Method+" "+handlers.RequestNamespace(r)+" "+r.URL.RequestURI()+"\n")
+		sum.Write(body)
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+		subject := "anonymous"
+		if p := auth.FromContext(r.Context()); p != nil {
+			subject = p.ID
+		}
+		storeKey := idempotencyKeyPrefix + subject + ":" + key
+
+		ctx, cancel := commandContext(r.Context())
+		defer cancel()
+
+		pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, Pending: true})
+		err := s.client.Do(ctx, s.client.B().Set().Key(storeKey).Value(string(pending)).Nx().Px(idempotencyLockTTL).Build()).Error()
+		if err != nil && !valkey.IsValkeyNil(err) {
+			log.Printf("Failed to reserve idempotency key: %v", err)
+			handlers.WriteCommandError(w, err)
+			return
+		}
+		if valkey.IsValkeyNil(err) {
+			// The key is taken: replay the response it holds
+			s.replayIdempotent(ctx, w, storeKey, fingerprint)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		next(rec, r)
+		s.recordIdempotent(r, storeKey, fingerprint, rec)
+	}
+}
+
+// replayIdempotent writes the response recorded under storeKey.
+func (s *Server) replayIdempotent(ctx context.Context, w http.ResponseWriter, storeKey, fingerprint string) {
+	data, err := s.client.Do(ctx, s.client.B().Get().Key(storeKey).Build()).AsBytes()
+	var record idempotencyRecord
+	if err == nil {
+		err = json.Unmarshal(data, &record)
+	}
+	if err != nil {
+		// Nil means the first request just released the key after failing
+		if valkey.IsValkeyNil(err) {
+			handlers.WriteError(w, http.StatusConflict, handlers.CodeConflict, "a request with this Idempotency-Key failed; retry it")
+			return
+		}
+		log.Printf("Failed to read idempotency key: %v", err)
+		handlers.WriteCommandError(w, err)
+		return
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		handlers.WriteError(w, http.StatusUnprocessableEntity, handlers.CodeBadRequest, "Idempotency-Key was already used for a different request")
+	case record.Pending:
+		w.Header().Set("Retry-After", "1")
+		handlers.WriteError(w, http.StatusConflict, handlers.CodeConflict, "a request with this Idempotency-Key is still in progress")
+	default:
+		for name, value := range record.Header {
+			w.Header().Set(name, value)
+		}
+		w.Header().Set(idempotencyReplayedHeader, "true")
+		w.WriteHeader(record.Status)
+		w.Write(record.Body)
+	}
+}
+
+// recordIdempotent stores the response of the first request with a key, or
+// releases the key if the response can't be replayed.
+func (s *Server) recordIdempotent(r *http.Request, storeKey, fingerprint string, rec *idempotencyRecorder) {
+	// The client may be gone once the response is written
+	ctx, cancel := commandContext(context.WithoutCancel(r.Context()))
+	defer cancel()
+
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if rec.status >= 500 || rec.overflow {
+		if err := s.client.Do(ctx, s.client.B().Del().Key(storeKey).Build()).Error(); err != nil {
+			log.Printf("Failed to release idempotency key: %v", err)
+		}
+		return
+	}
+
+	record := idempotencyRecord{
+		Fingerprint: fingerprint,
+		Status:      rec.status,
+		Header:      make(map[string]string),
+		Body:        rec.body.Bytes(),
+	}
+	for _, name := range idempotencyHeaders {
+		if v := rec.Header().Get(name); v != "" {
+			record.Header[name] = v
+		}
+	}
+	data, _ := json.Marshal(record)
+	if err := s.client.Do(ctx, s.client.B().Set().Key(storeKey).Value(string(data)).Px(s.idempotencyTTL).Build()).Error(); err != nil {
+		log.Printf("Failed to record idempotent response: %v", err)
+	}
+}
+
+// idempotentRoute reports whether requests to pattern honour Idempotency-Key.
+func idempotentRoute(pattern string) bool {
+	return strings.HasPrefix(pattern, "POST ") || strings.HasPrefix(pattern, "DELETE ")
+}
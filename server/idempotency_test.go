@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyBodyCap(t *testing.T) {
+	s := newTestServer(t)
+	s.maxBodyBytes = 16
+	handler := s.idempotencyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler ran with an oversized body")
+	})
+
+	for _, tc := range []struct {
+		name          string
+		contentLength bool
+	}{
+		{"declared length", true},
+		{"chunked", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/import", strings.NewReader(strings.Repeat("x", 64)))
+			if !tc.contentLength {
+				req.ContentLength = -1
+			}
+			req.Header.Set(IdempotencyKeyHeader, "k")
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("status = %d, want 413: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"valkey-rest/config"
+	"valkey-rest/handlers"
+)
+
+// ipFilter holds the address lists checked before authentication.
+type ipFilter struct {
+	allow      []netip.Prefix // Every route; empty allows all
+	deny       []netip.Prefix // Every route; wins over allow
+	adminAllow []netip.Prefix // Admin routes only; empty allows all
+	trusted    []netip.Prefix // Proxies whose X-Forwarded-For is believed
+}
+
+// newIPFilter parses the IP lists of cfg. Lists are comma-separated CIDRs or
+// single addresses.
+func newIPFilter(cfg config.Config) (*ipFilter, error) {
+	var f ipFilter
+	var err error
+	if f.allow, err = parsePrefixes(cfg.IPAllow); err != nil {
+		return nil, fmt.Errorf("IP_ALLOW: %w", err)
+	}
+	if f.deny, err = parsePrefixes(cfg.IPDeny); err != nil {
+		return nil, fmt.Errorf("IP_DENY: %w", err)
+	}
+	if f.adminAllow, err = parsePrefixes(cfg.AdminIPAllow); err != nil {
+		return nil, fmt.Errorf("ADMIN_IP_ALLOW: %w", err)
+	}
+	if f.trusted, err = parsePrefixes(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	return &f, nil
+}
+
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range config.SplitList(list) {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether addr passes the allow and deny lists.
+func (f *ipFilter) allowed(addr netip.Addr) bool {
+	if containsAddr(f.deny, addr) {
+		return false
+	}
+	return len(f.allow) == 0 || containsAddr(f.allow, addr)
+}
+
+// clientAddr resolves the client address of a connection from peer. When
+// the peer is a trusted proxy, X-Forwarded-For is walked from the right,
+// skipping trusted proxies, and the first other address is the client.
+func (f *ipFilter) clientAddr(peer netip.Addr, forwardedFor []string) netip.Addr {
+	addr := peer
+	if !containsAddr(f.trusted, addr) {
+		return addr
+	}
+	hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed entry can't be trusted, nor anything left of it
+			break
+		}
+		addr = hop.Unmap()
+		if !containsAddr(f.trusted, addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// parseRemoteAddr parses an address with or without a port.
+func parseRemoteAddr(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr.Unmap(), err == nil
+}
+
+// clientIPMiddleware records the client address for everything after it,
+// resolving X-Forwarded-For when the request comes from a trusted proxy.
+func (s *Server) clientIPMiddleware(next http.Handler) http.Handler {
+	if len(s.ipFilter.trusted) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := parseRemoteAddr(r.RemoteAddr); ok {
+			addr = s.ipFilter.clientAddr(addr, r.Header.Values("X-Forwarded-For"))
+			r = r.WithContext(context.WithValue(r.Context(), clientIPKey, addr.String()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ipFilterMiddleware rejects clients outside IP_ALLOW or inside IP_DENY.
+func (s *Server) ipFilterMiddleware(next http.Handler) http.Handler {
+	if len(s.ipFilter.allow) == 0 && len(s.ipFilter.deny) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := parseRemoteAddr(remoteIP(r)); !ok || !s.ipFilter.allowed(addr) {
+			writeIPDenied(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminIPMiddleware restricts an admin route to ADMIN_IP_ALLOW.
+func (s *Server) adminIPMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := parseRemoteAddr(remoteIP(r)); !ok || !containsAddr(s.ipFilter.adminAllow, addr) {
+			writeIPDenied(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeIPDenied(w http.ResponseWriter) {
+	handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, "access from this address is not allowed")
+}
+
+// grpcAllowAddr applies IP_ALLOW and IP_DENY to a gRPC peer. gRPC clients
+// connect directly, so X-Forwarded-For isn't consulted; admin-only RPCs
+// don't exist, so ADMIN_IP_ALLOW doesn't apply.
+func (s *Server) grpcAllowAddr(ctx context.Context) error {
+	if len(s.ipFilter.allow) == 0 && len(s.ipFilter.deny) == 0 {
+		return nil
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr, ok := parseRemoteAddr(p.Addr.String()); ok && s.ipFilter.allowed(addr) {
+			return nil
+		}
+	}
+	return status.Error(codes.PermissionDenied, "access from this address is not allowed")
+}
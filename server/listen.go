@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"valkey-rest/handlers"
+)
+
+// socketMode lets the socket owner and its group, such as a sidecar sharing
+// the group, connect to a Unix socket listener.
+const socketMode = 0o660
+
+// IsUnixAddress reports whether a listen address names a Unix socket.
+func IsUnixAddress(addr string) bool {
+	return strings.HasPrefix(addr, "unix:")
+}
+
+// Listen opens a listener for a listen address: host:port for TCP or
+// unix:/path for a Unix domain socket. A socket left behind by a previous run
+// is removed first; the socket is removed again when the listener closes.
+func Listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return lis, nil
+}
+
+// AdminHandler returns the HTTP handler for admin listeners. It serves every
+// route; when admin listeners are configured, Handler refuses admin routes.
+func (s *Server) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminListenerKey, true)))
+	})
+}
+
+// adminListenerMiddleware refuses an admin route on listeners other than
+// the admin listeners.
+func (s *Server) adminListenerMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if admin, _ := r.Context().Value(adminListenerKey).(bool); !admin {
+			handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, "admin endpoints are only served on the admin listener")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"valkey-rest/handlers"
+)
+
+// requestInfo is shared between the logging middleware and inner handlers so
+// details only known after authentication end up on the request log line.
+type requestInfo struct {
+	subject string
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey).(*requestInfo)
+	return info
+}
+
+// setRequestSubject records who made the request, if request logging is active.
+func setRequestSubject(r *http.Request, subject string) {
+	if info := requestInfoFrom(r.Context()); info != nil {
+		info.subject = subject
+	}
+}
+
+// logLevel is shared by every logger SetupLogging installs, so reloads can
+// change it in place.
+var logLevel slog.LevelVar
+
+// parseLogLevel maps a LOG_LEVEL value to a slog level, defaulting to info.
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// SetupLogging installs the default slog logger. Pretty mode writes
+// human-readable text for local development; otherwise one JSON object is
+// written per line. Output from the standard log package goes through it too.
+func SetupLogging(level, format string) {
+	logLevel.Set(parseLogLevel(level))
+
+	opts := &slog.HandlerOptions{Level: &logLevel}
+	var handler slog.Handler
+	if strings.ToLower(format) == "pretty" {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// loggingMiddleware emits one log line per request once it has completed.
+func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{subject: "anonymous"}
+		rec := newStatusRecorder(w)
+
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if rec.status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+
+		slog.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", r.Pattern),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote_ip", remoteIP(r)),
+			slog.String("subject", info.subject),
+			slog.String("namespace", handlers.RequestNamespace(r)),
+		)
+	})
+}
+
+// remoteIP returns the client address without the port, as resolved from
+// X-Forwarded-For when the request came through a trusted proxy.
+func remoteIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
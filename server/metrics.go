@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valkey-io/valkey-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "valkey_rest_http_requests_total",
+		Help: "Total number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "valkey_rest_http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	valkeyCommandErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "valkey_rest_valkey_command_errors_total",
+		Help: "Total number of failed Valkey commands by command name.",
+	}, []string{"command"})
+
+	cacheLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "valkey_rest_cache_lookups_total",
+		Help: "Total number of GET cache lookups by result: hit, stale, coalesced or miss.",
+	}, []string{"result"})
+
+	migrationResultsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "valkey_rest_migration_results_total",
+		Help: "Total number of operations on the migration secondary by operation and result.",
+	}, []string{"op", "result"})
+
+	auditWriteErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "valkey_rest_audit_write_errors_total",
+		Help: "Total number of audit entries that could not be written to the audit sink.",
+	})
+
+	readOnlyMode = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "valkey_rest_read_only",
+		Help: "1 while read-only mode refuses mutating endpoints, 0 otherwise.",
+	})
+
+	circuitBreakerOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "valkey_rest_circuit_breaker_open",
+		Help: "1 while the Valkey circuit breaker is rejecting requests, 0 otherwise.",
+	})
+
+	inFlightRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "valkey_rest_in_flight_requests",
+		Help: "Requests holding a slot of the global concurrency limit.",
+	})
+
+	concurrencyRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "valkey_rest_concurrency_rejected_total",
+		Help: "Total number of requests refused by the concurrency limits, by limit: global or token.",
+	}, []string{"limit"})
+)
+
+// metricsMiddleware records request counts and latency per matched route.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		// The mux fills in the matched pattern, which keeps label cardinality bounded
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		httpRequestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	promhttp.Handler().ServeHTTP(w, r)
+}
+
+// instrumentedClient traces every command, counts the ones that fail and
+// records its latency for /debug/vars. Key misses are not failures. Every
+// outcome is also fed to the circuit breaker.
+type instrumentedClient struct {
+	valkey.Client
+	breaker *CircuitBreaker
+}
+
+func (c instrumentedClient) Do(ctx context.Context, cmd valkey.Completed) valkey.ValkeyResult {
+	// Commands are recycled once executed, so read the name up front
+	name := commandName(cmd)
+	ctx, span := startCommandSpan(ctx, name)
+
+	start := time.Now()
+	resp := c.Client.Do(ctx, cmd)
+	err := resp.Error()
+	c.breaker.Record(err)
+	if err != nil && !valkey.IsValkeyNil(err) {
+		valkeyCommandErrorsTotal.WithLabelValues(name).Inc()
+	} else {
+		err = nil
+	}
+	recordCommand(name, time.Since(start), err != nil)
+	endCommandSpan(span, err)
+	return resp
+}
+
+func (c instrumentedClient) DoMulti(ctx context.Context, multi ...valkey.Completed) []valkey.ValkeyResult {
+	names := make([]string, len(multi))
+	spans := make([]trace.Span, len(multi))
+	for i, cmd := range multi {
+		names[i] = commandName(cmd)
+		_, spans[i] = startCommandSpan(ctx, names[i])
+	}
+
+	start := time.Now()
+	resps := c.Client.DoMulti(ctx, multi...)
+	// Pipelined commands share the round trip, so each is charged all of it
+	latency := time.Since(start)
+	for i, resp := range resps {
+		err := resp.Error()
+		c.breaker.Record(err)
+		if err != nil && !valkey.IsValkeyNil(err) {
+			valkeyCommandErrorsTotal.WithLabelValues(names[i]).Inc()
+		} else {
+			err = nil
+		}
+		recordCommand(names[i], latency, err != nil)
+		endCommandSpan(spans[i], err)
+	}
+	return resps
+}
+
+func commandName(cmd valkey.Completed) string {
+	if parts := cmd.Commands(); len(parts) > 0 {
+		return strings.ToUpper(parts[0])
+	}
+	return "unknown"
+}
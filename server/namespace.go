@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+// errNamespaceDenied is reported when a principal bound to namespaces uses
+// another one, or none.
+const errNamespaceDenied = "namespace not allowed for this token"
+
+const namespacePrefix = "/ns/"
+
+// namespaceMiddleware accepts the namespace either as the X-Namespace header or
+// as a /ns/{namespace} path prefix, normalising the latter into the header so
+// the rest of the stack only has to look in one place.
+func (s *Server) namespaceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, namespacePrefix) {
+			rest := strings.TrimPrefix(r.URL.Path, namespacePrefix)
+			ns, path, _ := strings.Cut(rest, "/")
+
+			r = r.Clone(r.Context())
+			r.Header.Set(handlers.NamespaceHeader, ns)
+			r.URL.Path = "/" + path
+			r.URL.RawPath = ""
+		}
+
+		if _, ok := r.Header[http.CanonicalHeaderKey(handlers.NamespaceHeader)]; ok {
+			if !handlers.ValidNamespace(handlers.RequestNamespace(r)) {
+				handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, "invalid namespace")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowNamespace is the part of namespace checking that needs the principal,
+// so authMiddleware calls it once the token is known. It writes a 403
+// response and returns false unless p may use the request's namespace.
+func allowNamespace(w http.ResponseWriter, r *http.Request, p *auth.Principal) bool {
+	if !p.CanUseNamespace(handlers.RequestNamespace(r)) {
+		handlers.WriteError(w, http.StatusForbidden, handlers.CodeForbidden, errNamespaceDenied)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,480 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+// routeInfo is a registered route. Routes are recorded as they are added to
+// the mux so the OpenAPI document can't drift from what is actually served.
+type routeInfo struct {
+	pattern    string
+	role       auth.Role // Zero for public routes
+	idempotent bool      // Honours Idempotency-Key
+	db         bool      // Honours ?db=
+}
+
+// route registers a handler behind authMiddleware.
+func (s *Server) route(pattern string, role auth.Role, handler http.HandlerFunc) {
+	// Idempotency keys are kept in Valkey
+	idempotent := s.client != nil && idempotentRoute(pattern)
+	db := dbRoute(pattern)
+	s.routes = append(s.routes, routeInfo{pattern: pattern, role: role, idempotent: idempotent, db: db})
+	handler = s.dbMiddleware(db, handler)
+	if idempotent {
+		handler = s.idempotencyMiddleware(handler)
+	}
+	handler = s.readOnlyMiddleware(mutatingRoute(pattern, role), handler)
+	if s.usage != nil {
+		handler = s.usageMiddleware(handler)
+	}
+	if strings.Contains(pattern, "{key}") {
+		handler = reservedKeyMiddleware(handler)
+	}
+	handler = s.authMiddleware(role, handler)
+	if role == auth.RoleAdmin && len(s.ipFilter.adminAllow) > 0 {
+		handler = s.adminIPMiddleware(handler)
+	}
+	if role == auth.RoleAdmin && s.adminListeners {
+		handler = s.adminListenerMiddleware(handler)
+	}
+	if s.audit != nil && auditedRoute(pattern, role) {
+		handler = s.auditMiddleware(handler)
+	}
+	s.router.HandleFunc(pattern, handler)
+}
+
+// publicRoute registers a handler that needs no authentication.
+func (s *Server) publicRoute(pattern string, handler http.HandlerFunc) {
+	s.routes = append(s.routes, routeInfo{pattern: pattern})
+	s.router.HandleFunc(pattern, handler)
+}
+
+// routeDoc adds what can't be derived from a route's registration. Request
+// and Response are zero values of the body types, whose schemas are
+// generated from their JSON tags.
+type routeDoc struct {
+	Summary     string
+	Query       []string
+	Request     any
+	RequestType string // Defaults to application/json
+	Status      int    // Success status, defaults to 200
+	Response    any
+	ContentType string // Response content type, defaults to application/json
+}
+
+var routeDocs = map[string]routeDoc{
+	"GET /health":       {Summary: "Check the Valkey connection"},
+	"GET /livez":        {Summary: "Liveness probe; succeeds while the process is up", Response: ProbeResponse{}},
+	"GET /readyz":       {Summary: "Readiness probe; checks Valkey within the probe timeout", Response: ProbeResponse{}},
+	"GET /metrics":      {Summary: "Prometheus metrics", ContentType: "text/plain"},
+	"GET /openapi.json": {Summary: "This OpenAPI document"},
+	"GET /docs":         {Summary: "Swagger UI for this document", ContentType: "text/html"},
+
+	"GET /keys/{key}":         {Summary: "Get a value", Query: []string{"include", "fields"}, Response: handlers.GetResponse{}},
+	"HEAD /keys/{key}":        {Summary: "Check whether a key exists without reading it"},
+	"GET /keys/{key}/exists":  {Summary: "Check whether a key exists"},
+	"GET /keys/{key}/meta":    {Summary: "Get a key's type, TTL and encoding", Response: handlers.KeyMeta{}},
+	"POST /keys/{key}":        {Summary: "Set a value", Query: []string{"expiration", "versioned"}, Request: handlers.SetRequest{}, Status: http.StatusCreated},
+	"POST /keys/{key}/rename": {Summary: "Rename a key", Request: handlers.MoveKeyRequest{}},
+	"POST /keys/{key}/copy":   {Summary: "Copy a key", Request: handlers.MoveKeyRequest{}},
+	"DELETE /keys/{key}":      {Summary: "Delete a key"},
+	"GET /keys":               {Summary: "List keys one SCAN page at a time, or stream them all as NDJSON", Query: []string{"pattern", "limit", "cursor", "include", "fields"}},
+	"DELETE /keys":            {Summary: "Delete keys matching a pattern", Query: []string{"pattern", "dry_run"}},
+
+	"GET /keys/{key}/range":     {Summary: "Read a byte range of a string value", Query: []string{"start", "end"}, Response: handlers.RangeResponse{}},
+	"POST /keys/{key}/append":   {Summary: "Append to a string value", Request: handlers.AppendRequest{}},
+	"POST /keys/{key}/setrange": {Summary: "Overwrite part of a string value at an offset", Query: []string{"offset"}, Request: handlers.SetRangeRequest{}},
+
+	"GET /keys/{key}/schedule":    {Summary: "Get the job scheduled for a key", Response: handlers.ScheduledJob{}},
+	"POST /keys/{key}/schedule":   {Summary: "Schedule a key to be deleted or moved", Request: handlers.ScheduleRequest{}, Status: http.StatusCreated, Response: handlers.ScheduledJob{}},
+	"DELETE /keys/{key}/schedule": {Summary: "Cancel the job scheduled for a key"},
+
+	"GET /keys/{key}/versions":                    {Summary: "List the stored versions of a key", Response: handlers.KeyVersionsResponse{}},
+	"POST /keys/{key}/versions/{version}/restore": {Summary: "Set a key back to one of its versions"},
+
+	"GET /export":  {Summary: "Stream keys as NDJSON DUMP records", Query: []string{"pattern"}, Response: handlers.ExportRecord{}, ContentType: "application/x-ndjson"},
+	"POST /import": {Summary: "Restore keys from an export or CSV", Query: []string{"format", "replace"}, Request: handlers.ExportRecord{}, RequestType: "application/x-ndjson"},
+
+	"POST /publish/{channel}":  {Summary: "Publish a message", Request: handlers.PublishRequest{}},
+	"GET /subscribe/{channel}": {Summary: "Subscribe to a channel as Server-Sent Events", Response: handlers.SubscribeMessage{}, ContentType: "text/event-stream"},
+
+	"POST /lists/{key}/bpop": {Summary: "Pop a list element, waiting for one if the list is empty", Query: []string{"timeout", "side"}, Response: handlers.PopResponse{}},
+
+	"POST /streams/{key}":                    {Summary: "Append a stream entry", Request: handlers.StreamAddRequest{}, Status: http.StatusCreated},
+	"GET /streams/{key}":                     {Summary: "Read a range of stream entries, or stream all of them as NDJSON", Query: []string{"start", "end", "count"}},
+	"GET /streams/{key}/read":                {Summary: "Read new stream entries, optionally blocking", Query: []string{"id", "count", "block"}},
+	"POST /streams/{key}/groups":             {Summary: "Create a consumer group", Request: handlers.StreamGroupRequest{}, Status: http.StatusCreated},
+	"GET /streams/{key}/groups/{group}":      {Summary: "Read as a group consumer", Query: []string{"consumer", "id", "count", "block"}},
+	"POST /streams/{key}/groups/{group}/ack": {Summary: "Acknowledge stream entries", Request: handlers.StreamAckRequest{}},
+
+	"GET /json/{key}":    {Summary: "Get a JSON document", Query: []string{"path"}},
+	"POST /json/{key}":   {Summary: "Set a JSON document", Query: []string{"path", "condition"}},
+	"DELETE /json/{key}": {Summary: "Delete a JSON document or path", Query: []string{"path"}},
+
+	"POST /hll/{key}":       {Summary: "Add elements to a HyperLogLog", Request: handlers.HLLAddRequest{}},
+	"GET /hll/{key}":        {Summary: "Count distinct elements", Query: []string{"union"}},
+	"POST /hll/{key}/merge": {Summary: "Merge HyperLogLogs", Request: handlers.HLLMergeRequest{}},
+
+	"GET /bitmaps/{key}/bits/{offset}":  {Summary: "Get a bit"},
+	"POST /bitmaps/{key}/bits/{offset}": {Summary: "Set a bit", Request: handlers.SetBitRequest{}},
+	"GET /bitmaps/{key}/count":          {Summary: "Count set bits", Query: []string{"start", "end", "unit"}},
+	"POST /bitmaps/{key}/op":            {Summary: "Combine bitmaps", Request: handlers.BitOpRequest{}},
+
+	"POST /geo/{key}":       {Summary: "Add geospatial members", Request: handlers.GeoAddRequest{}},
+	"GET /geo/{key}/search": {Summary: "Search members by radius or box", Query: []string{"member", "longitude", "latitude", "radius", "width", "height", "unit", "count", "sort"}},
+
+	"POST /sessions":            {Summary: "Create a session with a random ID", Request: handlers.SessionRequest{}, Status: http.StatusCreated, Response: handlers.SessionResponse{}},
+	"GET /sessions/{id}":        {Summary: "Read a session and renew its TTL", Response: handlers.SessionResponse{}},
+	"POST /sessions/{id}":       {Summary: "Set or remove session fields and renew its TTL", Request: handlers.SessionRequest{}, Response: handlers.SessionResponse{}},
+	"POST /sessions/{id}/touch": {Summary: "Renew a session's TTL"},
+	"DELETE /sessions/{id}":     {Summary: "Destroy a session"},
+
+	"GET /leaderboards/{name}":                         {Summary: "Read a page of a leaderboard from the top, or stream it as NDJSON", Query: []string{"offset", "limit", "order"}, Response: handlers.LeaderboardPage{}},
+	"DELETE /leaderboards/{name}":                      {Summary: "Delete a leaderboard"},
+	"POST /leaderboards/{name}/scores":                 {Summary: "Submit a member's score", Query: []string{"order"}, Request: handlers.ScoreRequest{}},
+	"GET /leaderboards/{name}/members/{member}":        {Summary: "Get a member's rank and score", Query: []string{"order"}},
+	"GET /leaderboards/{name}/members/{member}/around": {Summary: "Read the entries around a member", Query: []string{"radius", "order"}, Response: handlers.LeaderboardPage{}},
+	"DELETE /leaderboards/{name}/members/{member}":     {Summary: "Remove a member from a leaderboard"},
+
+	"GET /flags/{name}/evaluate": {Summary: "Evaluate a feature flag for a user", Query: []string{"user"}, Response: handlers.FlagEvaluation{}},
+	"GET /flags":                 {Summary: "List feature flags"},
+	"GET /flags/{name}":          {Summary: "Get a feature flag", Response: handlers.Flag{}},
+	"PUT /flags/{name}":          {Summary: "Create or replace a feature flag", Request: handlers.FlagRequest{}, Response: handlers.Flag{}},
+	"DELETE /flags/{name}":       {Summary: "Delete a feature flag"},
+
+	"POST /ratelimit/{bucket}": {Summary: "Take from a token bucket or sliding window rate limit", Request: handlers.RateLimitRequest{}, Response: handlers.RateLimitResponse{}},
+
+	"GET /locks/{name}":          {Summary: "Inspect a lock"},
+	"POST /locks/{name}":         {Summary: "Acquire a lock", Request: handlers.LockRequest{}, Response: handlers.LockResponse{}},
+	"POST /locks/{name}/renew":   {Summary: "Extend a held lock", Request: handlers.LockRequest{}},
+	"DELETE /locks/{name}":       {Summary: "Release a lock"},
+	"GET /scripts":               {Summary: "List registered scripts"},
+	"POST /scripts/{name}":       {Summary: "Run a registered script", Request: handlers.ScriptRequest{}},
+	"POST /admin/apikeys":        {Summary: "Create an API key", Request: auth.CreateAPIKeyRequest{}, Status: http.StatusCreated, Response: auth.APIKey{}},
+	"GET /admin/apikeys":         {Summary: "List API keys"},
+	"DELETE /admin/apikeys/{id}": {Summary: "Revoke an API key"},
+
+	"GET /admin/info":               {Summary: "Server INFO", Query: []string{"section"}},
+	"GET /admin/dbsize":             {Summary: "Number of keys"},
+	"POST /admin/flush":             {Summary: "Flush the database or keys matching a pattern", Request: handlers.FlushRequest{}},
+	"GET /admin/slowlog":            {Summary: "Recent slow commands", Query: []string{"count"}},
+	"GET /admin/latency":            {Summary: "Latency monitor events", Query: []string{"event"}},
+	"GET /admin/clients":            {Summary: "Client connections from CLIENT LIST", Query: []string{"type"}},
+	"POST /admin/clients/{id}/kill": {Summary: "Close a client connection", Query: []string{"node"}},
+	"GET /admin/config/{param}":     {Summary: "Configuration parameters matching a glob"},
+	"POST /admin/config":            {Summary: "Change configuration parameters", Request: handlers.ConfigSetRequest{}},
+	"POST /admin/reload":            {Summary: "Reload tokens, rate limits, usage quotas, log level and webhooks"},
+	"GET /admin/read-only":          {Summary: "Whether read-only mode is on", Response: ReadOnlyStatus{}},
+	"POST /admin/read-only":         {Summary: "Turn read-only mode on or off", Request: ReadOnlyRequest{}, Response: ReadOnlyStatus{}},
+	"GET /admin/audit":              {Summary: "Recent audit log entries, newest first", Query: []string{"count", "subject", "key", "since"}, Response: AuditResponse{}},
+	"GET /admin/usage":              {Summary: "Each token's requests and bytes in a month", Query: []string{"month", "principal"}, Response: UsageResponse{}},
+
+	"POST /admin/webhooks":        {Summary: "Register a keyspace notification webhook", Request: handlers.CreateWebhookRequest{}, Status: http.StatusCreated, Response: handlers.Webhook{}},
+	"GET /admin/webhooks":         {Summary: "List webhooks"},
+	"DELETE /admin/webhooks/{id}": {Summary: "Delete a webhook"},
+
+	"POST /command":      {Summary: "Run an arbitrary command", Request: handlers.CommandRequest{}},
+	"POST /transactions": {Summary: "Run commands atomically with MULTI/EXEC", Request: handlers.TransactionRequest{}},
+	"GET /ws":            {Summary: "WebSocket command gateway"},
+}
+
+var pathParamRe = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// openAPIBuilder collects named struct schemas into components as it goes.
+type openAPIBuilder struct {
+	schemas map[string]any
+}
+
+// buildOpenAPI generates the OpenAPI 3 document for the registered routes.
+func buildOpenAPI(routes []routeInfo) map[string]any {
+	b := &openAPIBuilder{schemas: make(map[string]any)}
+	errorSchema := b.schemaFor(reflect.TypeOf(handlers.ErrorResponse{}))
+
+	paths := make(map[string]map[string]any)
+	for _, rt := range routes {
+		method, path, _ := strings.Cut(rt.pattern, " ")
+		doc := routeDocs[rt.pattern]
+
+		var params []any
+		for _, m := range pathParamRe.FindAllStringSubmatch(path, -1) {
+			params = append(params, map[string]any{
+				"name": m[1], "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, name := range doc.Query {
+			params = append(params, map[string]any{
+				"name": name, "in": "query",
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+
+		op := map[string]any{
+			"summary":     doc.Summary,
+			"operationId": operationID(method, path),
+			"tags":        []string{routeTag(path)},
+		}
+
+		status := doc.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		if method != http.MethodHead {
+			contentType := doc.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			schema := map[string]any{}
+			if doc.Response != nil {
+				schema = b.schemaFor(reflect.TypeOf(doc.Response))
+			} else if contentType == "application/json" {
+				schema = map[string]any{"type": "object"}
+			}
+			success["content"] = map[string]any{contentType: map[string]any{"schema": schema}}
+		}
+		responses := map[string]any{
+			fmt.Sprint(status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{handlers.ProblemContentType: map[string]any{"schema": errorSchema}},
+			},
+		}
+		op["responses"] = responses
+
+		if doc.Request != nil {
+			requestType := doc.RequestType
+			if requestType == "" {
+				requestType = "application/json"
+			}
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{requestType: map[string]any{"schema": b.schemaFor(reflect.TypeOf(doc.Request))}},
+			}
+		}
+
+		if rt.role != 0 {
+			params = append(params,
+				map[string]any{"$ref": "#/components/parameters/Namespace"},
+				map[string]any{"$ref": "#/components/parameters/Timeout"},
+			)
+			if rt.idempotent {
+				params = append(params, map[string]any{"$ref": "#/components/parameters/IdempotencyKey"})
+			}
+			if rt.db {
+				params = append(params, map[string]any{"$ref": "#/components/parameters/DB"})
+			}
+			op["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+			op["description"] = fmt.Sprintf("Requires the %s role.", rt.role)
+		} else {
+			op["security"] = []any{}
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		// OpenAPI paths have no method prefix and no {name...} wildcards
+		path = pathParamRe.ReplaceAllString(path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Valkey REST API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+			"parameters": map[string]any{
+				"Namespace": map[string]any{
+					"name": handlers.NamespaceHeader, "in": "header",
+					"description": "Tenant namespace prefixed to every key",
+					"schema":      map[string]any{"type": "string"},
+				},
+				"IdempotencyKey": map[string]any{
+					"name": IdempotencyKeyHeader, "in": "header",
+					"description": "Unique key making the request safe to retry; repeats get the first response replayed",
+					"schema":      map[string]any{"type": "string", "maxLength": maxIdempotencyKey},
+				},
+				"DB": map[string]any{
+					"name": DBParam, "in": "query",
+					"description": "Logical database to run the request against; VALKEY_DB or one listed in VALKEY_DB_ALLOW",
+					"schema":      map[string]any{"type": "integer", "minimum": 0},
+				},
+				"Timeout": map[string]any{
+					"name": handlers.TimeoutHeader, "in": "header",
+					"description": "Timeout for the Valkey commands of the request in milliseconds, capped by the server",
+					"schema":      map[string]any{"type": "integer", "minimum": 1},
+				},
+			},
+		},
+		"security": []any{map[string]any{"bearerAuth": []string{}}},
+	}
+}
+
+// operationID derives a stable identifier such as "getKeysKey" from a route.
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '_' || r == '-'
+	}) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+// routeTag groups operations by their first path segment.
+func routeTag(path string) string {
+	first, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if first == "admin" {
+		return "admin"
+	}
+	return strings.TrimSuffix(first, ".json")
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns the JSON schema of t as encoding/json would marshal it.
+// Named structs are added to components and referenced.
+func (b *openAPIBuilder) schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			b.schemas[t.Name()] = map[string]any{}
+			b.schemas[t.Name()] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+func (b *openAPIBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	b.addFields(t, properties, &required)
+	sort.Strings(required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the JSON fields of a struct, flattening embedded structs
+// the way encoding/json does.
+func (b *openAPIBuilder) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			b.addFields(ft, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = b.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document generated at startup.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.openAPI)
+}
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at /openapi.json.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Valkey REST API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, swaggerUIPage)
+}
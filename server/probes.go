@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ProbeResponse is the body of /livez and /readyz. Checks maps each readiness
+// check to "ok" or the reason it failed.
+type ProbeResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// handleLivez reports that the process is up and serving. It never touches
+// Valkey, so an outage doesn't get the pod restarted.
+func (s *Server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(ProbeResponse{Status: "alive"})
+}
+
+// handleReadyz reports whether requests can be served: the server must not
+// be shutting down, the backend must answer PING within the readiness
+// timeout and, when enabled, no Valkey node may be loading its dataset or be
+// a replica cut off from its primary.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.readyTimeout)
+	defer cancel()
+
+	checks := make(map[string]string)
+	ready := true
+	record := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	// Don't wait on Valkey while the circuit breaker already knows it is down
+	// or the server is shutting down anyway
+	if s.drain.draining.Load() {
+		record("shutdown", errShuttingDown)
+	} else if s.breaker.Open() {
+		record("circuit_breaker", errCircuitOpen)
+	} else {
+		record("ping", s.store.Ping(ctx))
+	}
+	if ready && s.client != nil && (s.readyCheckLoading || s.readyCheckReplication) {
+		loading, replication := s.checkNodes(ctx)
+		if s.readyCheckLoading {
+			record("loading", loading)
+		}
+		if s.readyCheckReplication {
+			record("replication", replication)
+		}
+	}
+
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ProbeResponse{Status: "not ready", Checks: checks})
+		return
+	}
+	json.NewEncoder(w).Encode(ProbeResponse{Status: "ready", Checks: checks})
+}
+
+// checkNodes reads INFO from every node, reporting the first node still
+// loading its dataset and the first replica whose link to its primary is
+// down.
+func (s *Server) checkNodes(ctx context.Context) (loading, replication error) {
+	nodes := s.client.Nodes()
+	addrs := make([]string, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		node := nodes[addr]
+		info, err := node.Do(ctx, node.B().Info().Section("persistence", "replication").Build()).ToString()
+		if err != nil {
+			err = fmt.Errorf("%s: %w", addr, err)
+			return err, err
+		}
+		if loading == nil && infoField(info, "loading") == "1" {
+			loading = fmt.Errorf("%s is loading its dataset", addr)
+		}
+		if replication == nil && infoField(info, "role") == "slave" && infoField(info, "master_link_status") != "up" {
+			replication = fmt.Errorf("%s has lost its link to the primary", addr)
+		}
+	}
+	return loading, replication
+}
+
+// infoField returns the value of field in INFO output, or "" if absent.
+func infoField(info, field string) string {
+	for _, line := range strings.Split(info, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), field+":"); ok {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+const rateLimitKeyPrefix = "valkey-rest:ratelimit:"
+
+// RateLimitResult is the outcome of taking from a bucket.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration
+}
+
+// RateLimiter implements token buckets stored in Valkey, so limits hold
+// across every instance of the proxy.
+type RateLimiter struct {
+	client valkey.Client
+	limits atomic.Pointer[rateLimits]
+}
+
+// rateLimits are the configured limits, swapped as a whole on reload.
+type rateLimits struct {
+	perIP    int64
+	perToken int64
+	period   time.Duration
+}
+
+func NewRateLimiter(client valkey.Client, perIP, perToken int64, period time.Duration) *RateLimiter {
+	l := &RateLimiter{client: client}
+	l.SetLimits(perIP, perToken, period)
+	return l
+}
+
+// SetLimits replaces the per-IP and per-token limits. Existing buckets keep
+// their remaining tokens.
+func (l *RateLimiter) SetLimits(perIP, perToken int64, period time.Duration) {
+	l.limits.Store(&rateLimits{perIP: perIP, perToken: perToken, period: period})
+}
+
+// Take removes cost tokens from the named bucket, which holds up to limit
+// tokens and refills completely over period.
+func (l *RateLimiter) Take(ctx context.Context, bucket string, limit, cost int64, period time.Duration) (RateLimitResult, error) {
+	resp, err := handlers.TokenBucketScript.Exec(ctx, l.client, []string{rateLimitKeyPrefix + bucket}, []string{
+		strconv.FormatInt(limit, 10),
+		strconv.FormatInt(period.Milliseconds(), 10),
+		strconv.FormatInt(cost, 10),
+	}).AsIntSlice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:    resp[0] == 1,
+		Limit:      limit,
+		Remaining:  resp[1],
+		RetryAfter: time.Duration(resp[2]) * time.Millisecond,
+	}, nil
+}
+
+// allow applies a limit to a bucket and writes the rate limit headers. It
+// returns false after writing a 429 response. Valkey errors fail open, since
+// refusing every request during an outage would be worse than not limiting.
+func (l *RateLimiter) allow(w http.ResponseWriter, r *http.Request, bucket string, limit int64, period time.Duration) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	result, err := l.Take(r.Context(), bucket, limit, 1, period)
+	if err != nil {
+		log.Printf("Rate limit check failed, allowing request: %v", err)
+		return true
+	}
+
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+
+	if !result.Allowed {
+		// Retry-After is in whole seconds, rounded up
+		retry := int64((result.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.FormatInt(retry, 10))
+		handlers.WriteError(w, http.StatusTooManyRequests, handlers.CodeRateLimited, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// rateLimitMiddleware applies the per-IP limit before any other work is done.
+// Per-token limits are applied by authMiddleware once the token is known.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only requests that don't need Valkey get past an open circuit, and
+		// checking the limit would need it
+		limits := s.limiter.limits.Load()
+		if !s.breaker.Open() && !s.limiter.allow(w, r, "ip:"+remoteIP(r), limits.perIP, limits.period) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowPrincipal applies the per-token limit. API keys may carry their own
+// limit, which is always expressed per minute.
+func (s *Server) allowPrincipal(w http.ResponseWriter, r *http.Request, p *auth.Principal) bool {
+	if p.RateLimit > 0 {
+		return s.limiter.allow(w, r, "principal:"+p.ID, p.RateLimit, time.Minute)
+	}
+	limits := s.limiter.limits.Load()
+	return s.limiter.allow(w, r, "principal:"+p.ID, limits.perToken, limits.period)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+const readOnlyError = "server is in read-only mode"
+
+// readOnlyExempt are the admin routes that keep working in read-only mode,
+// so it can be turned off again.
+var readOnlyExempt = map[string]bool{
+	"POST /admin/reload":    true,
+	"GET /admin/read-only":  true,
+	"POST /admin/read-only": true,
+}
+
+// ReadOnlyRequest turns read-only mode on or off.
+type ReadOnlyRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// ReadOnlyStatus reports whether read-only mode is on.
+type ReadOnlyStatus struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// mutatingRoute reports whether a route can change data, and so is refused
+// in read-only mode: every route needing the write role, admin routes other
+// than reads, and the WebSocket gateway, which runs arbitrary commands.
+func mutatingRoute(pattern string, role auth.Role) bool {
+	if readOnlyExempt[pattern] {
+		return false
+	}
+	if role == auth.RoleWrite || pattern == "GET /ws" {
+		return true
+	}
+	method, _, _ := strings.Cut(pattern, " ")
+	return role == auth.RoleAdmin && method != http.MethodGet && method != http.MethodHead
+}
+
+// setReadOnly switches read-only mode, logging changes.
+func (s *Server) setReadOnly(enabled bool) {
+	if s.readOnly.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		readOnlyMode.Set(1)
+		log.Println("Read-only mode enabled - mutating endpoints are refused")
+	} else {
+		readOnlyMode.Set(0)
+		log.Println("Read-only mode disabled")
+	}
+}
+
+// readOnlyMiddleware refuses a mutating route while read-only mode is on,
+// and marks the context of other routes so they skip side writes.
+func (s *Server) readOnlyMiddleware(mutating bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly.Load() {
+			if mutating {
+				handlers.WriteError(w, http.StatusForbidden, handlers.CodeReadOnly, readOnlyError)
+				return
+			}
+			r = r.WithContext(handlers.WithReadOnly(r.Context()))
+		}
+		next(w, r)
+	}
+}
+
+// grpcReadOnly refuses RPCs needing more than the read role while read-only
+// mode is on.
+func (s *Server) grpcReadOnly(method string) error {
+	if role, ok := grpcMethodRoles[method]; s.readOnly.Load() && (!ok || role > auth.RoleRead) {
+		return status.Error(codes.PermissionDenied, readOnlyError)
+	}
+	return nil
+}
+
+func (s *Server) handleGetReadOnly(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(ReadOnlyStatus{ReadOnly: s.readOnly.Load()})
+}
+
+func (s *Server) handleSetReadOnly(w http.ResponseWriter, r *http.Request) {
+	var req ReadOnlyRequest
+	if !handlers.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Enabled == nil {
+		handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, "enabled is required")
+		return
+	}
+
+	s.setReadOnly(*req.Enabled)
+	json.NewEncoder(w).Encode(ReadOnlyStatus{ReadOnly: *req.Enabled})
+}
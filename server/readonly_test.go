@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"valkey-rest/handlers"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	s := newTestServer(t)
+	do(s, http.MethodPost, "/keys/k", testWriteToken, `{"value":"v"}`)
+	s.setReadOnly(true)
+	t.Cleanup(func() { s.setReadOnly(false) }) // Resets the gauge
+
+	if rec := do(s, http.MethodPost, "/keys/k", testWriteToken, `{"value":"w"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("write = %d, want 403: %s", rec.Code, rec.Body)
+	}
+	if rec := do(s, http.MethodGet, "/keys/k", testReadToken, ""); rec.Code != http.StatusOK {
+		t.Errorf("read = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	// Reads learn of the mode, so they can skip side writes
+	var marked bool
+	read := s.readOnlyMiddleware(false, func(w http.ResponseWriter, r *http.Request) {
+		marked = handlers.ReadOnly(r.Context())
+	})
+	read(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sessions/x", nil))
+	if !marked {
+		t.Error("read route context not marked read-only")
+	}
+	s.setReadOnly(false)
+	read(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sessions/x", nil))
+	if marked {
+		t.Error("context still marked after read-only mode was turned off")
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"time"
+
+	"valkey-rest/auth"
+	"valkey-rest/config"
+	"valkey-rest/handlers"
+)
+
+// ReloadConfig reads the configuration again from the config file the
+// server was started with and the environment, then applies it with Reload.
+func (s *Server) ReloadConfig() error {
+	cfg, err := config.Load(s.configFile)
+	if err != nil {
+		return err
+	}
+	return s.Reload(*cfg)
+}
+
+// Reload applies the settings of cfg that can change while the server runs:
+// auth tokens, rate limits, usage quotas and the log level. Webhook registrations are
+// re-read from Valkey. In-flight requests and the Valkey connection are left
+// alone; other settings only take effect on restart and are logged if they
+// differ. Nothing is applied if the tokens fail to load.
+func (s *Server) Reload(cfg config.Config) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	tokens, err := auth.NewTokenStore(cfg.AuthToken, cfg.Tokens, cfg.AuthTokensFile)
+	if err != nil {
+		return fmt.Errorf("load auth tokens: %w", err)
+	}
+	s.tokens.Store(tokens)
+	if !tokens.Enabled() && s.jwt == nil {
+		log.Println("Warning: reloaded configuration has no tokens - API is unsecured")
+	}
+
+	// Rate limit buckets live in Valkey
+	if s.client == nil {
+		cfg.RateLimitPerIP, cfg.RateLimitPerToken = 0, 0
+	}
+	s.limiter.SetLimits(cfg.RateLimitPerIP, cfg.RateLimitPerToken, cfg.RateLimitPeriod)
+	// Quotas are reloaded, but tracking itself is only turned on or off by a
+	// restart
+	if s.usage != nil {
+		s.usage.SetQuota(cfg.UsageMonthlyRequests, cfg.UsageMonthlyBytes)
+	}
+	logLevel.Set(parseLogLevel(cfg.LogLevel))
+
+	if s.startConfig.WebhooksEnabled {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.handlers.RefreshWebhooks(ctx); err != nil {
+			log.Printf("Failed to refresh webhooks: %v", err)
+		}
+	}
+
+	if !reflect.DeepEqual(fixedSettings(cfg), fixedSettings(s.startConfig)) {
+		log.Println("Warning: some changed settings only take effect on restart")
+	}
+	log.Printf("Configuration reloaded (%d tokens, %d per IP, %d per token every %s, log level %s)",
+		tokens.Len(), cfg.RateLimitPerIP, cfg.RateLimitPerToken, cfg.RateLimitPeriod, cfg.LogLevel)
+	return nil
+}
+
+// fixedSettings returns cfg without the settings Reload applies, leaving
+// those that need a restart.
+func fixedSettings(cfg config.Config) config.Config {
+	cfg.File = ""
+	cfg.AuthToken, cfg.AuthTokensFile, cfg.Tokens = "", "", nil
+	cfg.RateLimitPerIP, cfg.RateLimitPerToken, cfg.RateLimitPeriod = 0, 0, 0
+	cfg.UsageMonthlyRequests, cfg.UsageMonthlyBytes = 0, 0
+	cfg.LogLevel = ""
+	return cfg
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.ReloadConfig(); err != nil {
+		log.Printf("Failed to reload configuration: %v", err)
+		handlers.WriteError(w, http.StatusUnprocessableEntity, handlers.CodeBadRequest, err.Error())
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "reloaded"})
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"valkey-rest/handlers"
+)
+
+// bodyLimitMiddleware caps the size of every request body so a single large
+// upload can't exhaust memory. Handlers see an error once the limit is hit.
+// Imports are streamed rather than buffered, so they get their own limit.
+func (s *Server) bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := s.maxBodyBytes
+		if r.URL.Path == "/import" {
+			limit = s.maxImportBytes
+		}
+		if r.ContentLength > limit {
+			handlers.WriteError(w, http.StatusRequestEntityTooLarge, handlers.CodePayloadTooLarge, fmt.Sprintf("request body exceeds %d bytes", limit))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
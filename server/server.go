@@ -0,0 +1,525 @@
+// Package server wires the handlers, authentication and middleware into an
+// HTTP handler, and serves the same data over gRPC.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/auth"
+	"valkey-rest/config"
+	"valkey-rest/handlers"
+	"valkey-rest/store"
+)
+
+// Server is the valkey-rest API: a Valkey client plus everything needed to
+// serve it over HTTP and gRPC.
+type Server struct {
+	client            valkey.Client // Nil when running on another Store
+	secondary         valkey.Client // Migration target; nil when not migrating
+	store             store.Store
+	handlers          *handlers.Handlers
+	router            *http.ServeMux
+	handler           http.Handler
+	tokens            atomic.Pointer[auth.TokenStore] // Replaced on reload
+	jwt               *auth.JWTVerifier
+	apiKeys           *auth.APIKeyStore
+	limiter           *RateLimiter
+	usage             *UsageTracker       // nil unless USAGE_TRACKING is set
+	breaker           *CircuitBreaker     // nil without Valkey or when disabled
+	concurrency       *ConcurrencyLimiter // nil without concurrency limits
+	cors              *corsPolicy         // nil when no origins are allowed
+	audit             *Auditor            // nil when AUDIT_LOG is unset
+	ipFilter          *ipFilter
+	readOnly          atomic.Bool    // Set by READ_ONLY and POST /admin/read-only
+	adminListeners    bool           // Admin routes are only served by AdminHandler
+	db                int64          // Database the client selects on connect
+	allowedDBs        map[int64]bool // Other databases requests may select
+	maxBodyBytes      int64
+	maxImportBytes    int64
+	compressor        *handlers.ValueCompressor
+	routes            []routeInfo
+	openAPI           []byte
+	stopJWKS          context.CancelFunc
+	configFile        string
+	startConfig       config.Config
+	reloadMu          sync.Mutex
+	commandTimeout    time.Duration
+	maxCommandTimeout time.Duration
+	idempotencyTTL    time.Duration
+	scheduler         handlers.SchedulerOptions
+	// Readiness probe settings
+	readyTimeout          time.Duration
+	readyCheckLoading     bool
+	readyCheckReplication bool
+	// Shutdown state; see drain.go
+	drain drainState
+}
+
+// New connects to the backend selected by cfg.Backend and builds a Server
+// from cfg. Close releases the connection when the server is no longer needed.
+func New(cfg config.Config) (*Server, error) {
+	switch cfg.Backend {
+	case "", "valkey":
+	case "memory":
+		log.Println("Warning: BACKEND=memory keeps keys in process; only the /keys endpoints are served")
+		return NewWithStore(store.NewMemory(), cfg)
+	default:
+		return nil, fmt.Errorf("unknown BACKEND %q, expected valkey or memory", cfg.Backend)
+	}
+
+	client, err := newValkeyClient(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	if client, err = connectAllowedDBs(client, cfg); err != nil {
+		return nil, err
+	}
+	var secondary valkey.Client
+	if cfg.MigrationAddress != "" {
+		if secondary, err = connectSecondary(cfg); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("migration secondary: %w", err)
+		}
+	}
+	s, err := newWithClients(client, secondary, cfg)
+	if err != nil {
+		client.Close()
+		if secondary != nil {
+			secondary.Close()
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// connectSecondary connects to the migration secondary with the primary's
+// settings other than its address and password. TLS settings are shared;
+// sentinel and replica settings only apply to the primary.
+func connectSecondary(cfg config.Config) (valkey.Client, error) {
+	cfg.ValkeyAddress = cfg.MigrationAddress
+	cfg.ValkeyPassword = cfg.MigrationPassword
+	cfg.ValkeyTLSServerName = ""
+	cfg.ReadFromReplicas = false
+	cfg.ReplicaAddresses = ""
+	cfg.SentinelMaster = ""
+	client, err := newValkeyClient(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	return connectAllowedDBs(client, cfg)
+}
+
+// NewWithClient builds a Server around an existing Valkey client, which is
+// closed along with the server.
+func NewWithClient(client valkey.Client, cfg config.Config) (*Server, error) {
+	return newWithClients(client, nil, cfg)
+}
+
+// newWithClients is NewWithClient with an optional migration secondary,
+// which the /keys endpoints mirror in cfg.MigrationMode.
+func newWithClients(client, secondary valkey.Client, cfg config.Config) (*Server, error) {
+	// Requests may select the databases New connected a client for
+	var allowedDBs map[int64]bool
+	if dc, ok := client.(dbClient); ok {
+		allowedDBs = make(map[int64]bool, len(dc.dbs))
+		for db := range dc.dbs {
+			allowedDBs[db] = true
+		}
+	}
+
+	raw := client
+	breaker := NewCircuitBreaker(cfg.CircuitBreakerThreshold, cfg.CircuitBreakerProbeInterval, func(ctx context.Context) error {
+		return raw.Do(ctx, raw.B().Ping().Build()).Error()
+	})
+	client = instrumentedClient{Client: client, breaker: breaker}
+	var st store.Store = store.NewValkey(client)
+	if secondary != nil {
+		st = store.NewMirrored(st, store.NewValkey(secondary), store.MirrorOptions{
+			Mode:          cfg.MigrationMode,
+			ShadowTimeout: cfg.CommandTimeout,
+			Observe: func(op, result string) {
+				migrationResultsTotal.WithLabelValues(op, result).Inc()
+			},
+		})
+		log.Printf("Migrating keys with secondary Valkey at %s in %s mode", cfg.MigrationAddress, cfg.MigrationMode)
+	}
+	s, err := newServer(client, st, cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.secondary = secondary
+	s.breaker = breaker
+	s.allowedDBs = allowedDBs
+	if breaker != nil {
+		log.Printf("Circuit breaker opens after %d consecutive Valkey failures", cfg.CircuitBreakerThreshold)
+	}
+	return s, nil
+}
+
+// NewWithStore builds a Server that keeps keys in st instead of Valkey. Only
+// the health check and the plain /keys endpoints are served, since everything
+// else needs Valkey itself; rate limiting, API keys, webhooks and gRPC are
+// unavailable.
+func NewWithStore(st store.Store, cfg config.Config) (*Server, error) {
+	if cfg.GRPCPort != "" {
+		return nil, errors.New("GRPC_PORT requires the valkey backend")
+	}
+	if cfg.WebhooksEnabled {
+		return nil, errors.New("WEBHOOKS_ENABLED requires the valkey backend")
+	}
+	if cfg.SchedulerEnabled {
+		return nil, errors.New("SCHEDULER_ENABLED requires the valkey backend")
+	}
+	if cfg.RateLimitPerIP > 0 || cfg.RateLimitPerToken > 0 {
+		log.Println("Warning: rate limits are stored in Valkey and are disabled without it")
+		cfg.RateLimitPerIP, cfg.RateLimitPerToken = 0, 0
+	}
+	if cfg.UsageTracking {
+		return nil, errors.New("USAGE_TRACKING requires the valkey backend")
+	}
+	return newServer(nil, st, cfg)
+}
+
+// newServer builds a Server on st. client is nil unless st is backed by it.
+func newServer(client valkey.Client, st store.Store, cfg config.Config) (*Server, error) {
+	tokens, err := auth.NewTokenStore(cfg.AuthToken, cfg.Tokens, cfg.AuthTokensFile)
+	if err != nil {
+		return nil, fmt.Errorf("load auth tokens: %w", err)
+	}
+
+	if cfg.CacheSize > 0 {
+		st = store.NewCached(st, store.CacheOptions{
+			Size:  cfg.CacheSize,
+			TTL:   cfg.CacheTTL,
+			Stale: cfg.CacheStale,
+			Observe: func(result string) {
+				cacheLookupsTotal.WithLabelValues(result).Inc()
+			},
+		})
+		log.Printf("Caching up to %d values for %s, served stale for up to %s more", cfg.CacheSize, cfg.CacheTTL, cfg.CacheStale)
+	}
+
+	s := &Server{
+		client:                client,
+		store:                 st,
+		router:                http.NewServeMux(),
+		maxBodyBytes:          cfg.MaxBodyBytes,
+		maxImportBytes:        cfg.MaxImportBytes,
+		stopJWKS:              func() {},
+		configFile:            cfg.File,
+		adminListeners:        cfg.AdminListen != "",
+		db:                    cfg.ValkeyDB,
+		cors:                  newCORSPolicy(cfg),
+		commandTimeout:        cfg.CommandTimeout,
+		idempotencyTTL:        cfg.IdempotencyTTL,
+		maxCommandTimeout:     cfg.MaxCommandTimeout,
+		readyTimeout:          cfg.ReadyTimeout,
+		readyCheckLoading:     cfg.ReadyCheckLoading,
+		readyCheckReplication: cfg.ReadyCheckReplication,
+		startConfig:           cfg,
+		scheduler: handlers.SchedulerOptions{
+			Interval: cfg.SchedulerInterval,
+			Callback: cfg.SchedulerCallbackURL,
+			Secret:   cfg.SchedulerCallbackSecret,
+		},
+	}
+	s.tokens.Store(tokens)
+	s.drain.stop, s.drain.cancel = context.WithCancel(context.Background())
+
+	s.setReadOnly(cfg.ReadOnly)
+
+	s.ipFilter, err = newIPFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.ipFilter.adminAllow) > 0 {
+		log.Printf("Admin routes restricted to %s", cfg.AdminIPAllow)
+	}
+
+	if cfg.JWT.JWKSURL != "" {
+		jwtCtx, stopJWKS := context.WithCancel(context.Background())
+		s.jwt, err = auth.NewJWTVerifier(jwtCtx, cfg.JWT)
+		if err != nil {
+			stopJWKS()
+			return nil, fmt.Errorf("set up JWT authentication: %w", err)
+		}
+		s.stopJWKS = stopJWKS
+		log.Printf("JWT authentication enabled using keys from %s", cfg.JWT.JWKSURL)
+	}
+
+	if tokens.Enabled() {
+		log.Printf("Token authentication enabled (%d tokens)", tokens.Len())
+	} else if s.jwt == nil {
+		log.Println("Warning: No AUTH_TOKEN, AUTH_TOKENS_FILE or JWT_JWKS_URL configured - API is unsecured")
+	}
+
+	s.limiter = NewRateLimiter(client, cfg.RateLimitPerIP, cfg.RateLimitPerToken, cfg.RateLimitPeriod)
+	if cfg.RateLimitPerIP > 0 || cfg.RateLimitPerToken > 0 {
+		log.Printf("Rate limiting enabled: %d per IP, %d per token every %s", cfg.RateLimitPerIP, cfg.RateLimitPerToken, cfg.RateLimitPeriod)
+	}
+
+	if cfg.UsageTracking {
+		s.usage = NewUsageTracker(client, cfg.UsageMonthlyRequests, cfg.UsageMonthlyBytes)
+		log.Printf("Usage tracking enabled, with monthly quotas of %d requests and %d bytes per token", cfg.UsageMonthlyRequests, cfg.UsageMonthlyBytes)
+	}
+
+	s.concurrency = NewConcurrencyLimiter(cfg.MaxInFlight, cfg.MaxInFlightPerToken, cfg.MaxQueue, cfg.QueueTimeout)
+	if s.concurrency != nil {
+		log.Printf("Concurrency limited to %d requests, %d per token, with %d waiting up to %s", cfg.MaxInFlight, cfg.MaxInFlightPerToken, cfg.MaxQueue, cfg.QueueTimeout)
+	}
+
+	// Imports are the largest bodies a value can arrive in
+	s.compressor, err = handlers.NewValueCompressor(cfg.ValueCompression, cfg.ValueCompressionThreshold, max(cfg.MaxBodyBytes, cfg.MaxImportBytes))
+	if err != nil {
+		s.stopJWKS()
+		return nil, fmt.Errorf("invalid VALUE_COMPRESSION: %w", err)
+	}
+	if cfg.ValueCompression != "" && cfg.ValueCompression != "none" {
+		log.Printf("Compressing values of %d bytes or more with %s", cfg.ValueCompressionThreshold, cfg.ValueCompression)
+	}
+
+	scripts, err := handlers.LoadScripts(cfg.ScriptsDir)
+	if err != nil {
+		s.stopJWKS()
+		return nil, fmt.Errorf("load scripts: %w", err)
+	}
+	if names := scripts.Names(); len(names) > 0 {
+		log.Printf("Loaded %d scripts from %s", len(names), cfg.ScriptsDir)
+	}
+
+	s.audit, err = newAuditor(client, cfg.AuditLog, cfg.AuditFile, cfg.AuditStream, cfg.AuditStreamMaxLen, cfg.AuditSecret)
+	if err != nil {
+		s.stopJWKS()
+		return nil, fmt.Errorf("set up audit log: %w", err)
+	}
+	if s.audit != nil {
+		log.Printf("Audit log enabled, writing to %s", cfg.AuditLog)
+	}
+
+	if client != nil {
+		s.apiKeys = auth.NewAPIKeyStore(client)
+	}
+	s.handlers = handlers.New(client, st, s.apiKeys, s.compressor, scripts, handlers.NewCommandPolicy(cfg.CommandAllow, cfg.CommandDeny), cfg.ValkeyDB, cfg.KeyVersions, cfg.SessionTTL, cfg.ScheduleKey)
+	s.setupRoutes(cfg.DocsEnabled)
+	s.openAPI, _ = json.Marshal(buildOpenAPI(s.routes))
+	// Each layer sees the route pattern the mux sets on the request it passes
+	// down. Requests are counted for shutdown draining, then the client
+	// address is resolved and namespace path prefixes are stripped before
+	// anything else runs, and requests rejected by the IP
+	// lists, CORS preflights and requests rejected by the circuit breaker or
+	// rate or concurrency limits are still logged and counted.
+	s.handler = s.drainMiddleware(s.clientIPMiddleware(s.namespaceMiddleware(s.tracingMiddleware(s.loggingMiddleware(s.metricsMiddleware(s.ipFilterMiddleware(s.corsMiddleware(s.circuitBreakerMiddleware(s.rateLimitMiddleware(s.concurrencyMiddleware(s.timeoutMiddleware(s.bodyLimitMiddleware(s.compressionMiddleware(s.router))))))))))))))
+	return s, nil
+}
+
+// Handler returns the HTTP handler serving the REST API.
+func (s *Server) Handler() http.Handler {
+	return s
+}
+
+// RunWebhooks delivers keyspace notifications to registered webhooks until
+// ctx is cancelled.
+func (s *Server) RunWebhooks(ctx context.Context) {
+	s.handlers.RunWebhooks(ctx)
+}
+
+// RunScheduler runs due jobs from the key schedule until ctx is cancelled.
+func (s *Server) RunScheduler(ctx context.Context) {
+	s.handlers.RunScheduler(ctx, s.scheduler)
+}
+
+// Close stops background work and closes the Valkey client. Call it after
+// Drain, so requests still running don't lose their connection.
+func (s *Server) Close() {
+	s.drain.cancel()
+	s.stopJWKS()
+	s.breaker.Close()
+	s.audit.Close()
+	if s.client != nil {
+		s.client.Close()
+	}
+	if s.secondary != nil {
+		s.secondary.Close()
+	}
+}
+
+func (s *Server) setupRoutes(docs bool) {
+	h := s.handlers
+
+	// Health checks are public (no auth required). /livez only shows the
+	// process is up; /readyz also checks Valkey
+	s.publicRoute("GET /health", h.HandleHealth)
+	s.publicRoute("GET /livez", s.handleLivez)
+	s.publicRoute("GET /readyz", s.handleReadyz)
+
+	// Prometheus metrics are public so scrapers don't need an API token
+	s.publicRoute("GET /metrics", s.handleMetrics)
+
+	// The API description is public too; Swagger UI is opt-in
+	s.publicRoute("GET /openapi.json", s.handleOpenAPI)
+	if docs {
+		s.publicRoute("GET /docs", s.handleDocs)
+	}
+
+	// Protected endpoints require authentication
+	s.route("GET /keys/{key}", auth.RoleRead, h.HandleGet)
+	s.route("HEAD /keys/{key}", auth.RoleRead, h.HandleHead)
+	s.route("GET /keys/{key}/exists", auth.RoleRead, h.HandleExists)
+	s.route("POST /keys/{key}", auth.RoleWrite, h.HandleSet)
+	s.route("DELETE /keys/{key}", auth.RoleWrite, h.HandleDelete)
+	s.route("GET /keys", auth.RoleRead, h.HandleList)
+
+	// Reloads tokens, rate limits, usage quotas, the log level and webhooks
+	// like SIGHUP
+	s.route("POST /admin/reload", auth.RoleAdmin, s.handleReload)
+
+	// Read-only mode can be switched per instance without a restart
+	s.route("GET /admin/read-only", auth.RoleAdmin, s.handleGetReadOnly)
+	s.route("POST /admin/read-only", auth.RoleAdmin, s.handleSetReadOnly)
+
+	// Recent entries of the audit log, with their hash chain checked
+	if s.audit != nil {
+		s.route("GET /admin/audit", auth.RoleAdmin, s.handleAudit)
+	}
+
+	// Everything below talks to Valkey directly rather than through the Store
+	if s.client == nil {
+		return
+	}
+
+	// Each token's requests and bytes this month or an earlier one
+	if s.usage != nil {
+		s.route("GET /admin/usage", auth.RoleAdmin, s.handleUsage)
+	}
+
+	s.route("GET /keys/{key}/meta", auth.RoleRead, h.HandleKeyMeta)
+	s.route("GET /keys/{key}/versions", auth.RoleRead, h.HandleListVersions)
+	s.route("POST /keys/{key}/versions/{version}/restore", auth.RoleWrite, h.HandleRestoreVersion)
+	s.route("POST /keys/{key}/rename", auth.RoleWrite, h.HandleRename)
+	s.route("POST /keys/{key}/copy", auth.RoleWrite, h.HandleCopy)
+	s.route("GET /keys/{key}/range", auth.RoleRead, h.HandleGetRange)
+	s.route("POST /keys/{key}/append", auth.RoleWrite, h.HandleAppend)
+	s.route("POST /keys/{key}/setrange", auth.RoleWrite, h.HandleSetRange)
+	s.route("GET /keys/{key}/schedule", auth.RoleRead, h.HandleGetSchedule)
+	s.route("POST /keys/{key}/schedule", auth.RoleWrite, h.HandleSchedule)
+	s.route("DELETE /keys/{key}/schedule", auth.RoleWrite, h.HandleCancelSchedule)
+	s.route("DELETE /keys", auth.RoleWrite, h.HandleBulkDelete)
+
+	// Export and import
+	s.route("GET /export", auth.RoleRead, h.HandleExport)
+	s.route("POST /import", auth.RoleWrite, h.HandleImport)
+
+	// Pub/Sub
+	s.route("POST /publish/{channel}", auth.RoleWrite, h.HandlePublish)
+	s.route("GET /subscribe/{channel}", auth.RoleRead, h.HandleSubscribe)
+
+	// Blocking list pops for queue workers
+	s.route("POST /lists/{key}/bpop", auth.RoleWrite, h.HandleBlockingPop)
+
+	// Streams. Consumer group reads update the pending entries list, so they
+	// need write access.
+	s.route("POST /streams/{key}", auth.RoleWrite, h.HandleStreamAdd)
+	s.route("GET /streams/{key}", auth.RoleRead, h.HandleStreamRange)
+	s.route("GET /streams/{key}/read", auth.RoleRead, h.HandleStreamRead)
+	s.route("POST /streams/{key}/groups", auth.RoleWrite, h.HandleStreamCreateGroup)
+	s.route("GET /streams/{key}/groups/{group}", auth.RoleWrite, h.HandleStreamReadGroup)
+	s.route("POST /streams/{key}/groups/{group}/ack", auth.RoleWrite, h.HandleStreamAck)
+
+	// JSON documents (requires the JSON module)
+	s.route("GET /json/{key}", auth.RoleRead, h.HandleJSONGet)
+	s.route("POST /json/{key}", auth.RoleWrite, h.HandleJSONSet)
+	s.route("DELETE /json/{key}", auth.RoleWrite, h.HandleJSONDelete)
+
+	// HyperLogLog cardinality counting
+	s.route("POST /hll/{key}", auth.RoleWrite, h.HandleHLLAdd)
+	s.route("GET /hll/{key}", auth.RoleRead, h.HandleHLLCount)
+	s.route("POST /hll/{key}/merge", auth.RoleWrite, h.HandleHLLMerge)
+
+	// Bitmaps
+	s.route("GET /bitmaps/{key}/bits/{offset}", auth.RoleRead, h.HandleGetBit)
+	s.route("POST /bitmaps/{key}/bits/{offset}", auth.RoleWrite, h.HandleSetBit)
+	s.route("GET /bitmaps/{key}/count", auth.RoleRead, h.HandleBitCount)
+	s.route("POST /bitmaps/{key}/op", auth.RoleWrite, h.HandleBitOp)
+
+	// Geospatial indexes
+	s.route("POST /geo/{key}", auth.RoleWrite, h.HandleGeoAdd)
+	s.route("GET /geo/{key}/search", auth.RoleRead, h.HandleGeoSearch)
+
+	// Distributed locks
+	s.route("GET /locks/{name}", auth.RoleRead, h.HandleGetLock)
+	s.route("POST /locks/{name}", auth.RoleWrite, h.HandleAcquireLock)
+	s.route("POST /locks/{name}/renew", auth.RoleWrite, h.HandleRenewLock)
+	s.route("DELETE /locks/{name}", auth.RoleWrite, h.HandleReleaseLock)
+
+	// Sessions for web apps, renewed on every access
+	s.route("POST /sessions", auth.RoleWrite, h.HandleCreateSession)
+	s.route("GET /sessions/{id}", auth.RoleRead, h.HandleGetSession)
+	s.route("POST /sessions/{id}", auth.RoleWrite, h.HandleUpdateSession)
+	s.route("POST /sessions/{id}/touch", auth.RoleWrite, h.HandleTouchSession)
+	s.route("DELETE /sessions/{id}", auth.RoleWrite, h.HandleDestroySession)
+
+	// Leaderboards on sorted sets
+	s.route("GET /leaderboards/{name}", auth.RoleRead, h.HandleGetLeaderboard)
+	s.route("DELETE /leaderboards/{name}", auth.RoleWrite, h.HandleDeleteLeaderboard)
+	s.route("POST /leaderboards/{name}/scores", auth.RoleWrite, h.HandleSubmitScore)
+	s.route("GET /leaderboards/{name}/members/{member}", auth.RoleRead, h.HandleGetMemberRank)
+	s.route("GET /leaderboards/{name}/members/{member}/around", auth.RoleRead, h.HandleGetAroundMember)
+	s.route("DELETE /leaderboards/{name}/members/{member}", auth.RoleWrite, h.HandleRemoveMember)
+
+	// Feature flags, evaluated by clients and managed by admins
+	s.route("GET /flags/{name}/evaluate", auth.RoleRead, h.HandleEvaluateFlag)
+	s.route("GET /flags", auth.RoleAdmin, h.HandleListFlags)
+	s.route("GET /flags/{name}", auth.RoleAdmin, h.HandleGetFlag)
+	s.route("PUT /flags/{name}", auth.RoleAdmin, h.HandlePutFlag)
+	s.route("DELETE /flags/{name}", auth.RoleAdmin, h.HandleDeleteFlag)
+
+	// Rate limit checks for other services, in buckets they define
+	s.route("POST /ratelimit/{bucket}", auth.RoleWrite, h.HandleRateLimit)
+
+	// Scripts run only from the registered allow-list, never arbitrary EVAL
+	s.route("GET /scripts", auth.RoleRead, h.HandleListScripts)
+	s.route("POST /scripts/{name}", auth.RoleWrite, h.HandleRunScript)
+
+	// API key management
+	s.route("POST /admin/apikeys", auth.RoleAdmin, h.HandleCreateAPIKey)
+	s.route("GET /admin/apikeys", auth.RoleAdmin, h.HandleListAPIKeys)
+	s.route("DELETE /admin/apikeys/{id}", auth.RoleAdmin, h.HandleDeleteAPIKey)
+
+	// Server diagnostics
+	s.route("GET /admin/info", auth.RoleAdmin, h.HandleInfo)
+	s.route("GET /admin/dbsize", auth.RoleAdmin, h.HandleDBSize)
+	s.route("POST /admin/flush", auth.RoleAdmin, h.HandleFlush)
+	s.route("GET /admin/slowlog", auth.RoleAdmin, h.HandleSlowlog)
+	s.route("GET /admin/latency", auth.RoleAdmin, h.HandleLatency)
+	s.route("GET /admin/clients", auth.RoleAdmin, h.HandleListClients)
+	s.route("POST /admin/clients/{id}/kill", auth.RoleAdmin, h.HandleKillClient)
+	s.route("GET /admin/config/{param}", auth.RoleAdmin, h.HandleGetConfig)
+	s.route("POST /admin/config", auth.RoleAdmin, h.HandleSetConfig)
+
+	// Keyspace notification webhooks
+	s.route("POST /admin/webhooks", auth.RoleAdmin, h.HandleCreateWebhook)
+	s.route("GET /admin/webhooks", auth.RoleAdmin, h.HandleListWebhooks)
+	s.route("DELETE /admin/webhooks/{id}", auth.RoleAdmin, h.HandleDeleteWebhook)
+
+	// Command passthrough for anything the typed endpoints don't cover
+	s.route("POST /command", auth.RoleAdmin, h.HandleCommand)
+	s.route("POST /transactions", auth.RoleAdmin, h.HandleTransaction)
+
+	// WebSocket gateway runs arbitrary commands, so it is admin only
+	s.route("GET /ws", auth.RoleAdmin, h.HandleWebSocket)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+
+	"valkey-rest/handlers"
+)
+
+// timeoutMiddleware sets the command timeout handlers use: the configured
+// default, or the X-Timeout-Ms header capped at the configured maximum.
+// /health keeps the shorter readiness timeout.
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := s.commandTimeout
+		if r.URL.Path == "/health" {
+			timeout = s.readyTimeout
+		}
+
+		if v := r.Header.Get(handlers.TimeoutHeader); v != "" {
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || ms <= 0 {
+				handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, fmt.Sprintf("%s must be a positive number of milliseconds", handlers.TimeoutHeader))
+				return
+			}
+			timeout = min(time.Duration(ms)*time.Millisecond, s.maxCommandTimeout)
+			// Leave the response time to be written after a long command
+			if timeout > s.commandTimeout {
+				http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 5*time.Second))
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(handlers.WithCommandTimeout(r.Context(), timeout)))
+	})
+}
+
+// grpcCommandTimeout is the gRPC counterpart of timeoutMiddleware. A client
+// deadline replaces the default, up to the configured maximum; shorter ones
+// apply anyway through the context.
+func (s *Server) grpcCommandTimeout(ctx context.Context) context.Context {
+	timeout := s.commandTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(time.Until(deadline), s.maxCommandTimeout)
+	}
+	return handlers.WithCommandTimeout(ctx, timeout)
+}
+
+// commandContext bounds the Valkey commands of a gRPC call by its command
+// timeout.
+func commandContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, handlers.CommandTimeout(ctx))
+}
+
+// retryDelay allows retries of read-only commands up to retries times after
+// a connection error, waiting a random time of up to backoff, doubled on
+// every attempt ("full jitter"). valkey-go only retries commands that can
+// safely be sent twice.
+func retryDelay(retries int, backoff time.Duration) valkey.RetryDelayFn {
+	return func(attempts int, _ valkey.Completed, _ error) time.Duration {
+		if attempts > retries {
+			return -1
+		}
+		limit := backoff << min(attempts-1, 10)
+		return rand.N(limit) + 1
+	}
+}
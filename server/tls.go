@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+
+	"valkey-rest/config"
+)
+
+// loadCertPool reads a PEM bundle of CA certificates.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// TLSConfig builds the TLS configuration for the HTTP listener. When a
+// client CA bundle is configured, clients must present a certificate signed by
+// it (mutual TLS).
+func TLSConfig(cfg *config.Config) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pool, err := loadCertPool(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// valkeyTLSConfig builds the TLS configuration for connections to Valkey. The
+// server name defaults to the host of the first configured address so SNI
+// works with managed services.
+func valkeyTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ValkeyTLSServerName,
+		InsecureSkipVerify: cfg.ValkeyTLSInsecure,
+	}
+
+	if tlsConfig.ServerName == "" {
+		if addrs := config.SplitList(cfg.ValkeyAddress); len(addrs) > 0 {
+			if host, _, err := net.SplitHostPort(addrs[0]); err == nil {
+				tlsConfig.ServerName = host
+			}
+		}
+	}
+
+	if cfg.ValkeyTLSCAFile != "" {
+		pool, err := loadCertPool(cfg.ValkeyTLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// A client certificate is only needed when Valkey requires mutual TLS
+	if cfg.ValkeyTLSCertFile != "" || cfg.ValkeyTLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ValkeyTLSCertFile, cfg.ValkeyTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load Valkey client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"valkey-rest/auth"
+	"valkey-rest/handlers"
+)
+
+const (
+	usageKeyPrefix = "valkey-rest:usage:"
+	usageMonth     = "2006-01"
+	// usageRetention is how long a month's counters outlive their last
+	// write, keeping a year of history for billing.
+	usageRetention = 400 * 24 * time.Hour
+)
+
+// errQuotaExceeded is reported once a token has used up a monthly quota.
+const errQuotaExceeded = "monthly quota exceeded"
+
+// UsageCounters are the requests made and bytes transferred. Bytes are
+// counted before response compression.
+type UsageCounters struct {
+	Requests int64 `json:"requests"`
+	BytesIn  int64 `json:"bytes_in"`  // Request bodies
+	BytesOut int64 `json:"bytes_out"` // Response bodies
+}
+
+// PrincipalUsage is a token's usage in a month, in total and per route.
+type PrincipalUsage struct {
+	Principal string `json:"principal"`
+	Name      string `json:"name,omitempty"`
+	UsageCounters
+	Routes map[string]*UsageCounters `json:"routes"` // By route pattern or gRPC method
+}
+
+// UsageQuota is the monthly quota applied to every token; 0 means none.
+type UsageQuota struct {
+	Requests int64 `json:"requests"`
+	Bytes    int64 `json:"bytes"` // Bytes in and out together
+}
+
+// UsageResponse is returned by GET /admin/usage.
+type UsageResponse struct {
+	Month      string            `json:"month"`
+	Quota      UsageQuota        `json:"quota"`
+	Principals []*PrincipalUsage `json:"principals"`
+}
+
+// UsageTracker counts each principal's requests and bytes per calendar
+// month (UTC) in Valkey, so every instance adds to the same totals, and
+// enforces the monthly quotas.
+type UsageTracker struct {
+	client valkey.Client
+	quota  atomic.Pointer[UsageQuota]
+}
+
+func NewUsageTracker(client valkey.Client, requests, bytes int64) *UsageTracker {
+	u := &UsageTracker{client: client}
+	u.SetQuota(requests, bytes)
+	return u
+}
+
+// SetQuota replaces the monthly quotas. Usage already counted is kept.
+func (u *UsageTracker) SetQuota(requests, bytes int64) {
+	u.quota.Store(&UsageQuota{Requests: requests, Bytes: bytes})
+}
+
+func usageKey(month, principal string) string {
+	return usageKeyPrefix + month + ":" + principal
+}
+
+// usageIndexKey is a set of the principals with usage in a month.
+func usageIndexKey(month string) string {
+	return usageKeyPrefix + month
+}
+
+// Allow reports whether p may make another request this month. Valkey
+// errors fail open, like rate limits. Requests already in flight when a
+// quota runs out still complete, so usage may end slightly above it.
+func (u *UsageTracker) Allow(ctx context.Context, p *auth.Principal) bool {
+	quota := u.quota.Load()
+	if quota.Requests <= 0 && quota.Bytes <= 0 {
+		return true
+	}
+
+	key := usageKey(time.Now().UTC().Format(usageMonth), p.ID)
+	counts, err := u.client.Do(ctx, u.client.B().Hmget().Key(key).Field("requests", "bytes_in", "bytes_out").Build()).ToArray()
+	if err != nil {
+		log.Printf("Usage quota check failed, allowing request: %v", err)
+		return true
+	}
+	var used [3]int64
+	for i, count := range counts {
+		used[i], _ = count.AsInt64() // Missing fields read as nil
+	}
+	if quota.Requests > 0 && used[0] >= quota.Requests {
+		return false
+	}
+	return quota.Bytes <= 0 || used[1]+used[2] < quota.Bytes
+}
+
+// Record adds a request to p's usage for the current month.
+func (u *UsageTracker) Record(ctx context.Context, p *auth.Principal, route string, bytesIn, bytesOut int64) {
+	month := time.Now().UTC().Format(usageMonth)
+	key, index := usageKey(month, p.ID), usageIndexKey(month)
+	retention := int64(usageRetention / time.Second)
+
+	for _, resp := range u.client.DoMulti(ctx,
+		u.client.B().Hincrby().Key(key).Field("requests").Increment(1).Build(),
+		u.client.B().Hincrby().Key(key).Field("bytes_in").Increment(bytesIn).Build(),
+		u.client.B().Hincrby().Key(key).Field("bytes_out").Increment(bytesOut).Build(),
+		u.client.B().Hincrby().Key(key).Field("requests "+route).Increment(1).Build(),
+		u.client.B().Hincrby().Key(key).Field("bytes_in "+route).Increment(bytesIn).Build(),
+		u.client.B().Hincrby().Key(key).Field("bytes_out "+route).Increment(bytesOut).Build(),
+		u.client.B().Hset().Key(key).FieldValue().FieldValue("name", p.Name).Build(),
+		u.client.B().Expire().Key(key).Seconds(retention).Build(),
+		u.client.B().Sadd().Key(index).Member(p.ID).Build(),
+		u.client.B().Expire().Key(index).Seconds(retention).Build(),
+	) {
+		if err := resp.Error(); err != nil {
+			log.Printf("Failed to record usage of %s: %v", p.ID, err)
+			return
+		}
+	}
+}
+
+// Usage returns every principal's usage in month, sorted by principal.
+func (u *UsageTracker) Usage(ctx context.Context, month string) ([]*PrincipalUsage, error) {
+	ids, err := u.client.Do(ctx, u.client.B().Smembers().Key(usageIndexKey(month)).Build()).AsStrSlice()
+	if err != nil || len(ids) == 0 {
+		return []*PrincipalUsage{}, err
+	}
+	slices.Sort(ids)
+
+	cmds := make(valkey.Commands, len(ids))
+	for i, id := range ids {
+		cmds[i] = u.client.B().Hgetall().Key(usageKey(month, id)).Build()
+	}
+	usage := make([]*PrincipalUsage, 0, len(ids))
+	for i, resp := range u.client.DoMulti(ctx, cmds...) {
+		fields, err := resp.AsStrMap()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			usage = append(usage, parseUsage(ids[i], fields))
+		}
+	}
+	return usage, nil
+}
+
+// parseUsage decodes a usage hash: "requests", "bytes_in" and "bytes_out"
+// hold the totals, and the same names followed by a space and a route hold
+// that route's share.
+func parseUsage(id string, fields map[string]string) *PrincipalUsage {
+	usage := &PrincipalUsage{Principal: id, Name: fields["name"], Routes: make(map[string]*UsageCounters)}
+	for field, value := range fields {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		counter, route, _ := strings.Cut(field, " ")
+		counters := &usage.UsageCounters
+		if route != "" {
+			if counters = usage.Routes[route]; counters == nil {
+				counters = &UsageCounters{}
+				usage.Routes[route] = counters
+			}
+		}
+		switch counter {
+		case "requests":
+			counters.Requests = n
+		case "bytes_in":
+			counters.BytesIn = n
+		case "bytes_out":
+			counters.BytesOut = n
+		}
+	}
+	return usage
+}
+
+// nextMonth returns the start of the month after t, when quotas reset.
+func nextMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// countingBody counts the bytes a handler reads from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// usageMiddleware enforces the monthly quotas and records each request of
+// an authenticated principal. It runs inside authMiddleware, which puts the
+// principal on the context; requests refused before then aren't counted.
+func (s *Server) usageMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := auth.FromContext(r.Context())
+		if p == nil {
+			next(w, r)
+			return
+		}
+
+		// Checking the quota needs Valkey, so an open circuit skips it
+		if !s.breaker.Open() && !s.usage.Allow(r.Context(), p) {
+			retry := int64(time.Until(nextMonth(time.Now())).Round(time.Second) / time.Second)
+			w.Header().Set("Retry-After", strconv.FormatInt(retry, 10))
+			handlers.WriteError(w, http.StatusTooManyRequests, handlers.CodeQuotaExceeded, errQuotaExceeded)
+			return
+		}
+
+		body := &countingBody{ReadCloser: r.Body}
+		r.Body = body
+		rec := newStatusRecorder(w)
+		next(rec, r)
+
+		// The client may be gone, but the request still counts
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), handlers.CommandTimeout(r.Context()))
+		defer cancel()
+		s.usage.Record(ctx, p, r.Pattern, body.n, rec.bytes)
+	}
+}
+
+// grpcAllowUsage is the gRPC counterpart of the quota check in
+// usageMiddleware.
+func (s *Server) grpcAllowUsage(ctx context.Context, p *auth.Principal) error {
+	if s.usage != nil && !s.usage.Allow(ctx, p) {
+		return status.Error(codes.ResourceExhausted, errQuotaExceeded)
+	}
+	return nil
+}
+
+// grpcRecordUsage records a call made by an authenticated principal, with
+// the sizes of its messages standing in for body bytes.
+func (s *Server) grpcRecordUsage(ctx context.Context, method string, req, resp any) {
+	p := auth.FromContext(ctx)
+	if s.usage == nil || p == nil {
+		return
+	}
+	var bytesIn, bytesOut int64
+	if m, ok := req.(proto.Message); ok {
+		bytesIn = int64(proto.Size(m))
+	}
+	if m, ok := resp.(proto.Message); ok {
+		bytesOut = int64(proto.Size(m))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), handlers.CommandTimeout(ctx))
+	defer cancel()
+	s.usage.Record(ctx, p, method, bytesIn, bytesOut)
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		month = time.Now().UTC().Format(usageMonth)
+	} else if _, err := time.Parse(usageMonth, month); err != nil {
+		handlers.WriteError(w, http.StatusBadRequest, handlers.CodeBadRequest, "month must be in the form YYYY-MM")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), handlers.CommandTimeout(r.Context()))
+	defer cancel()
+
+	usage, err := s.usage.Usage(ctx, month)
+	if err != nil {
+		handlers.WriteCommandError(w, err)
+		return
+	}
+	if principal := r.URL.Query().Get("principal"); principal != "" {
+		usage = slices.DeleteFunc(usage, func(u *PrincipalUsage) bool { return u.Principal != principal })
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UsageResponse{Month: month, Quota: *s.usage.quota.Load(), Principals: usage})
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Invalidator is implemented by stores that cache values, so writes made
+// around the Store can drop stale copies.
+type Invalidator interface {
+	// Invalidate drops any cached copies of keys.
+	Invalidate(keys ...string)
+	// InvalidateAll empties the cache.
+	InvalidateAll()
+}
+
+// CacheOptions configure a Cached store.
+type CacheOptions struct {
+	// Size is the most keys kept; the least recently used are evicted.
+	Size int
+	// TTL is how long a cached value is served without asking the backend.
+	TTL time.Duration
+	// Stale is how long past TTL a value may still be served while it is
+	// refreshed in the background. Zero disables stale-while-revalidate.
+	Stale time.Duration
+	// Observe, if set, is called with "hit", "stale", "coalesced" or "miss"
+	// for every Get.
+	Observe func(result string)
+}
+
+// cacheEntry is a cached Get result. Missing keys are cached too, so hot
+// misses don't reach the backend either.
+type cacheEntry struct {
+	key     string
+	value   string
+	missing bool
+	fetched time.Time
+}
+
+func (e *cacheEntry) result() (string, error) {
+	if e.missing {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+// cacheLoad is a Get in progress that other readers of the key wait for.
+type cacheLoad struct {
+	done  chan struct{}
+	value string
+	err   error
+}
+
+// Cached keeps recent Get results of another Store in process. Concurrent
+// misses for a key share one backend read, and once TTL has passed a value
+// is served for up to Stale longer while one background read refreshes it.
+// Writes through the Cached store invalidate the key; writes made elsewhere
+// are seen once the cached value expires, unless reported to Invalidate.
+type Cached struct {
+	Store
+	opts CacheOptions
+
+	mu      sync.Mutex
+	lru     *list.List // Of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+	loads   map[string]*cacheLoad
+}
+
+// NewCached returns st with a cache in front of Get.
+func NewCached(st Store, opts CacheOptions) *Cached {
+	if opts.Observe == nil {
+		opts.Observe = func(string) {}
+	}
+	return &Cached{
+		Store:   st,
+		opts:    opts,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		loads:   make(map[string]*cacheLoad),
+	}
+}
+
+func (c *Cached) Get(ctx context.Context, key string) (string, error) {
+	// Entries belong to the configured database
+	if _, ok := DBFromContext(ctx); ok {
+		return c.Store.Get(ctx, key)
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		age := time.Since(entry.fetched)
+		switch {
+		case age < c.opts.TTL:
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			c.opts.Observe("hit")
+			return entry.result()
+		case age < c.opts.TTL+c.opts.Stale:
+			c.lru.MoveToFront(elem)
+			if _, loading := c.loads[key]; !loading {
+				load := c.startLoad(key)
+				go func() {
+					// The refresh outlives the request that noticed the stale value
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					c.load(ctx, key, load)
+				}()
+			}
+			c.mu.Unlock()
+			c.opts.Observe("stale")
+			return entry.result()
+		default:
+			c.remove(elem)
+		}
+	}
+
+	if load, ok := c.loads[key]; ok {
+		c.mu.Unlock()
+		c.opts.Observe("coalesced")
+		select {
+		case <-load.done:
+			return load.value, load.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	load := c.startLoad(key)
+	c.mu.Unlock()
+	c.opts.Observe("miss")
+	c.load(ctx, key, load)
+	return load.value, load.err
+}
+
+// startLoad registers a read of key for others to wait on. c.mu must be held.
+func (c *Cached) startLoad(key string) *cacheLoad {
+	load := &cacheLoad{done: make(chan struct{})}
+	c.loads[key] = load
+	return load
+}
+
+// load reads key from the backend and caches the result, unless the key was
+// invalidated while the read was in flight.
+func (c *Cached) load(ctx context.Context, key string, load *cacheLoad) {
+	load.value, load.err = c.Store.Get(ctx, key)
+
+	c.mu.Lock()
+	if c.loads[key] == load {
+		delete(c.loads, key)
+		if load.err == nil || load.err == ErrNotFound {
+			c.put(&cacheEntry{key: key, value: load.value, missing: load.err == ErrNotFound, fetched: time.Now()})
+		}
+	}
+	c.mu.Unlock()
+	close(load.done)
+}
+
+// put caches entry, evicting the least recently used key if the cache is
+// full. c.mu must be held.
+func (c *Cached) put(entry *cacheEntry) {
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.opts.Size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops a cached entry. c.mu must be held.
+func (c *Cached) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+func (c *Cached) Invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+		// A read in flight may have seen the old value; don't let it cache it
+		delete(c.loads, key)
+	}
+}
+
+func (c *Cached) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Init()
+	clear(c.entries)
+	clear(c.loads)
+}
+
+func (c *Cached) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	defer c.Invalidate(key)
+	return c.Store.Set(ctx, key, value, ttl)
+}
+
+func (c *Cached) Del(ctx context.Context, key string) (bool, error) {
+	defer c.Invalidate(key)
+	return c.Store.Del(ctx, key)
+}
+
+func (c *Cached) CompareAndSet(ctx context.Context, key, value string, ttl time.Duration, tags []string) (bool, error) {
+	defer c.Invalidate(key)
+	return c.Store.CompareAndSet(ctx, key, value, ttl, tags)
+}
+
+func (c *Cached) CompareAndDelete(ctx context.Context, key string, tags []string) (bool, error) {
+	defer c.Invalidate(key)
+	return c.Store.CompareAndDelete(ctx, key, tags)
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store kept in a map. It backs the handler and server tests
+// and BACKEND=memory for local development. Nothing is persisted and every
+// instance has its own keyspace.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   string
+	expires time.Time // Zero for keys without a TTL
+}
+
+// NewMemory returns an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]memoryEntry)}
+}
+
+// getLocked returns the entry for key, dropping it if it has expired.
+func (m *Memory) getLocked(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if ok && !entry.expires.IsZero() && !time.Now().Before(entry.expires) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, ok
+}
+
+func (m *Memory) setLocked(key, value string, ttl time.Duration) {
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	m.entries[key] = entry
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.getLocked(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return entry.value, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.setLocked(key, value, ttl)
+	return nil
+}
+
+func (m *Memory) Del(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.getLocked(key)
+	delete(m.entries, key)
+	return ok, nil
+}
+
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.getLocked(key)
+	return ok, nil
+}
+
+// Scan walks the keys in sorted order. The cursor is the last key returned,
+// so keys added or removed between pages don't shift the walk.
+func (m *Memory) Scan(ctx context.Context, pattern, cursor string, count int) ([]string, string, error) {
+	after := ""
+	if cursor != "0" {
+		if len(cursor) < 2 || cursor[0] != 'k' {
+			return nil, "", ErrInvalidCursor
+		}
+		after = cursor[1:]
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	all := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		if _, ok := m.getLocked(key); ok && key > after {
+			all = append(all, key)
+		}
+	}
+	sort.Strings(all)
+
+	// Like SCAN, count bounds the keys examined rather than the keys returned
+	if len(all) <= count {
+		return filterKeys(all, pattern), "0", nil
+	}
+	page := all[:count]
+	return filterKeys(page, pattern), "k" + page[len(page)-1], nil
+}
+
+func filterKeys(keys []string, pattern string) []string {
+	matched := []string{}
+	for _, key := range keys {
+		if globMatch(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+func (m *Memory) CompareAndSet(ctx context.Context, key, value string, ttl time.Duration, tags []string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.matchLocked(key, tags) {
+		return false, nil
+	}
+	m.setLocked(key, value, ttl)
+	return true, nil
+}
+
+func (m *Memory) CompareAndDelete(ctx context.Context, key string, tags []string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.matchLocked(key, tags) {
+		return false, nil
+	}
+	delete(m.entries, key)
+	return true, nil
+}
+
+// matchLocked reports whether key exists and its value matches one of tags.
+func (m *Memory) matchLocked(key string, tags []string) bool {
+	entry, ok := m.getLocked(key)
+	return ok && tagsMatch(entry.value, tags)
+}
+
+// tagsMatch reports whether value matches one of tags.
+func tagsMatch(value string, tags []string) bool {
+	sum := sha1.Sum([]byte(value))
+	tag := hex.EncodeToString(sum[:])
+	for _, t := range tags {
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch implements the glob syntax of SCAN MATCH: * and ? wildcards,
+// [abc], [^abc] and [a-z] classes, and backslash escapes.
+func globMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			rest, ok := matchClass(pattern[1:], s[0])
+			if !ok {
+				return false
+			}
+			pattern, s = rest, s[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchClass matches c against the character class at the start of pattern,
+// just after the opening bracket, and returns the pattern after the class.
+func matchClass(pattern string, c byte) (string, bool) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:] // Closing bracket
+	}
+	return pattern, matched != negate
+}
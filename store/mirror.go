@@ -0,0 +1,275 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Migration modes of a Mirrored store.
+const (
+	// MirrorDualWrite writes to both stores and reads from the primary,
+	// comparing each read against the secondary in the background.
+	MirrorDualWrite = "dual_write"
+	// MirrorReadFallback writes to the primary and reads keys it lacks from
+	// the secondary.
+	MirrorReadFallback = "read_fallback"
+)
+
+// maxShadowReads bounds the background reads comparing the two stores;
+// reads beyond it are skipped rather than queued.
+const maxShadowReads = 64
+
+// secondaryCursor prefixes the Scan cursors of the secondary's pass in
+// MirrorReadFallback mode. Neither backend issues cursors containing ':'.
+const secondaryCursor = "s:"
+
+// MirrorOptions configure a Mirrored store.
+type MirrorOptions struct {
+	// Mode is MirrorDualWrite or MirrorReadFallback.
+	Mode string
+	// ShadowTimeout limits each background read of the secondary.
+	ShadowTimeout time.Duration
+	// Observe, if set, is called with the operation and its outcome on the
+	// secondary: "match", "mismatch", "error" or "skipped" for shadow reads,
+	// "fallback" for reads it served, and "ok" or "error" for writes.
+	Observe func(op, result string)
+}
+
+// Mirrored runs a Store alongside the one it is replacing, so keys can be
+// moved between servers without downtime. The primary is authoritative:
+// its errors fail the request, while the secondary's are only observed.
+type Mirrored struct {
+	primary   Store
+	secondary Store
+	opts      MirrorOptions
+	shadows   chan struct{}
+}
+
+// NewMirrored returns a Store serving primary with secondary mirrored in
+// the given mode.
+func NewMirrored(primary, secondary Store, opts MirrorOptions) *Mirrored {
+	if opts.Observe == nil {
+		opts.Observe = func(string, string) {}
+	}
+	return &Mirrored{
+		primary:   primary,
+		secondary: secondary,
+		opts:      opts,
+		shadows:   make(chan struct{}, maxShadowReads),
+	}
+}
+
+// IsMirrored reports whether st, or the store a cache fronts, is Mirrored.
+// Writes made around the Store only reach the primary, so callers have to
+// route them through it or refuse them.
+func IsMirrored(st Store) bool {
+	if c, ok := st.(*Cached); ok {
+		st = c.Store
+	}
+	_, ok := st.(*Mirrored)
+	return ok
+}
+
+func (m *Mirrored) fallback() bool {
+	return m.opts.Mode == MirrorReadFallback
+}
+
+// mirror reports the outcome of a write copied to the secondary.
+func (m *Mirrored) mirror(op string, err error) {
+	if err != nil {
+		m.opts.Observe(op, "error")
+		return
+	}
+	m.opts.Observe(op, "ok")
+}
+
+// Ping checks the primary only, so a secondary being drained or not yet
+// ready doesn't take the proxy out of service.
+func (m *Mirrored) Ping(ctx context.Context) error {
+	return m.primary.Ping(ctx)
+}
+
+func (m *Mirrored) Get(ctx context.Context, key string) (string, error) {
+	value, err := m.primary.Get(ctx, key)
+	if m.fallback() {
+		if err != ErrNotFound {
+			return value, err
+		}
+		value, err = m.secondary.Get(ctx, key)
+		if err == nil {
+			m.opts.Observe("get", "fallback")
+		}
+		return value, err
+	}
+
+	if err == nil || err == ErrNotFound {
+		m.shadowGet(ctx, key, value, err == ErrNotFound)
+	}
+	return value, err
+}
+
+// shadowGet compares the secondary's copy of key with what the primary
+// returned, without delaying the response.
+func (m *Mirrored) shadowGet(ctx context.Context, key, value string, missing bool) {
+	select {
+	case m.shadows <- struct{}{}:
+	default:
+		m.opts.Observe("get", "skipped")
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() { <-m.shadows }()
+		ctx, cancel := context.WithTimeout(ctx, m.opts.ShadowTimeout)
+		defer cancel()
+
+		shadow, err := m.secondary.Get(ctx, key)
+		switch {
+		case err != nil && err != ErrNotFound:
+			m.opts.Observe("get", "error")
+		case (err == ErrNotFound) != missing || shadow != value:
+			m.opts.Observe("get", "mismatch")
+		default:
+			m.opts.Observe("get", "match")
+		}
+	}()
+}
+
+func (m *Mirrored) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := m.primary.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	m.mirrorSet(ctx, key, value, ttl)
+	return nil
+}
+
+// mirrorSet follows a write to the primary. In MirrorReadFallback mode the
+// secondary's copy is deleted instead, so it can't be read again once the
+// new value expires.
+func (m *Mirrored) mirrorSet(ctx context.Context, key, value string, ttl time.Duration) {
+	if m.fallback() {
+		_, err := m.secondary.Del(ctx, key)
+		m.mirror("del", err)
+		return
+	}
+	m.mirror("set", m.secondary.Set(ctx, key, value, ttl))
+}
+
+// Del deletes key from both stores in either mode, so a deleted key can't
+// reappear from the secondary.
+func (m *Mirrored) Del(ctx context.Context, key string) (bool, error) {
+	deleted, err := m.primary.Del(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	shadowDeleted, err := m.secondary.Del(ctx, key)
+	m.mirror("del", err)
+	if m.fallback() {
+		deleted = deleted || shadowDeleted
+	}
+	return deleted, nil
+}
+
+func (m *Mirrored) Exists(ctx context.Context, key string) (bool, error) {
+	exists, err := m.primary.Exists(ctx, key)
+	if err != nil || exists || !m.fallback() {
+		return exists, err
+	}
+	return m.secondary.Exists(ctx, key)
+}
+
+// Scan walks the primary, then in MirrorReadFallback mode the secondary,
+// leaving out the secondary's keys the primary also has.
+func (m *Mirrored) Scan(ctx context.Context, pattern, cursor string, count int) ([]string, string, error) {
+	if !m.fallback() {
+		return m.primary.Scan(ctx, pattern, cursor, count)
+	}
+
+	rest, secondary := strings.CutPrefix(cursor, secondaryCursor)
+	if !secondary {
+		keys, next, err := m.primary.Scan(ctx, pattern, cursor, count)
+		if err == nil && next == "0" {
+			next = secondaryCursor + "0"
+		}
+		return keys, next, err
+	}
+
+	keys, next, err := m.secondary.Scan(ctx, pattern, rest, count)
+	if err != nil {
+		return nil, "", err
+	}
+	if next != "0" {
+		next = secondaryCursor + next
+	}
+	filtered := keys[:0]
+	for _, key := range keys {
+		exists, err := m.primary.Exists(ctx, key)
+		if err != nil {
+			return nil, "", err
+		}
+		if !exists {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered, next, nil
+}
+
+// CompareAndSet in MirrorReadFallback mode matches tags against the
+// secondary's value when the primary lacks the key, and moves the key to the
+// primary. That check and write aren't atomic across the stores.
+func (m *Mirrored) CompareAndSet(ctx context.Context, key, value string, ttl time.Duration, tags []string) (bool, error) {
+	applied, err := m.primary.CompareAndSet(ctx, key, value, ttl, tags)
+	if err != nil {
+		return false, err
+	}
+	if applied {
+		m.mirrorSet(ctx, key, value, ttl)
+		return true, nil
+	}
+	if !m.fallback() {
+		return false, nil
+	}
+
+	if exists, err := m.primary.Exists(ctx, key); err != nil || exists {
+		return false, err
+	}
+	current, err := m.secondary.Get(ctx, key)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !tagsMatch(current, tags) {
+		return false, nil
+	}
+	if err := m.Set(ctx, key, value, ttl); err != nil {
+		return false, err
+	}
+	m.opts.Observe("set", "fallback")
+	return true, nil
+}
+
+// CompareAndDelete in MirrorReadFallback mode deletes from the secondary
+// when the primary lacks the key.
+func (m *Mirrored) CompareAndDelete(ctx context.Context, key string, tags []string) (bool, error) {
+	applied, err := m.primary.CompareAndDelete(ctx, key, tags)
+	if err != nil {
+		return false, err
+	}
+	if applied {
+		_, err := m.secondary.Del(ctx, key)
+		m.mirror("del", err)
+		return true, nil
+	}
+	if !m.fallback() {
+		return false, nil
+	}
+
+	if exists, err := m.primary.Exists(ctx, key); err != nil || exists {
+		return false, err
+	}
+	return m.secondary.CompareAndDelete(ctx, key, tags)
+}
@@ -0,0 +1,58 @@
+// Package store abstracts the key-value operations behind the /keys
+// endpoints, so they can run against Valkey or an in-memory map.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by Get for keys that don't exist.
+	ErrNotFound = errors.New("key not found")
+	// ErrInvalidCursor is returned by Scan for cursors it did not issue.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+type dbKey struct{}
+
+// WithDB returns a context whose commands run against logical database db
+// rather than the one the backend was configured with.
+func WithDB(ctx context.Context, db int64) context.Context {
+	return context.WithValue(ctx, dbKey{}, db)
+}
+
+// DBFromContext returns the database selected with WithDB, if any.
+func DBFromContext(ctx context.Context) (int64, bool) {
+	db, ok := ctx.Value(dbKey{}).(int64)
+	return db, ok
+}
+
+// Store holds string values by key. Implementations must be safe for
+// concurrent use.
+//
+// Conditional writes compare the current value against ETags, given as the
+// hex SHA-1 of the stored bytes, or "*" to match any existing value.
+type Store interface {
+	// Ping checks that the backend is reachable.
+	Ping(ctx context.Context) error
+	// Get returns the value of key, or ErrNotFound.
+	Get(ctx context.Context, key string) (string, error)
+	// Set stores value under key. A ttl of zero keeps the key forever.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Del deletes key and reports whether it existed.
+	Del(ctx context.Context, key string) (bool, error)
+	// Exists reports whether key exists.
+	Exists(ctx context.Context, key string) (bool, error)
+	// Scan returns one page of keys matching a glob pattern along with the
+	// cursor to continue from, which is "0" once every key has been visited.
+	// Scans start from cursor "0" and count is a page size hint.
+	Scan(ctx context.Context, pattern, cursor string, count int) ([]string, string, error)
+	// CompareAndSet stores value only if the current value matches one of
+	// tags, and reports whether it did.
+	CompareAndSet(ctx context.Context, key, value string, ttl time.Duration, tags []string) (bool, error)
+	// CompareAndDelete deletes key only if its value matches one of tags,
+	// and reports whether it did.
+	CompareAndDelete(ctx context.Context, key string, tags []string) (bool, error)
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// Valkey is the default Store, backed by a Valkey standalone, Sentinel or
+// cluster deployment.
+type Valkey struct {
+	client valkey.Client
+}
+
+// NewValkey returns a Store that runs commands through client.
+func NewValkey(client valkey.Client) *Valkey {
+	return &Valkey{client: client}
+}
+
+func (v *Valkey) Ping(ctx context.Context) error {
+	return v.client.Do(ctx, v.client.B().Ping().Build()).Error()
+}
+
+func (v *Valkey) Get(ctx context.Context, key string) (string, error) {
+	result, err := v.client.Do(ctx, v.client.B().Get().Key(key).Build()).ToString()
+	if valkey.IsValkeyNil(err) {
+		return "", ErrNotFound
+	}
+	return result, err
+}
+
+func (v *Valkey) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	builder := v.client.B().Set().Key(key).Value(value)
+	if ttl > 0 {
+		return v.client.Do(ctx, builder.Px(ttl).Build()).Error()
+	}
+	return v.client.Do(ctx, builder.Build()).Error()
+}
+
+func (v *Valkey) Del(ctx context.Context, key string) (bool, error) {
+	n, err := v.client.Do(ctx, v.client.B().Del().Key(key).Build()).AsInt64()
+	return n > 0, err
+}
+
+func (v *Valkey) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := v.client.Do(ctx, v.client.B().Exists().Key(key).Build()).AsInt64()
+	return n > 0, err
+}
+
+// Scan runs a single SCAN step.
+//
+// Standalone cursors are the SCAN cursor itself. In cluster mode only
+// primaries are walked; the cursor also records which one, encoded as base64
+// of "address cursor", and moves on to the next when one is exhausted.
+func (v *Valkey) Scan(ctx context.Context, pattern, cursor string, count int) ([]string, string, error) {
+	if v.client.Mode() != valkey.ClientModeCluster {
+		c, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		result, err := v.client.Do(ctx, v.client.B().Scan().Cursor(c).Match(pattern).Count(int64(count)).Build()).AsScanEntry()
+		if err != nil {
+			return nil, "", err
+		}
+		return result.Elements, strconv.FormatUint(result.Cursor, 10), nil
+	}
+
+	// Walk nodes in a stable order so the cursor can name the next one
+	nodes := v.client.Nodes()
+	addrs := make([]string, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var addr string
+	nodeCursor := uint64(0)
+	if cursor == "0" {
+		first, err := nextPrimary(ctx, nodes, addrs, 0)
+		if err != nil {
+			return nil, "", err
+		}
+		if first == "" {
+			return []string{}, "0", nil
+		}
+		addr = first
+	} else {
+		decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		a, c, ok := strings.Cut(string(decoded), " ")
+		if !ok {
+			return nil, "", ErrInvalidCursor
+		}
+		if nodeCursor, err = strconv.ParseUint(c, 10, 64); err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		addr = a
+	}
+
+	node, ok := nodes[addr]
+	if !ok {
+		// The node left the cluster since the previous page
+		return nil, "", ErrInvalidCursor
+	}
+
+	result, err := node.Do(ctx, node.B().Scan().Cursor(nodeCursor).Match(pattern).Count(int64(count)).Build()).AsScanEntry()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if result.Cursor != 0 {
+		return result.Elements, encodeClusterCursor(addr, result.Cursor), nil
+	}
+	// Continue with the next primary, if any
+	next, err := nextPrimary(ctx, nodes, addrs, sort.SearchStrings(addrs, addr)+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if next != "" {
+		return result.Elements, encodeClusterCursor(next, 0), nil
+	}
+	return result.Elements, "0", nil
+}
+
+// nextPrimary returns the first primary in addrs from index i on, or "" if
+// there is none. Replicas are skipped, as they hold copies of their
+// primary's keys and would list them twice.
+func nextPrimary(ctx context.Context, nodes map[string]valkey.Client, addrs []string, i int) (string, error) {
+	for ; i < len(addrs); i++ {
+		primary, err := IsPrimary(ctx, nodes[addrs[i]])
+		if err != nil {
+			return "", err
+		}
+		if primary {
+			return addrs[i], nil
+		}
+	}
+	return "", nil
+}
+
+// IsPrimary reports whether a node is a primary, according to ROLE.
+func IsPrimary(ctx context.Context, node valkey.Client) (bool, error) {
+	role, err := node.Do(ctx, node.B().Role().Build()).ToArray()
+	if err != nil {
+		return false, err
+	}
+	if len(role) == 0 {
+		return false, nil
+	}
+	name, err := role[0].ToString()
+	return name == "master", err
+}
+
+func encodeClusterCursor(addr string, cursor uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(addr + " " + strconv.FormatUint(cursor, 10)))
+}
+
+// compareAndSetScript writes or deletes a key only if its current value's
+// ETag matches one of the given tags, making If-Match atomic.
+//
+// KEYS[1] key; ARGV: "set" or "del", value, expiration in ms (0 for none),
+// then the tags ("*" matches any existing value).
+// Returns 1 when applied, 0 when the precondition failed.
+var compareAndSetScript = valkey.NewLuaScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+  return 0
+end
+local tag = redis.sha1hex(current)
+local matched = false
+for i = 4, #ARGV do
+  if ARGV[i] == '*' or ARGV[i] == tag then
+    matched = true
+    break
+  end
+end
+if not matched then
+  return 0
+end
+if ARGV[1] == 'del' then
+  redis.call('DEL', KEYS[1])
+elseif tonumber(ARGV[3]) > 0 then
+  redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+  redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+func (v *Valkey) CompareAndSet(ctx context.Context, key, value string, ttl time.Duration, tags []string) (bool, error) {
+	return v.compareAndSet(ctx, "set", key, value, ttl, tags)
+}
+
+func (v *Valkey) CompareAndDelete(ctx context.Context, key string, tags []string) (bool, error) {
+	return v.compareAndSet(ctx, "del", key, "", 0, tags)
+}
+
+func (v *Valkey) compareAndSet(ctx context.Context, op, key, value string, ttl time.Duration, tags []string) (bool, error) {
+	args := append([]string{op, value, strconv.FormatInt(ttl.Milliseconds(), 10)}, tags...)
+	applied, err := compareAndSetScript.Exec(ctx, v.client, []string{key}, args).AsInt64()
+	return applied == 1, err
+}
@@ -0,0 +1,5 @@
+// Package valkeyrestpb holds the protobuf messages and gRPC stubs for the
+// gRPC API. Regenerate after editing valkeyrest.proto.
+package valkeyrestpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative valkeyrest.proto